@@ -3,17 +3,21 @@ package main
 import (
 	"os"
 	"sync"
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 
+	"github.com/jvlerner/my-finance-api/internal/cli"
+	"github.com/jvlerner/my-finance-api/internal/db"
 	"github.com/jvlerner/my-finance-api/internal/handlers"
 	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/mynance-platform/config"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/migrate"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
+	"github.com/jvlerner/mynance-platform/prometheus"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 var (
@@ -23,75 +27,111 @@ var (
 )
 
 func main() {
+	// Logger antes de tudo: os subcomandos também registram o que fazem
+	logger.InitLogger()
 	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
 
-	logger.InitLogger()
-	defer logger.CloseLogger()
+	// Subcomandos de linha de comando (ex.: bootstrap-admin) rodam no lugar do servidor
+	if len(os.Args) > 1 {
+		code := cli.Run(os.Args[1:])
+		logger.CloseLogger()
+		os.Exit(code)
+	}
 
-	logger.Log.Info("[INFO] Starting MyFinance Auth Admin/Service...")
+	// Este serviço usa dois bancos e autentica admins e serviços por conta própria
+	r := server.New(server.Config{Name: "MyFinance Auth Admin/Service"})
 
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		postgres.InitDB(userDBName,
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_NAME"),
-		)
+		db.Connect(userDBName, "DB")
 	}()
 
 	go func() {
 		defer wg.Done()
-		postgres.InitDB(adminDBName,
-			os.Getenv("DB_ADMIN_USER"),
-			os.Getenv("DB_ADMIN_PASSWORD"),
-			os.Getenv("DB_ADMIN_HOST"),
-			os.Getenv("DB_ADMIN_PORT"),
-			os.Getenv("DB_ADMIN_NAME"),
-		)
+		db.Connect(adminDBName, "DB_ADMIN")
 	}()
 	wg.Wait()
-	defer postgres.CloseAll()
+	r.OnShutdown(db.CloseAll)
+
+	// Só o db-auth-admin é deste serviço; o db-auth é migrado pelo mynance-auth
+	migrate.Apply(db.GetDB(adminDBName), migrations.FS)
+	r.AddCheck("migrations", migrate.Check(db.GetDB(adminDBName), migrations.FS))
 
 	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.GetDB(userDBName), userDBName)
-	defer prometheus.RemoveDBFromMonitoring(userDBName)
-
-	prometheus.SetDBForMonitoring(postgres.GetDB(adminDBName), adminDBName)
-	defer prometheus.RemoveDBFromMonitoring(adminDBName)
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
+	for _, name := range []string{userDBName, adminDBName} {
+		database := db.GetDB(name)
+		prometheus.SetDBForMonitoring(database, name)
+		r.OnShutdown(func() { prometheus.RemoveDBFromMonitoring(name) })
+		r.AddCheck("database "+name, database.PingContext)
+	}
+
+	// Chaves deste serviço (admin/service) e chaves públicas do mynance-auth (usuários)
+	jwks.Init()
+	r.OnShutdown(jwks.Close)
+	handlers.InitUserKeys()
+
+	// Algoritmo e custo do hash das senhas
+	hasher.Init()
+
+	// Regras de senha e lista de senhas vazadas
+	passwordpolicy.Init()
+
+	r.GET("/.well-known/jwks.json", jwks.Handler)
 
 	r.POST("/auth/service/login", handlers.LoginService)
 	r.POST("/auth/admin/login", handlers.LoginAdmin)
 
 	seviceRoutes := r.Group("/auth/service")
 	seviceRoutes.Use(middleware.ServiceAuth())
-	seviceRoutes.GET("/validate-token", handlers.ValidateUserToken)
+	seviceRoutes.GET("/validate-token", middleware.RequireServiceScope(middleware.ServiceScopeValidateToken), handlers.ValidateUserToken)
 
+	oauthRoutes := r.Group("/oauth")
+	oauthRoutes.Use(middleware.ServiceAuth(), middleware.RequireServiceScope(middleware.ServiceScopeIntrospect))
+	oauthRoutes.POST("/introspect", handlers.IntrospectToken)
+	oauthRoutes.POST("/introspect/batch", handlers.IntrospectTokens)
+
+	// Cada rota declara a permissão exigida; os papéis que a concedem ficam em internal/rbac
+	can := middleware.RequirePermission
 	adminRoutes := r.Group("/auth/admin")
 	adminRoutes.Use(middleware.AdminAuth())
-	adminRoutes.POST("/logout", handlers.LogoutAdmin)
-	adminRoutes.POST("/register", handlers.RegisterServiceAccount)
-
-	r.Run(":8080")
+	adminRoutes.POST("/logout", can(rbac.PermOwnAccount), handlers.LogoutAdmin)
+	adminRoutes.GET("/me", can(rbac.PermOwnAccount), handlers.CurrentAdmin)
+	adminRoutes.POST("/register", can(rbac.PermServiceAccountsManage), handlers.RegisterServiceAccount)
+	adminRoutes.POST("/users/:id/2fa/reset", can(rbac.PermUsersSecurity), handlers.ResetUserTwoFactor)
+	adminRoutes.POST("/lockouts/unlock", can(rbac.PermUsersSecurity), handlers.UnlockLogin)
+	adminRoutes.GET("/audit", can(rbac.PermAuditRead), handlers.ListAuditLog)
+
+	adminRoutes.GET("/admins", can(rbac.PermAdminsManage), handlers.ListAdmins)
+	adminRoutes.POST("/admins", can(rbac.PermAdminsManage), handlers.CreateAdmin)
+	adminRoutes.PUT("/admins/:id/role", can(rbac.PermAdminsManage), handlers.ChangeAdminRole)
+
+	adminRoutes.GET("/service-accounts", can(rbac.PermServiceAccountsRead), handlers.ListServiceAccounts)
+	adminRoutes.POST("/service-accounts", can(rbac.PermServiceAccountsManage), handlers.RegisterServiceAccount)
+	adminRoutes.GET("/service-accounts/:id", can(rbac.PermServiceAccountsRead), handlers.GetServiceAccount)
+	adminRoutes.PUT("/service-accounts/:id", can(rbac.PermServiceAccountsManage), handlers.UpdateServiceAccount)
+	adminRoutes.POST("/service-accounts/:id/rotate", can(rbac.PermServiceAccountsManage), handlers.RotateServiceAccountSecret)
+	adminRoutes.DELETE("/service-accounts/:id", can(rbac.PermServiceAccountsManage), handlers.RevokeServiceAccount)
+
+	adminRoutes.GET("/oauth-clients", can(rbac.PermOAuthClientsRead), handlers.ListOAuthClients)
+	adminRoutes.POST("/oauth-clients", can(rbac.PermOAuthClientsManage), handlers.CreateOAuthClient)
+	adminRoutes.GET("/oauth-clients/:id", can(rbac.PermOAuthClientsRead), handlers.GetOAuthClient)
+	adminRoutes.PUT("/oauth-clients/:id", can(rbac.PermOAuthClientsManage), handlers.UpdateOAuthClient)
+	adminRoutes.POST("/oauth-clients/:id/rotate", can(rbac.PermOAuthClientsManage), handlers.RotateOAuthClientSecret)
+	adminRoutes.DELETE("/oauth-clients/:id", can(rbac.PermOAuthClientsManage), handlers.RevokeOAuthClient)
+
+	adminRoutes.GET("/users", can(rbac.PermUsersRead), handlers.ListUsers)
+	adminRoutes.GET("/users/:id", can(rbac.PermUsersRead), handlers.GetUser)
+	adminRoutes.POST("/users/:id/deactivate", can(rbac.PermUsersManage), handlers.DeactivateUser)
+	adminRoutes.POST("/users/:id/reactivate", can(rbac.PermUsersManage), handlers.ReactivateUser)
+	adminRoutes.PUT("/users/:id/role", can(rbac.PermUsersManage), handlers.ChangeUserRole)
+	adminRoutes.POST("/users/:id/password-reset", can(rbac.PermUsersSecurity), handlers.ForceUserPasswordReset)
+	adminRoutes.POST("/users/:id/sessions/revoke", can(rbac.PermUsersSecurity), handlers.RevokeUserSessions)
+	adminRoutes.POST("/users/:id/impersonate", can(rbac.PermUsersImpersonate), handlers.ImpersonateUser)
+
+	adminRoutes.GET("/impersonations", can(rbac.PermAuditRead), handlers.ListImpersonations)
+	adminRoutes.GET("/impersonations/:id/requests", can(rbac.PermAuditRead), handlers.ListImpersonationRequests)
+	adminRoutes.DELETE("/impersonations/:id", can(rbac.PermUsersImpersonate), handlers.EndImpersonation)
+
+	r.Run()
 }
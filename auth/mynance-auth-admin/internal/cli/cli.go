@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/migrate"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
+)
+
+const usage = `Usage: api <command> [flags]
+
+Commands:
+  bootstrap-admin   create the first superadmin of mynance-auth-admin
+  migrate           apply, revert or verify the db-auth-admin migrations (see "migrate help")
+
+Without a command the HTTP server is started.
+`
+
+// Run executes a command line subcommand and returns the process exit code
+func Run(args []string) int {
+	switch args[0] {
+	case "bootstrap-admin":
+		return bootstrapAdmin(args[1:], os.Stdin, os.Stdout, os.Stderr)
+	case "migrate":
+		return migrateAdminDB(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// migrateAdminDB runs the migrate subcommand against db-auth-admin, the database this service owns
+func migrateAdminDB(args []string) int {
+	dbName := os.Getenv("ADMIN_DB_NAME")
+	db.Connect(dbName, "DB_ADMIN")
+	defer db.CloseAll()
+
+	return migrate.Command(db.GetDB(dbName), migrations.FS, args)
+}
+
+// bootstrapAdmin creates a superadmin while none is active; afterwards admins are managed through the API.
+// The password comes from ADMIN_BOOTSTRAP_PASSWORD or stdin (-password-stdin), otherwise one is generated and printed.
+func bootstrapAdmin(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	email := flags.String("email", "", "admin email (required)")
+	name := flags.String("name", "Admin", "admin name")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from the first line of stdin")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	*email = strings.TrimSpace(*email)
+	if *email == "" {
+		fmt.Fprintln(stderr, "-email is required")
+		flags.Usage()
+		return 2
+	}
+
+	password := os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	if *passwordStdin {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(stderr, "failed to read password: %v\n", err)
+			return 1
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	hasher.Init()
+	passwordpolicy.Init()
+
+	generated := password == ""
+	if generated {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			fmt.Fprintf(stderr, "failed to generate password: %v\n", err)
+			return 1
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	} else if violations := passwordpolicy.Check(password, *email, *name); len(violations) > 0 {
+		for _, violation := range violations {
+			fmt.Fprintln(stderr, violation.Message)
+		}
+		return 1
+	}
+
+	dbName := os.Getenv("ADMIN_DB_NAME")
+	db.Connect(dbName, "DB_ADMIN")
+	defer db.CloseAll()
+
+	ctx := context.Background()
+
+	// Só serve para o primeiro acesso: depois disso um superadmin cria os demais pela API
+	exists, err := db.ActiveSuperadminExists(ctx, dbName)
+	if err != nil {
+		fmt.Fprintf(stderr, "database error: %v\n", err)
+		return 1
+	}
+	if exists {
+		fmt.Fprintln(stderr, "an active superadmin already exists, create other admins through POST /auth/admin/admins")
+		return 1
+	}
+
+	taken, err := db.UserExists(ctx, dbName, *email)
+	if err != nil {
+		fmt.Fprintf(stderr, "database error: %v\n", err)
+		return 1
+	}
+	if taken {
+		fmt.Fprintf(stderr, "email %s is already in use\n", *email)
+		return 1
+	}
+
+	details, _ := json.Marshal(map[string]string{"email": *email, "role": rbac.RoleSuperadmin})
+	entry := db.AuditEntry{
+		AdminEmail: "bootstrap-cli",
+		Action:     "admin.bootstrap",
+		TargetType: "admin",
+		Details:    details,
+	}
+	var adminID int
+	err = db.Audited(ctx, dbName, dbName, &entry, func(tx *sql.Tx) error {
+		var err error
+		adminID, err = db.CreateAdmin(ctx, tx, *name, *email, password, rbac.RoleSuperadmin)
+		entry.TargetID = strconv.Itoa(adminID)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to create admin: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Admin %s created with role %s (id %d)\n", *email, rbac.RoleSuperadmin, adminID)
+	if generated {
+		fmt.Fprintf(stdout, "Generated password: %s\nStore it now, it is not shown again.\n", password)
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jvlerner/mynance-platform/hasher"
+)
+
+// ErrLastSuperadmin is returned when a change would leave no active superadmin
+var ErrLastSuperadmin = errors.New("at least one active superadmin is required")
+
+// CreateAdmin inserts an admin account with the given role
+func CreateAdmin(ctx context.Context, tx *sql.Tx, name, email, password, role string) (int, error) {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		name, email, hashedPassword, role).Scan(&userID)
+	return userID, err
+}
+
+// ActiveSuperadminExists reports whether some active account can already manage admins
+func ActiveSuperadminExists(ctx context.Context, dbName string) (bool, error) {
+	db := GetDB(dbName)
+
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE role = 'superadmin' AND active)").Scan(&exists)
+	return exists, err
+}
+
+// GetAdmins lists every admin account, service accounts excluded
+func GetAdmins(ctx context.Context, dbName string) ([]AdminAccount, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, "SELECT id, name, email, role, COALESCE(active, FALSE), created_at FROM users WHERE role <> 'service' ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := []AdminAccount{}
+	for rows.Next() {
+		var admin AdminAccount
+		if err := rows.Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Role, &admin.Active, &admin.CreatedAt); err != nil {
+			return nil, err
+		}
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+// GetAdmin returns an admin account, or nil if there is none with this id
+func GetAdmin(ctx context.Context, dbName string, id int) (*AdminAccount, error) {
+	db := GetDB(dbName)
+
+	var admin AdminAccount
+	err := db.QueryRowContext(ctx, "SELECT id, name, email, role, COALESCE(active, FALSE), created_at FROM users WHERE id = $1 AND role <> 'service'", id).
+		Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Role, &admin.Active, &admin.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// UpdateAdminRole changes the role of an admin; demoting the last active superadmin returns ErrLastSuperadmin
+func UpdateAdminRole(ctx context.Context, tx *sql.Tx, id int, role string) error {
+	// Trava os superadmins para duas trocas simultâneas não removerem o último
+	var superadmins int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT id FROM users WHERE role = 'superadmin' AND active AND id <> $1 FOR UPDATE) s`, id).
+		Scan(&superadmins); err != nil {
+		return err
+	}
+
+	var current string
+	if err := tx.QueryRowContext(ctx, "SELECT role FROM users WHERE id = $1 FOR UPDATE", id).Scan(&current); err != nil {
+		return err
+	}
+	if current == "superadmin" && role != "superadmin" && superadmins == 0 {
+		return ErrLastSuperadmin
+	}
+
+	_, err := tx.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id)
+	return err
+}
+
+// GetAdminAuth returns what AdminAuth checks on every request, or nil if the account does not exist
+func GetAdminAuth(ctx context.Context, dbName string, id int) (*AdminAuth, error) {
+	db := GetDB(dbName)
+
+	var auth AdminAuth
+	err := db.QueryRowContext(ctx, "SELECT role, COALESCE(active, FALSE), last_password_change FROM users WHERE id = $1", id).
+		Scan(&auth.Role, &auth.Active, &auth.LastPasswordChange)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &auth, nil
+}
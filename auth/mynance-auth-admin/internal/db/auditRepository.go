@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Audited runs action in a transaction on dbName and records entry in admin_audit_log of auditDBName;
+// if the row cannot be written the action is rolled back, so no admin action goes unrecorded.
+// action may fill in entry, e.g. with the id of what it created, before the row is written.
+func Audited(ctx context.Context, dbName, auditDBName string, entry *AuditEntry, action func(tx *sql.Tx) error) error {
+	tx, err := GetDB(dbName).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := action(tx); err != nil {
+		return err
+	}
+
+	if dbName == auditDBName {
+		if _, err := insertAudit(ctx, tx, entry); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// Bancos diferentes não dividem transação: a linha é gravada antes do commit da ação
+	// e removida se o commit falhar, para o registro nunca faltar nem sobrar
+	auditDB := GetDB(auditDBName)
+	auditID, err := insertAudit(ctx, auditDB, entry)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		if _, deleteErr := auditDB.ExecContext(context.WithoutCancel(ctx), "DELETE FROM admin_audit_log WHERE id = $1", auditID); deleteErr != nil {
+			return errors.Join(err, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertAudit stores an admin action; details is a JSON object or nil
+func insertAudit(ctx context.Context, q queryRower, entry *AuditEntry) (int, error) {
+	var details interface{}
+	if len(entry.Details) > 0 {
+		details = string(entry.Details)
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx, `INSERT INTO admin_audit_log (admin_id, admin_email, action, target_type, target_id, details, ip)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7) RETURNING id`,
+		entry.AdminID, entry.AdminEmail, entry.Action, entry.TargetType, entry.TargetID, details, entry.IP).Scan(&id)
+	return id, err
+}
+
+// GetAuditLog returns the most recent admin actions, optionally only the ones on a target
+func GetAuditLog(ctx context.Context, dbName, targetType, targetID string, limit, offset int) ([]AuditEntry, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, `SELECT id, admin_id, admin_email, action, target_type, COALESCE(target_id, ''),
+			COALESCE(details, '{}'::jsonb), COALESCE(ip, ''), created_at
+		FROM admin_audit_log
+		WHERE ($1 = '' OR target_type = $1) AND ($2 = '' OR target_id = $2)
+		ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`, targetType, targetID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.AdminID, &entry.AdminEmail, &entry.Action, &entry.TargetType, &entry.TargetID,
+			&details, &entry.IP, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testUserDB  = "db-auth"
+	testAdminDB = "db-auth-admin"
+)
+
+func mockDB(t *testing.T, name string) sqlmock.Sqlmock {
+	t.Helper()
+
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register(name, database)
+	t.Cleanup(func() {
+		Register(name, nil)
+		database.Close()
+	})
+	return mock
+}
+
+func auditEntry() *AuditEntry {
+	adminID := 1
+	return &AuditEntry{AdminID: &adminID, AdminEmail: "root@mynance.test", Action: "user.deactivate", TargetType: "user", IP: "203.0.113.1"}
+}
+
+// deactivate plays an admin action; it fills in the target like the handlers do
+func deactivate(entry *AuditEntry, err error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		if _, execErr := tx.ExecContext(context.Background(), "UPDATE users SET active = FALSE WHERE id = $1", 7); execErr != nil {
+			return execErr
+		}
+		entry.TargetID = "7"
+		return err
+	}
+}
+
+func expectAuditRow(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery("INSERT INTO admin_audit_log").
+		WithArgs(1, "root@mynance.test", "user.deactivate", "user", "7", nil, "203.0.113.1")
+}
+
+func TestAuditedOnTheAuditDatabase(t *testing.T) {
+	errAction := errors.New("action failed")
+	errAudit := errors.New("audit insert failed")
+
+	tests := []struct {
+		name      string
+		actionErr error
+		auditErr  error
+		wantErr   error
+	}{
+		{"recorded with the action", nil, nil, nil},
+		// Falha da ação: nem a ação nem o registro ficam
+		{"action fails", errAction, nil, errAction},
+		// Falha do registro: a ação é desfeita
+		{"audit row fails", nil, errAudit, errAudit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t, testAdminDB)
+			entry := auditEntry()
+
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE users SET active = FALSE").WillReturnResult(sqlmock.NewResult(0, 1))
+			switch {
+			case tt.actionErr != nil:
+				mock.ExpectRollback()
+			case tt.auditErr != nil:
+				expectAuditRow(mock).WillReturnError(tt.auditErr)
+				mock.ExpectRollback()
+			default:
+				expectAuditRow(mock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				mock.ExpectCommit()
+			}
+
+			err := Audited(context.Background(), testAdminDB, testAdminDB, entry, deactivate(entry, tt.actionErr))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Audited = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAuditedAcrossDatabases(t *testing.T) {
+	errAction := errors.New("action failed")
+	errAudit := errors.New("audit insert failed")
+	errCommit := errors.New("commit failed")
+	errDelete := errors.New("delete failed")
+
+	tests := []struct {
+		name      string
+		actionErr error
+		auditErr  error
+		commitErr error
+		deleteErr error
+		wantErrs  []error
+	}{
+		{"recorded with the action", nil, nil, nil, nil, nil},
+		{"action fails before the audit row", errAction, nil, nil, nil, []error{errAction}},
+		{"audit row fails before the commit", nil, errAudit, nil, nil, []error{errAudit}},
+		// O commit da ação falhou depois da linha gravada: a linha é removida
+		{"commit fails", nil, nil, errCommit, nil, []error{errCommit}},
+		{"commit and compensation fail", nil, nil, errCommit, errDelete, []error{errCommit, errDelete}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := mockDB(t, testUserDB)
+			audit := mockDB(t, testAdminDB)
+			entry := auditEntry()
+
+			users.ExpectBegin()
+			users.ExpectExec("UPDATE users SET active = FALSE").WillReturnResult(sqlmock.NewResult(0, 1))
+			switch {
+			case tt.actionErr != nil:
+				users.ExpectRollback()
+			case tt.auditErr != nil:
+				expectAuditRow(audit).WillReturnError(tt.auditErr)
+				users.ExpectRollback()
+			default:
+				expectAuditRow(audit).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				if tt.commitErr == nil {
+					users.ExpectCommit()
+					break
+				}
+				users.ExpectCommit().WillReturnError(tt.commitErr)
+				deleted := audit.ExpectExec("DELETE FROM admin_audit_log WHERE id = \\$1").WithArgs(42)
+				if tt.deleteErr != nil {
+					deleted.WillReturnError(tt.deleteErr)
+				} else {
+					deleted.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+
+			err := Audited(context.Background(), testUserDB, testAdminDB, entry, deactivate(entry, tt.actionErr))
+			if tt.wantErrs == nil && err != nil {
+				t.Fatalf("Audited = %v, want no error", err)
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Fatalf("Audited = %v, want %v", err, want)
+				}
+			}
+			if err := users.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if err := audit.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"go.uber.org/zap"
+)
+
+// Este serviço usa dois bancos (db-auth e db-auth-admin), registrados pelo nome lido de USER_DB_NAME e ADMIN_DB_NAME
+var (
+	dbs  = make(map[string]*sql.DB)
+	lock = sync.RWMutex{}
+)
+
+// Connect opens the database configured by the <prefix>_* variables and registers it under name
+func Connect(name, prefix string) {
+	Register(name, postgres.Connect(prefix))
+}
+
+// Register makes an opened database available to GetDB under name
+func Register(name string, db *sql.DB) {
+	lock.Lock()
+	defer lock.Unlock()
+	dbs[name] = db
+}
+
+// GetDB retrieves a database registered by Connect
+func GetDB(name string) *sql.DB {
+	lock.RLock()
+	defer lock.RUnlock()
+	return dbs[name]
+}
+
+// CloseAll closes every registered database
+func CloseAll() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for name, db := range dbs {
+		if err := db.Close(); err != nil {
+			logger.Log.Error("Failed to close database connection", zap.String("db", name), zap.Error(err))
+		}
+	}
+	dbs = make(map[string]*sql.DB)
+}
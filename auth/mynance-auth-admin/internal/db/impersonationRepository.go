@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CreateImpersonation stores an impersonation started by an admin; it is valid for ttl
+func CreateImpersonation(ctx context.Context, tx *sql.Tx, impersonation Impersonation, ttl time.Duration) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO impersonations (id, admin_id, user_id, reason, allow_write, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')`,
+		impersonation.ID, impersonation.AdminID, impersonation.UserID, impersonation.Reason, impersonation.AllowWrite,
+		impersonation.IP, int(ttl.Seconds()))
+	return err
+}
+
+// ImpersonationActive reports whether an impersonation was not ended, has not expired and its admin is still active
+func ImpersonationActive(ctx context.Context, dbName, id string) (bool, error) {
+	db := GetDB(dbName)
+
+	var active bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM impersonations i JOIN users u ON u.id = i.admin_id
+		WHERE i.id = $1 AND i.ended_at IS NULL AND i.expires_at > NOW() AND u.active)`, id).Scan(&active)
+	return active, err
+}
+
+// EndImpersonation stops an impersonation before it expires; it returns false if it was not running
+func EndImpersonation(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	result, err := tx.ExecContext(ctx, "UPDATE impersonations SET ended_at = NOW() WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()", id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// RecordImpersonationRequest logs a request made with an impersonation token
+func RecordImpersonationRequest(ctx context.Context, dbName, impersonationID string, request ImpersonationRequest) error {
+	db := GetDB(dbName)
+	_, err := db.ExecContext(ctx, `INSERT INTO impersonation_requests (impersonation_id, service, method, path, allowed)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)`,
+		impersonationID, request.Service, request.Method, request.Path, request.Allowed)
+	return err
+}
+
+// GetImpersonations returns the most recent impersonations, optionally only the ones of a user (userID 0 means all)
+func GetImpersonations(ctx context.Context, dbName string, userID, limit, offset int) ([]Impersonation, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, `SELECT i.id, i.admin_id, u.email, i.user_id, i.reason, i.allow_write, COALESCE(i.ip, ''),
+			i.created_at, i.expires_at, i.ended_at,
+			(SELECT COUNT(*) FROM impersonation_requests r WHERE r.impersonation_id = i.id)
+		FROM impersonations i JOIN users u ON u.id = i.admin_id
+		WHERE ($1 = 0 OR i.user_id = $1)
+		ORDER BY i.created_at DESC LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	impersonations := []Impersonation{}
+	for rows.Next() {
+		var impersonation Impersonation
+		if err := rows.Scan(&impersonation.ID, &impersonation.AdminID, &impersonation.AdminEmail, &impersonation.UserID,
+			&impersonation.Reason, &impersonation.AllowWrite, &impersonation.IP, &impersonation.CreatedAt,
+			&impersonation.ExpiresAt, &impersonation.EndedAt, &impersonation.Requests); err != nil {
+			return nil, err
+		}
+		impersonations = append(impersonations, impersonation)
+	}
+	return impersonations, rows.Err()
+}
+
+// GetImpersonationRequests returns the requests made during an impersonation, oldest first
+func GetImpersonationRequests(ctx context.Context, dbName, impersonationID string, limit, offset int) ([]ImpersonationRequest, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, `SELECT id, service, COALESCE(method, ''), COALESCE(path, ''), allowed, created_at
+		FROM impersonation_requests WHERE impersonation_id = $1
+		ORDER BY created_at, id LIMIT $2 OFFSET $3`, impersonationID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []ImpersonationRequest{}
+	for rows.Next() {
+		var request ImpersonationRequest
+		if err := rows.Scan(&request.ID, &request.Service, &request.Method, &request.Path, &request.Allowed, &request.CreatedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
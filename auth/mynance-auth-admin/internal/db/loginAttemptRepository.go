@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/loginguard"
+)
+
+// LoginStore keeps the login attempts and lockouts of loginguard in the login_attempts and login_lockouts of a database
+type LoginStore struct {
+	DBName string
+}
+
+// LockoutRemaining returns how long logins for the email stay blocked; zero means not blocked
+func (s LoginStore) LockoutRemaining(ctx context.Context, email string) (time.Duration, error) {
+	db := GetDB(s.DBName)
+
+	var seconds float64
+	err := db.QueryRowContext(ctx, "SELECT EXTRACT(EPOCH FROM locked_until - NOW()) FROM login_lockouts WHERE email = $1 AND locked_until > NOW()", email).Scan(&seconds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// RecentIPFailures counts failed logins from an IP in the last window
+func (s LoginStore) RecentIPFailures(ctx context.Context, ip string, window time.Duration) (int, error) {
+	db := GetDB(s.DBName)
+
+	var failures int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM login_attempts
+		WHERE ip = $1 AND NOT success AND created_at > NOW() - $2 * INTERVAL '1 second'`, ip, int(window.Seconds())).Scan(&failures)
+	return failures, err
+}
+
+// RecordFailure audits a failed login and locks the email as the policy decides.
+// It returns the lockout applied by this failure, if any.
+func (s LoginStore) RecordFailure(ctx context.Context, attempt loginguard.Attempt, policy loginguard.Policy) (time.Duration, error) {
+	db := GetDB(s.DBName)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO login_attempts (email, user_id, ip, user_agent, success) VALUES ($1, $2, $3, $4, FALSE)",
+		attempt.Email, attempt.UserID, attempt.IP, attempt.UserAgent); err != nil {
+		return 0, err
+	}
+
+	lockout, err := recordLockout(ctx, tx, attempt.Email, policy)
+	if err != nil {
+		return 0, err
+	}
+	return lockout, tx.Commit()
+}
+
+// RecordSuccess audits a successful login and clears the failures of the email
+func (s LoginStore) RecordSuccess(ctx context.Context, attempt loginguard.Attempt) error {
+	db := GetDB(s.DBName)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO login_attempts (email, user_id, ip, user_agent, success) VALUES ($1, $2, $3, $4, TRUE)",
+		attempt.Email, attempt.UserID, attempt.IP, attempt.UserAgent); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM login_lockouts WHERE email = $1", attempt.Email); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordLockout counts one more failure of the email and stores the lockout the policy gives it
+func recordLockout(ctx context.Context, tx *sql.Tx, email string, policy loginguard.Policy) (time.Duration, error) {
+	// Garante a linha para travá-la: falhas simultâneas do mesmo e-mail contam uma depois da outra
+	if _, err := tx.ExecContext(ctx, "INSERT INTO login_lockouts (email, failed_count) VALUES ($1, 0) ON CONFLICT (email) DO NOTHING", email); err != nil {
+		return 0, err
+	}
+
+	var previous int
+	var sinceLast float64
+	err := tx.QueryRowContext(ctx, "SELECT failed_count, EXTRACT(EPOCH FROM NOW() - last_failure_at) FROM login_lockouts WHERE email = $1 FOR UPDATE",
+		email).Scan(&previous, &sinceLast)
+	if err != nil {
+		return 0, err
+	}
+
+	failures := policy.Failures(previous, time.Duration(sinceLast*float64(time.Second)))
+	lockout := policy.Lockout(failures)
+	_, err = tx.ExecContext(ctx, `UPDATE login_lockouts SET failed_count = $2, last_failure_at = NOW(),
+		locked_until = CASE WHEN $3 > 0 THEN NOW() + $3 * INTERVAL '1 second' ELSE locked_until END
+		WHERE email = $1`, email, failures, int(lockout.Seconds()))
+	return lockout, err
+}
+
+// UnlockLogin clears the lockout and the failure count of an email; it returns false if nothing was locked
+func UnlockLogin(ctx context.Context, tx *sql.Tx, email string) (bool, error) {
+	result, err := tx.ExecContext(ctx, "DELETE FROM login_lockouts WHERE email = $1", email)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const oauthClientColumns = `id, client_id, name, secret_hash IS NOT NULL, redirect_uris, scopes, first_party, created_at, updated_at, revoked_at
+	FROM oauth_clients`
+
+// CreateOAuthClient registers an OAuth client; secretHash is empty for public clients
+func CreateOAuthClient(ctx context.Context, tx *sql.Tx, client OAuthClient, secretHash string, createdBy int) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `INSERT INTO oauth_clients (client_id, name, secret_hash, redirect_uris, scopes, first_party, created_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, 0)) RETURNING id`,
+		client.ClientID, client.Name, secretHash, pq.Array(client.RedirectURIs), pq.Array(client.Scopes), client.FirstParty, createdBy).Scan(&id)
+	return id, err
+}
+
+// GetOAuthClients lists every OAuth client, revoked ones included
+func GetOAuthClients(ctx context.Context, dbName string) ([]OAuthClient, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, "SELECT "+oauthClientColumns+" ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+	return clients, rows.Err()
+}
+
+// GetOAuthClient returns an OAuth client, or nil if there is none with this id
+func GetOAuthClient(ctx context.Context, dbName string, id int) (*OAuthClient, error) {
+	db := GetDB(dbName)
+
+	client, err := scanOAuthClient(db.QueryRowContext(ctx, "SELECT "+oauthClientColumns+" WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// UpdateOAuthClient replaces the name, redirect URIs, scopes and consent setting of an OAuth client
+func UpdateOAuthClient(ctx context.Context, tx *sql.Tx, client OAuthClient) error {
+	_, err := tx.ExecContext(ctx, `UPDATE oauth_clients SET name = $2, redirect_uris = $3, scopes = $4, first_party = $5, updated_at = NOW()
+		WHERE id = $1`, client.ID, client.Name, pq.Array(client.RedirectURIs), pq.Array(client.Scopes), client.FirstParty)
+	return err
+}
+
+// RotateOAuthClientSecret replaces the secret of a confidential client; the old one stops working right away
+func RotateOAuthClientSecret(ctx context.Context, tx *sql.Tx, id int, secretHash string) error {
+	_, err := tx.ExecContext(ctx, "UPDATE oauth_clients SET secret_hash = $2, updated_at = NOW() WHERE id = $1 AND secret_hash IS NOT NULL", id, secretHash)
+	return err
+}
+
+// RevokeOAuthClient disables an OAuth client; mynance-auth refuses its authorization and token requests from then on
+func RevokeOAuthClient(ctx context.Context, tx *sql.Tx, id int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE oauth_clients SET revoked_at = NOW(), updated_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id)
+	return err
+}
+
+func scanOAuthClient(row rowScanner) (*OAuthClient, error) {
+	var client OAuthClient
+	err := row.Scan(&client.ID, &client.ClientID, &client.Name, &client.Confidential, pq.Array(&client.RedirectURIs), pq.Array(&client.Scopes),
+		&client.FirstParty, &client.CreatedAt, &client.UpdatedAt, &client.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
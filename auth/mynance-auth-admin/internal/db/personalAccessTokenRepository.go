@@ -0,0 +1,29 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// UsePersonalAccessToken looks up a valid token of an active user and records its use; it returns nil if the token is not valid
+func UsePersonalAccessToken(ctx context.Context, dbName, tokenHash string) (*PersonalAccessToken, error) {
+	db := GetDB(dbName)
+
+	var token PersonalAccessToken
+	err := db.QueryRowContext(ctx, `UPDATE personal_access_tokens t SET last_used_at = NOW()
+		FROM users u
+		WHERE t.token_hash = $1 AND u.id = t.user_id AND u.active
+			AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		RETURNING t.id, t.user_id, u.email, u.role, t.scopes, t.expires_at`, tokenHash).
+		Scan(&token.ID, &token.UserID, &token.Email, &token.Role, pq.Array(&token.Scopes), &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
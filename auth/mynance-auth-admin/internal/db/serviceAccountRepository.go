@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/lib/pq"
+)
+
+// serviceAccountColumns are scanned by scanServiceAccount; accounts created before service_accounts existed have no row there
+const serviceAccountColumns = `u.id, u.name, u.email, COALESCE(s.scopes, '{validate-token}'), COALESCE(u.active, FALSE) AND s.revoked_at IS NULL,
+	u.created_at, s.previous_password_expires_at, s.rotated_at, s.last_used_at, s.revoked_at
+	FROM users u LEFT JOIN service_accounts s ON s.user_id = u.id`
+
+// CreateServiceAccount inserts a new service user with its allowed scopes
+func CreateServiceAccount(ctx context.Context, tx *sql.Tx, name, email, password string, scopes []string) (int, error) {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	var userID int
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		name, email, hashedPassword, "service").Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO service_accounts (user_id, scopes) VALUES ($1, $2)", userID, pq.Array(scopes))
+	return userID, err
+}
+
+// GetServiceAccounts lists every service account, revoked ones included
+func GetServiceAccounts(ctx context.Context, dbName string) ([]ServiceAccount, error) {
+	db := GetDB(dbName)
+
+	rows, err := db.QueryContext(ctx, "SELECT "+serviceAccountColumns+" WHERE u.role = 'service' ORDER BY u.created_at DESC, u.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []ServiceAccount{}
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// GetServiceAccount returns a service account, or nil if there is none with this id
+func GetServiceAccount(ctx context.Context, dbName string, id int) (*ServiceAccount, error) {
+	db := GetDB(dbName)
+
+	account, err := scanServiceAccount(db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" WHERE u.id = $1 AND u.role = 'service'", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// UpdateServiceAccount renames a service account and replaces its scopes
+func UpdateServiceAccount(ctx context.Context, tx *sql.Tx, id int, name string, scopes []string) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2 AND role = 'service'", name, id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO service_accounts (user_id, scopes) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET scopes = EXCLUDED.scopes`, id, pq.Array(scopes))
+	return err
+}
+
+// RotateServiceAccountSecret stores a new credential; the current one keeps working for overlap,
+// and so do the tokens it issued
+func RotateServiceAccountSecret(ctx context.Context, tx *sql.Tx, id int, newHash string, overlap time.Duration) error {
+	var currentHash string
+	err := tx.QueryRowContext(ctx, "SELECT password FROM users WHERE id = $1 AND role = 'service' FOR UPDATE", id).Scan(&currentHash)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", newHash, id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO service_accounts (user_id, previous_password, previous_password_expires_at, rotated_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second', NOW())
+		ON CONFLICT (user_id) DO UPDATE SET previous_password = EXCLUDED.previous_password,
+			previous_password_expires_at = EXCLUDED.previous_password_expires_at, rotated_at = EXCLUDED.rotated_at`,
+		id, currentHash, int(overlap.Seconds()))
+	return err
+}
+
+// RevokeServiceAccount disables a service account; its tokens are refused from the next request on
+func RevokeServiceAccount(ctx context.Context, tx *sql.Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET active = FALSE WHERE id = $1 AND role = 'service'", id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO service_accounts (user_id, revoked_at) VALUES ($1, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET revoked_at = NOW(), previous_password = NULL, previous_password_expires_at = NULL`, id)
+	return err
+}
+
+// ServicePreviousPassword returns the credential replaced by the last rotation while it is still accepted, or "",
+// and when it stops being accepted
+func ServicePreviousPassword(ctx context.Context, dbName string, id int) (string, time.Time, error) {
+	db := GetDB(dbName)
+
+	var hash string
+	var expiresAt time.Time
+	err := db.QueryRowContext(ctx, `SELECT previous_password, previous_password_expires_at FROM service_accounts
+		WHERE user_id = $1 AND previous_password IS NOT NULL AND previous_password_expires_at > NOW() AND revoked_at IS NULL`, id).Scan(&hash, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, nil
+		}
+		return "", time.Time{}, err
+	}
+	return hash, expiresAt, nil
+}
+
+// GetServiceAccountAuth returns the state ServiceAuth checks on every request, or nil if the account does not exist
+func GetServiceAccountAuth(ctx context.Context, dbName string, id int) (*ServiceAccountAuth, error) {
+	db := GetDB(dbName)
+
+	var auth ServiceAccountAuth
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(u.active, FALSE) AND s.revoked_at IS NULL, COALESCE(s.scopes, '{validate-token}'), s.rotated_at,
+			COALESCE(s.previous_password_expires_at <= NOW(), TRUE),
+			s.user_id IS NOT NULL AND (s.last_used_at IS NULL OR s.last_used_at < NOW() - INTERVAL '1 minute')
+		FROM users u LEFT JOIN service_accounts s ON s.user_id = u.id
+		WHERE u.id = $1 AND u.role = 'service'`, id).
+		Scan(&auth.Active, pq.Array(&auth.Scopes), &auth.RotatedAt, &auth.OverlapEnded, &auth.NeedsTouch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// TouchServiceAccount records that the account was just used
+func TouchServiceAccount(ctx context.Context, dbName string, id int) error {
+	db := GetDB(dbName)
+	_, err := db.ExecContext(ctx, "UPDATE service_accounts SET last_used_at = NOW() WHERE user_id = $1", id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanServiceAccount(row rowScanner) (*ServiceAccount, error) {
+	var account ServiceAccount
+	err := row.Scan(&account.ID, &account.Name, &account.Email, pq.Array(&account.Scopes), &account.Active,
+		&account.CreatedAt, &account.PreviousSecretExpiresAt, &account.RotatedAt, &account.LastUsedAt, &account.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
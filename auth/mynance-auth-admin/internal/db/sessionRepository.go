@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SessionActive reports whether a user session still has a usable refresh token
+func SessionActive(ctx context.Context, dbName, familyID string) (bool, error) {
+	db := GetDB(dbName)
+
+	var active bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sessions
+		WHERE family_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW())`, familyID).Scan(&active)
+	return active, err
+}
+
+// RevokeUserSessions signs a user out of every device and revokes the personal access tokens;
+// access tokens already issued stop working too
+func RevokeUserSessions(ctx context.Context, tx *sql.Tx, userID int) (int64, error) {
+	result, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()", userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return 0, err
+	}
+
+	// O validate-token recusa tokens emitidos antes de last_password_change
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET last_password_change = NOW() WHERE id = $1", userID); err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
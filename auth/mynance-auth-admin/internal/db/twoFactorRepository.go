@@ -0,0 +1,19 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ResetTwoFactor removes the TOTP enrollment and recovery codes of a user; it returns false if 2FA was not set up
+func ResetTwoFactor(ctx context.Context, tx *sql.Tx, userID int) (bool, error) {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
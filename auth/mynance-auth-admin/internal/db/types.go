@@ -0,0 +1,160 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type User struct {
+	ID                 int       `json:"id"`
+	Name               string    `json:"name"`
+	Email              string    `json:"email"`
+	Password           string    `json:"password"`
+	Role               string    `json:"role"`
+	LastPasswordChange int64     `json:"lastPasswordChange"`
+	CreatedAt          time.Time `json:"createdAt"`
+	Active             bool      `json:"active"`
+}
+
+type Profile struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	Active    bool      `json:"active"`
+}
+
+// PersonalAccessToken is a user's token as seen by introspection, with its owner
+type PersonalAccessToken struct {
+	ID        int
+	UserID    int
+	Email     string
+	Role      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// UserSummary is a row of the admin user listing
+type UserSummary struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserDetails is a user as seen by an admin, with security information
+type UserDetails struct {
+	UserSummary
+	EmailVerifiedAt    *time.Time `json:"emailVerifiedAt"`
+	LastPasswordChange time.Time  `json:"lastPasswordChange"`
+	TwoFactorEnabled   bool       `json:"twoFactorEnabled"`
+	ActiveSessions     int        `json:"activeSessions"`
+	LastLoginAt        *time.Time `json:"lastLoginAt"`
+}
+
+// UserFilter narrows the admin user listing; empty fields are ignored
+type UserFilter struct {
+	Search string
+	Role   string
+	Status string
+	Active *bool
+}
+
+// AuditEntry is one action taken by an admin
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	AdminID    *int            `json:"adminId"`
+	AdminEmail string          `json:"adminEmail"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	Details    json.RawMessage `json:"details"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// ServiceAccount is a service user with its allowed scopes and credential state
+type ServiceAccount struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Scopes    []string  `json:"scopes"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	// PreviousSecretExpiresAt is when the credential replaced by the last rotation stops working
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt"`
+	RotatedAt               *time.Time `json:"rotatedAt"`
+	LastUsedAt              *time.Time `json:"lastUsedAt"`
+	RevokedAt               *time.Time `json:"revokedAt"`
+}
+
+// ServiceAccountAuth is what ServiceAuth needs to accept a service token
+type ServiceAccountAuth struct {
+	Active    bool
+	Scopes    []string
+	RotatedAt *time.Time
+	// OverlapEnded is true once tokens issued before the last rotation must be refused
+	OverlapEnded bool
+	// NeedsTouch is true when last_used_at is old enough to be updated
+	NeedsTouch bool
+}
+
+// AdminAccount is an admin user as listed to superadmins
+type AdminAccount struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AdminAuth is what AdminAuth checks on every request
+type AdminAuth struct {
+	Role               string
+	Active             bool
+	LastPasswordChange time.Time
+}
+
+// Impersonation is an admin seeing the app as a user, with how many requests were made
+type Impersonation struct {
+	ID         string     `json:"id"`
+	AdminID    int        `json:"adminId"`
+	AdminEmail string     `json:"adminEmail"`
+	UserID     int        `json:"userId"`
+	Reason     string     `json:"reason"`
+	AllowWrite bool       `json:"allowWrite"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	EndedAt    *time.Time `json:"endedAt"`
+	Requests   int        `json:"requests"`
+}
+
+// ImpersonationRequest is one request made with an impersonation token
+type ImpersonationRequest struct {
+	ID        int       `json:"id"`
+	Service   string    `json:"service"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Allowed   bool      `json:"allowed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OAuthClient is an application allowed to sign users in through the OpenID Connect provider of mynance-auth
+type OAuthClient struct {
+	ID           int        `json:"id"`
+	ClientID     string     `json:"clientId"`
+	Name         string     `json:"name"`
+	Confidential bool       `json:"confidential"`
+	RedirectURIs []string   `json:"redirectUris"`
+	Scopes       []string   `json:"scopes"`
+	FirstParty   bool       `json:"firstParty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	RevokedAt    *time.Time `json:"revokedAt"`
+}
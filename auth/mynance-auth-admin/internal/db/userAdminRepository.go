@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// OutboxEmail is queued in the user database and delivered by the mynance-auth outbox worker
+type OutboxEmail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// likeEscaper keeps % and _ typed in the search box from acting as wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+const userFilterWhere = `WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+	AND ($2 = '' OR role = $2)
+	AND ($3 = '' OR status = $3)
+	AND ($4::boolean IS NULL OR active = $4)`
+
+// SearchUsers returns a page of users matching the filter and the total number of matches
+func SearchUsers(ctx context.Context, dbName string, filter UserFilter, limit, offset int) ([]UserSummary, int, error) {
+	db := GetDB(dbName)
+	search := likeEscaper.Replace(strings.TrimSpace(filter.Search))
+
+	var total int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users "+userFilterWhere,
+		search, filter.Role, filter.Status, filter.Active).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT id, name, email, role, status, active, created_at FROM users `+userFilterWhere+`
+		ORDER BY created_at DESC, id DESC LIMIT $5 OFFSET $6`,
+		search, filter.Role, filter.Status, filter.Active, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var user UserSummary
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Status, &user.Active, &user.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// GetUserDetails returns a user with 2FA, session and login information, or nil if it does not exist
+func GetUserDetails(ctx context.Context, dbName string, userID int) (*UserDetails, error) {
+	db := GetDB(dbName)
+
+	var user UserDetails
+	err := db.QueryRowContext(ctx, `SELECT u.id, u.name, u.email, u.role, u.status, u.active, u.created_at,
+			u.email_verified_at, u.last_password_change,
+			EXISTS(SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL),
+			(SELECT COUNT(*) FROM sessions s
+				WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.rotated_at IS NULL AND s.expires_at > NOW()),
+			(SELECT MAX(a.created_at) FROM login_attempts a WHERE a.user_id = u.id AND a.success)
+		FROM users u WHERE u.id = $1`, userID).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Status, &user.Active, &user.CreatedAt,
+			&user.EmailVerifiedAt, &user.LastPasswordChange, &user.TwoFactorEnabled, &user.ActiveSessions, &user.LastLoginAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserRole changes the plan of a user; it returns false if the user does not exist
+func UpdateUserRole(ctx context.Context, tx *sql.Tx, userID int, role string) (bool, error) {
+	result, err := tx.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// ForcePasswordReset replaces the password with an unusable hash, signs the user out everywhere,
+// personal access tokens included, and queues a reset link that stays valid for ttl
+func ForcePasswordReset(ctx context.Context, tx *sql.Tx, userID int, lockedHash, tokenHash string, ttl time.Duration, email OutboxEmail) error {
+	// Tokens emitidos antes da troca deixam de valer
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", lockedHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+
+	// Só o link mais recente continua valendo
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')",
+		userID, tokenHash, int(ttl.Seconds())); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "INSERT INTO email_outbox (recipient, subject, body) VALUES ($1, $2, $3)",
+		email.To, email.Subject, email.Body)
+	return err
+}
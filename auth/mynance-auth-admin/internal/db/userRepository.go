@@ -1,58 +1,43 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jvlerner/mynance-platform/hasher"
 )
 
 // UserExists checks if a user exists by email
-func UserExists(dbName, email string) (bool, error) {
-	db := postgres.GetDB(dbName)
+func UserExists(ctx context.Context, dbName, email string) (bool, error) {
+	db := GetDB(dbName)
 
 	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
 	return exists, err
 }
 
-// CreateServiceAccount inserts a new service user
-func CreateServiceAccount(dbName, name, email, password string) (int, error) {
-	db := postgres.GetDB(dbName)
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return 0, err
-	}
-
-	var userID int
-	err = db.QueryRow("INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id",
-		name, email, string(hashedPassword), "service").Scan(&userID)
-	return userID, err
-}
-
 // CreateUser inserts a new user
-func CreateUser(dbName, name, email, password string) (int, error) {
-	db := postgres.GetDB(dbName)
+func CreateUser(ctx context.Context, dbName, name, email, password string) (int, error) {
+	db := GetDB(dbName)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return 0, err
 	}
 
 	var userID int
-	err = db.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id",
-		name, email, string(hashedPassword)).Scan(&userID)
+	err = db.QueryRowContext(ctx, "INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id",
+		name, email, hashedPassword).Scan(&userID)
 	return userID, err
 }
 
-func UserLastPasswordChange(dbName string, userID int) (time.Time, error) {
-	db := postgres.GetDB(dbName)
+func UserLastPasswordChange(ctx context.Context, dbName string, userID int) (time.Time, error) {
+	db := GetDB(dbName)
 
 	var lastPasswordChange time.Time
-	err := db.QueryRow("SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
+	err := db.QueryRowContext(ctx, "SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return time.Time{}, nil
@@ -63,13 +48,14 @@ func UserLastPasswordChange(dbName string, userID int) (time.Time, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func GetUserByEmail(dbName, email string) (*postgres.User, error) {
-	db := postgres.GetDB(dbName)
+func GetUserByEmail(ctx context.Context, dbName, email string) (*User, error) {
+	db := GetDB(dbName)
 
-	var user postgres.User
-	err := db.QueryRow(`SELECT id, name, email, password, active, created_at, last_password_change, role 
+	var user User
+	var lastPasswordChange time.Time
+	err := db.QueryRowContext(ctx, `SELECT id, name, email, password, active, created_at, last_password_change, role 
 		FROM users WHERE email = $1`, email).
-		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt, &user.LastPasswordChange, &user.Role)
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt, &lastPasswordChange, &user.Role)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -77,15 +63,16 @@ func GetUserByEmail(dbName, email string) (*postgres.User, error) {
 		}
 		return nil, err
 	}
+	user.LastPasswordChange = lastPasswordChange.Unix()
 	return &user, nil
 }
 
 // GetProfileByID retrieves user profile by ID
-func GetProfileByID(dbName string, userID int) (*postgres.Profile, error) {
-	db := postgres.GetDB(dbName)
+func GetProfileByID(ctx context.Context, dbName string, userID int) (*Profile, error) {
+	db := GetDB(dbName)
 
-	var user postgres.Profile
-	err := db.QueryRow("SELECT id, name, email, active, created_at FROM users WHERE id = $1", userID).
+	var user Profile
+	err := db.QueryRowContext(ctx, "SELECT id, name, email, active, created_at FROM users WHERE id = $1", userID).
 		Scan(&user.ID, &user.Name, &user.Email, &user.Active, &user.CreatedAt)
 
 	if err != nil {
@@ -98,36 +85,42 @@ func GetProfileByID(dbName string, userID int) (*postgres.Profile, error) {
 }
 
 // UpdateUser updates user details
-func UpdateUser(dbName string, userID int, name string) error {
-	db := postgres.GetDB(dbName)
-	_, err := db.Exec("UPDATE users SET name = $1 WHERE id = $2", name, userID)
+func UpdateUser(ctx context.Context, dbName string, userID int, name string) error {
+	db := GetDB(dbName)
+	_, err := db.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", name, userID)
 	return err
 }
 
 // UpdateUserPassword updates user password
-func UpdateUserPassword(dbName string, userID int, password string) error {
-	db := postgres.GetDB(dbName)
+func UpdateUserPassword(ctx context.Context, dbName string, userID int, password string) error {
+	db := GetDB(dbName)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec("UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2",
-		string(hashedPassword), userID)
+	_, err = db.ExecContext(ctx, "UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2",
+		hashedPassword, userID)
+	return err
+}
+
+// UpgradePasswordHash replaces the stored hash with a stronger one for the same password.
+// It does not touch last_password_change and does nothing if the password changed meanwhile.
+func UpgradePasswordHash(ctx context.Context, dbName string, userID int, currentHash, newHash string) error {
+	db := GetDB(dbName)
+	_, err := db.ExecContext(ctx, "UPDATE users SET password = $3 WHERE id = $1 AND password = $2", userID, currentHash, newHash)
 	return err
 }
 
 // DeleteUser marks a user as inactive
-func DeleteUser(dbName string, userID int) error {
-	db := postgres.GetDB(dbName)
-	_, err := db.Exec("UPDATE users SET active = FALSE WHERE id = $1", userID)
+func DeleteUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE users SET active = FALSE WHERE id = $1", userID)
 	return err
 }
 
 // RecoverUser reactivates a user account
-func RecoverUser(dbName string, userID int) error {
-	db := postgres.GetDB(dbName)
-	_, err := db.Exec("UPDATE users SET active = TRUE WHERE id = $1", userID)
+func RecoverUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE users SET active = TRUE WHERE id = $1", userID)
 	return err
 }
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// CurrentAdmin returns the logged admin with the permissions of its role, so the panel can hide what it cannot do
+func CurrentAdmin(c *gin.Context) {
+	role := c.GetString("adminRole")
+	c.JSON(http.StatusOK, gin.H{
+		"id":          c.GetInt("adminId"),
+		"email":       c.GetString("adminEmail"),
+		"role":        role,
+		"permissions": rbac.Permissions(role),
+	})
+}
+
+// ListAdmins returns every admin account
+func ListAdmins(c *gin.Context) {
+	admins, err := db.GetAdmins(c.Request.Context(), adminDBName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"admins": admins, "roles": rbac.AdminRoles})
+}
+
+type createAdminInput struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// CreateAdmin adds an admin account with one of the admin roles
+func CreateAdmin(c *gin.Context) {
+	var input createAdminInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.Email = strings.TrimSpace(input.Email)
+	if input.Name == "" || input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and email are required"})
+		return
+	}
+	if !rbac.ValidAdminRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role", "roles": rbac.AdminRoles})
+		return
+	}
+
+	exists, err := db.UserExists(c.Request.Context(), adminDBName, input.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	}
+
+	if !checkPassword(c, input.Password, input.Email, input.Name) {
+		return
+	}
+
+	var adminID int
+	err = audited(c, adminDBName, auditAdminCreate, auditTargetAdmin, func(tx *sql.Tx) (string, gin.H, error) {
+		var err error
+		adminID, err = db.CreateAdmin(c.Request.Context(), tx, input.Name, input.Email, input.Password, input.Role)
+		return strconv.Itoa(adminID), gin.H{"email": input.Email, "role": input.Role}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin"})
+		logger.Ctx(c.Request.Context()).Error("Failed to create admin", zap.String("email", input.Email), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Admin created", zap.Int("adminID", adminID), zap.String("role", input.Role), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusCreated, gin.H{"message": "Admin created successfully", "id": adminID})
+}
+
+// ChangeAdminRole gives an admin another role; the last active superadmin cannot be demoted
+func ChangeAdminRole(c *gin.Context) {
+	adminID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid admin id"})
+		return
+	}
+
+	var input changeRoleInput
+	if err := c.BindJSON(&input); err != nil || !rbac.ValidAdminRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role", "roles": rbac.AdminRoles})
+		return
+	}
+
+	admin, err := db.GetAdmin(c.Request.Context(), adminDBName, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if admin == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin not found"})
+		return
+	}
+	if admin.Role == input.Role {
+		c.JSON(http.StatusOK, gin.H{"message": "Admin already has this role"})
+		return
+	}
+
+	err = audited(c, adminDBName, auditAdminRoleChange, auditTargetAdmin, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.UpdateAdminRole(c.Request.Context(), tx, adminID, input.Role)
+		return strconv.Itoa(adminID), gin.H{"from": admin.Role, "to": input.Role}, err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrLastSuperadmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot demote the last active superadmin"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		logger.Ctx(c.Request.Context()).Error("Failed to change admin role", zap.Int("adminID", adminID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Admin role changed", zap.Int("adminID", adminID), zap.String("role", input.Role), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+)
+
+// Audit actions, stored in admin_audit_log.action
+const (
+	auditUserDeactivate       = "user.deactivate"
+	auditUserReactivate       = "user.reactivate"
+	auditUserRoleChange       = "user.role_change"
+	auditUserPasswordReset    = "user.password_reset"
+	auditUserSessionsRevoke   = "user.sessions_revoke"
+	auditUserTwoFactorReset   = "user.2fa_reset"
+	auditUserImpersonate      = "user.impersonate"
+	auditImpersonationEnd     = "impersonation.end"
+	auditLoginUnlock          = "login.unlock"
+	auditServiceAccountCreate = "service_account.create"
+	auditServiceAccountUpdate = "service_account.update"
+	auditServiceAccountRotate = "service_account.rotate"
+	auditServiceAccountRevoke = "service_account.revoke"
+	auditOAuthClientCreate    = "oauth_client.create"
+	auditOAuthClientUpdate    = "oauth_client.update"
+	auditOAuthClientRotate    = "oauth_client.rotate"
+	auditOAuthClientRevoke    = "oauth_client.revoke"
+	auditAdminCreate          = "admin.create"
+	auditAdminRoleChange      = "admin.role_change"
+)
+
+// Audit target types
+const (
+	auditTargetUser           = "user"
+	auditTargetLogin          = "login"
+	auditTargetServiceAccount = "service_account"
+	auditTargetAdmin          = "admin"
+	auditTargetImpersonation  = "impersonation"
+	auditTargetOAuthClient    = "oauth_client"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// errNoChange rolls an audited action back without a row when there turned out to be nothing to do
+var errNoChange = errors.New("nothing to change")
+
+// audited runs an action of the logged admin in a transaction on dbName and records it in admin_audit_log.
+// run returns the target id and details of the row; if the row cannot be written the action is rolled back.
+func audited(c *gin.Context, dbName, action, targetType string, run func(tx *sql.Tx) (string, gin.H, error)) error {
+	entry := db.AuditEntry{
+		AdminEmail: c.GetString("adminEmail"),
+		Action:     action,
+		TargetType: targetType,
+		IP:         c.ClientIP(),
+	}
+	if adminID := c.GetInt("adminId"); adminID > 0 {
+		entry.AdminID = &adminID
+	}
+
+	return db.Audited(c.Request.Context(), dbName, adminDBName, &entry, func(tx *sql.Tx) error {
+		targetID, details, err := run(tx)
+		if err != nil {
+			return err
+		}
+		entry.TargetID = targetID
+		if details != nil {
+			entry.Details, err = json.Marshal(details)
+		}
+		return err
+	})
+}
+
+// ListAuditLog returns the latest admin actions, filtered by ?targetType and ?targetId
+func ListAuditLog(c *gin.Context) {
+	limit, offset, ok := pagination(c, defaultAuditLimit, maxAuditLimit)
+	if !ok {
+		return
+	}
+
+	entries, err := db.GetAuditLog(c.Request.Context(), adminDBName, c.Query("targetType"), c.Query("targetId"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// pagination reads ?page (from 1) and ?pageSize, answering 400 when they are invalid
+func pagination(c *gin.Context, defaultSize, maxSize int) (int, int, bool) {
+	page, size := 1, defaultSize
+
+	if value := c.Query("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return 0, 0, false
+		}
+		page = parsed
+	}
+	if value := c.Query("pageSize"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pageSize must be between 1 and " + strconv.Itoa(maxSize)})
+			return 0, 0, false
+		}
+		size = parsed
+	}
+
+	return size, (page - 1) * size, true
+}
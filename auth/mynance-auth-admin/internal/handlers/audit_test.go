@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// separateDatabases gives db-auth and db-auth-admin different names, as in production, so audited goes across them
+func separateDatabases(t *testing.T) (users, admin sqlmock.Sqlmock) {
+	t.Helper()
+
+	previousUser, previousAdmin := userDBName, adminDBName
+	userDBName, adminDBName = "db-auth", "db-auth-admin"
+	t.Cleanup(func() { userDBName, adminDBName = previousUser, previousAdmin })
+	return mockDB(t, userDBName), mockDB(t, adminDBName)
+}
+
+func adminContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/admin/users/7/deactivate", nil)
+	c.Request.RemoteAddr = "203.0.113.1:1234"
+	c.Set("adminId", testAdminID)
+	c.Set("adminEmail", testAdminEmail)
+	return c
+}
+
+func TestAuditedRecordsTheAdminAcrossDatabases(t *testing.T) {
+	users, admin := separateDatabases(t)
+
+	users.ExpectBegin()
+	users.ExpectExec("UPDATE users SET active = FALSE").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	admin.ExpectQuery("INSERT INTO admin_audit_log").
+		WithArgs(testAdminID, testAdminEmail, auditUserDeactivate, auditTargetUser, "7", `{"email":"ana@example.com"}`, "203.0.113.1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	users.ExpectCommit()
+
+	c := adminContext()
+	err := audited(c, userDBName, auditUserDeactivate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		_, err := tx.ExecContext(c.Request.Context(), "UPDATE users SET active = FALSE WHERE id = $1", 7)
+		return "7", gin.H{"email": "ana@example.com"}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{users, admin} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditedRemovesTheRowWhenTheActionIsNotCommitted(t *testing.T) {
+	users, admin := separateDatabases(t)
+	errCommit := errors.New("connection reset")
+
+	users.ExpectBegin()
+	users.ExpectExec("UPDATE users SET active = FALSE").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	admin.ExpectQuery("INSERT INTO admin_audit_log").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	users.ExpectCommit().WillReturnError(errCommit)
+	admin.ExpectExec("DELETE FROM admin_audit_log WHERE id = \\$1").WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	c := adminContext()
+	err := audited(c, userDBName, auditUserDeactivate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		_, err := tx.ExecContext(c.Request.Context(), "UPDATE users SET active = FALSE WHERE id = $1", 7)
+		return "7", nil, err
+	})
+	if !errors.Is(err, errCommit) {
+		t.Fatalf("audited = %v, want %v", err, errCommit)
+	}
+	for _, mock := range []sqlmock.Sqlmock{users, admin} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditedWritesNoRowForNoChange(t *testing.T) {
+	users, admin := separateDatabases(t)
+
+	users.ExpectBegin()
+	users.ExpectRollback()
+
+	err := audited(adminContext(), userDBName, auditUserDeactivate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		return "7", nil, errNoChange
+	})
+	if !errors.Is(err, errNoChange) {
+		t.Fatalf("audited = %v, want %v", err, errNoChange)
+	}
+	// O sqlmock do db-auth-admin falharia com qualquer INSERT
+	for _, mock := range []sqlmock.Sqlmock{users, admin} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+
+	defaultImpersonationPageSize = 50
+	maxImpersonationPageSize     = 200
+
+	// Limites das colunas de impersonation_requests
+	maxRequestMethodLength = 10
+	maxRequestPathLength   = 2048
+)
+
+type impersonateInput struct {
+	// Reason is required and stored with the impersonation, e.g. the support ticket
+	Reason     string `json:"reason"`
+	AllowWrite bool   `json:"allowWrite"`
+	// Minutes defaults to 15, at most 60
+	Minutes int `json:"minutes"`
+}
+
+// ImpersonateUser mints a short-lived token to see the app as a user; it is read-only unless allowWrite is requested
+func ImpersonateUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input impersonateInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to impersonate a user"})
+		return
+	}
+	ttl := defaultImpersonationTTL
+	if input.Minutes != 0 {
+		ttl = time.Duration(input.Minutes) * time.Minute
+		if ttl < time.Minute || ttl > maxImpersonationTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "minutes must be between 1 and " + strconv.Itoa(int(maxImpersonationTTL.Minutes()))})
+			return
+		}
+	}
+	if input.AllowWrite && !rbac.Allowed(c.GetString("adminRole"), rbac.PermUsersImpersonateWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + string(rbac.PermUsersImpersonateWrite)})
+		return
+	}
+
+	user, err := db.GetUserDetails(c.Request.Context(), userDBName, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is inactive"})
+		return
+	}
+
+	adminID := c.GetInt("adminId")
+	tokenClaims, err := claims.New(claims.TypeImpersonation, claims.IssuerAdmin, claims.UserAudience(), user.ID, user.Email, user.Role, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	tokenClaims.Actor = &claims.Actor{Subject: strconv.Itoa(adminID), Email: c.GetString("adminEmail")}
+	tokenClaims.AllowWrite = input.AllowWrite
+
+	// Registro antes do token: sem a linha no banco o validate-token recusaria o token de qualquer forma
+	err = audited(c, adminDBName, auditUserImpersonate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.CreateImpersonation(c.Request.Context(), tx, db.Impersonation{
+			ID:         tokenClaims.ID,
+			AdminID:    adminID,
+			UserID:     user.ID,
+			Reason:     input.Reason,
+			AllowWrite: input.AllowWrite,
+			IP:         c.ClientIP(),
+		}, ttl)
+		return strconv.Itoa(user.ID), gin.H{
+			"impersonationId": tokenClaims.ID,
+			"reason":          input.Reason,
+			"allowWrite":      input.AllowWrite,
+			"minutes":         int(ttl.Minutes()),
+		}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		logger.Ctx(c.Request.Context()).Error("Failed to store impersonation", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+
+	token, err := jwks.Keys.Sign(tokenClaims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Warn("User impersonation started", zap.Int("userID", user.ID), zap.String("impersonationID", tokenClaims.ID),
+		zap.Bool("allowWrite", input.AllowWrite), zap.String("admin", c.GetString("adminEmail")))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":           token,
+		"impersonationId": tokenClaims.ID,
+		"expiresAt":       tokenClaims.ExpiresAt,
+		"allowWrite":      input.AllowWrite,
+	})
+}
+
+// EndImpersonation revokes an impersonation token before it expires
+func EndImpersonation(c *gin.Context) {
+	id := c.Param("id")
+
+	err := audited(c, adminDBName, auditImpersonationEnd, auditTargetImpersonation, func(tx *sql.Tx) (string, gin.H, error) {
+		ended, err := db.EndImpersonation(c.Request.Context(), tx, id)
+		if err == nil && !ended {
+			err = errNoChange
+		}
+		return id, nil, err
+	})
+	if errors.Is(err, errNoChange) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No running impersonation with this id"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User impersonation ended", zap.String("impersonationID", id), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended successfully"})
+}
+
+// ListImpersonations returns the latest impersonations, filtered by ?userId
+func ListImpersonations(c *gin.Context) {
+	var userID int
+	if value := c.Query("userId"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+			return
+		}
+		userID = parsed
+	}
+
+	limit, offset, ok := pagination(c, defaultImpersonationPageSize, maxImpersonationPageSize)
+	if !ok {
+		return
+	}
+
+	impersonations, err := db.GetImpersonations(c.Request.Context(), adminDBName, userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"impersonations": impersonations})
+}
+
+// ListImpersonationRequests returns every request made during an impersonation
+func ListImpersonationRequests(c *gin.Context) {
+	limit, offset, ok := pagination(c, defaultImpersonationPageSize, maxImpersonationPageSize)
+	if !ok {
+		return
+	}
+
+	requests, err := db.GetImpersonationRequests(c.Request.Context(), adminDBName, c.Param("id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// isImpersonationToken peeks at the typ claim; the signature is checked afterwards with this service's keys
+func isImpersonationToken(token string) bool {
+	tokenClaims := &Claims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, tokenClaims); err != nil {
+		return false
+	}
+	return tokenClaims.Type == claims.TypeImpersonation
+}
+
+// inspectImpersonationToken accepts impersonation tokens signed here whose impersonation is still running
+func inspectImpersonationToken(ctx context.Context, token string) (*tokenInfo, error) {
+	tokenClaims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, tokenClaims, jwks.Keys.Keyfunc)
+	if err != nil || !parsed.Valid {
+		return nil, errTokenInvalid
+	}
+	if err := tokenClaims.Expect(claims.TypeImpersonation, claims.IssuerAdmin, claims.AudienceAuthAdmin); err != nil || tokenClaims.Actor == nil {
+		return nil, errTokenInvalid
+	}
+
+	active, err := db.ImpersonationActive(ctx, adminDBName, tokenClaims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errSessionRevoked
+	}
+
+	// O usuário pode ter sido desativado durante a personificação
+	user, err := db.GetProfileByID(ctx, userDBName, tokenClaims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Active {
+		return nil, errTokenInvalid
+	}
+
+	return &tokenInfo{
+		UserID:     tokenClaims.UserID,
+		Email:      tokenClaims.Email,
+		Role:       tokenClaims.Role,
+		TokenType:  claims.TypeImpersonation,
+		ExpiresAt:  tokenClaims.ExpiresAt,
+		IssuedAt:   tokenClaims.IssuedAt,
+		Issuer:     tokenClaims.Issuer,
+		Audience:   tokenClaims.Audience,
+		ID:         tokenClaims.ID,
+		Actor:      tokenClaims.Actor,
+		AllowWrite: tokenClaims.AllowWrite,
+	}, nil
+}
+
+// reportedRequest reads the request a service is about to serve, which it sends in X-Request-Method and X-Request-Path
+func reportedRequest(c *gin.Context) db.ImpersonationRequest {
+	return db.ImpersonationRequest{
+		Service: c.GetString("serviceName"),
+		Method:  truncate(strings.ToUpper(c.GetHeader("X-Request-Method")), maxRequestMethodLength),
+		Path:    truncate(c.GetHeader("X-Request-Path"), maxRequestPathLength),
+	}
+}
+
+// recordImpersonatedRequest logs the request a service is about to serve with an impersonation token
+func recordImpersonatedRequest(ctx context.Context, info *tokenInfo, request db.ImpersonationRequest) error {
+	// Mesma regra dos microsserviços: sem allowWrite só GET e HEAD passam
+	request.Allowed = info.AllowWrite || request.Method == http.MethodGet || request.Method == http.MethodHead
+	if err := db.RecordImpersonationRequest(ctx, adminDBName, info.ID, request); err != nil {
+		return errors.New("failed to record impersonated request: " + err.Error())
+	}
+	return nil
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
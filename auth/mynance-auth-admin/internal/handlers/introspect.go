@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBatchTokens = 100
+	// batchWorkers bounds the database queries a single batch runs at the same time
+	batchWorkers = 8
+)
+
+// introspectionMaxAge caps how long callers may reuse a positive answer; it bounds how late a revocation is noticed.
+// It is read on first use, after config.LoadEnv.
+var (
+	introspectionMaxAge     time.Duration
+	introspectionMaxAgeOnce sync.Once
+)
+
+// introspection is the RFC 7662 response; only "active" is sent for refused tokens
+type introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	// Extensões do Mynance
+	UserID int    `json:"user_id,omitempty"`
+	Role   string `json:"role,omitempty"`
+	// Act identifies the admin behind an impersonation token (RFC 8693)
+	Act        *claims.Actor `json:"act,omitempty"`
+	AllowWrite bool          `json:"allow_write,omitempty"`
+	// CacheMaxAge is only set in batch results, single responses use Cache-Control
+	CacheMaxAge *int `json:"cache_max_age,omitempty"`
+	// Error is only set in batch results, on a token that could not be checked: it is not known to be
+	// inactive and should be introspected again
+	Error string `json:"error,omitempty"`
+}
+
+// IntrospectToken implements RFC 7662: the token comes form-encoded in "token", token_type_hint is accepted and ignored
+func IntrospectToken(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "The token parameter is required"})
+		return
+	}
+
+	result, maxAge, err := introspectOne(c.Request.Context(), token, reportedRequest(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to introspect token", zap.String("service", c.GetString("serviceName")), zap.Error(err))
+		return
+	}
+
+	setCacheHint(c, maxAge)
+	c.JSON(http.StatusOK, result)
+}
+
+type batchIntrospectionInput struct {
+	Tokens []string `json:"tokens"`
+}
+
+// IntrospectTokens validates up to maxBatchTokens tokens in one round trip; results keep the order of the request.
+// A token that could not be checked gets "error": "server_error" in its result instead of failing the batch.
+func IntrospectTokens(c *gin.Context) {
+	var input batchIntrospectionInput
+	if err := c.BindJSON(&input); err != nil || len(input.Tokens) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "tokens must be a non-empty list"})
+		return
+	}
+	if len(input.Tokens) > maxBatchTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "At most " + strconv.Itoa(maxBatchTokens) + " tokens per batch"})
+		return
+	}
+
+	// Tokens repetidos são consultados uma vez só
+	var unique []string
+	seen := map[string]bool{}
+	for _, token := range input.Tokens {
+		if !seen[token] {
+			seen[token] = true
+			unique = append(unique, token)
+		}
+	}
+
+	// Os workers não tocam no gin.Context, que não é seguro entre goroutines
+	ctx := c.Request.Context()
+	request := reportedRequest(c)
+	service := c.GetString("serviceName")
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	answers := make(map[string]*introspection, len(unique))
+	slots := make(chan struct{}, batchWorkers)
+	for _, token := range unique {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(token string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, maxAge, err := introspectOne(ctx, token, request)
+			if err != nil {
+				// Uma falha responde só pelo próprio token; os demais resultados continuam valendo
+				logger.Ctx(ctx).Error("Failed to introspect token in batch", zap.String("service", service), zap.Error(err))
+				result = &introspection{Error: "server_error"}
+			} else if result.Active {
+				result.CacheMaxAge = &maxAge
+			}
+
+			mu.Lock()
+			answers[token] = result
+			mu.Unlock()
+		}(token)
+	}
+	wg.Wait()
+
+	results := make([]*introspection, len(input.Tokens))
+	for i, token := range input.Tokens {
+		results[i] = answers[token]
+	}
+
+	// Cada item traz o próprio cache_max_age; a resposta inteira não deve ser reaproveitada
+	setCacheHint(c, 0)
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// introspectOne returns the RFC 7662 answer for a token and for how many seconds it may be reused;
+// request is recorded when the token is an impersonation token
+func introspectOne(ctx context.Context, token string, request db.ImpersonationRequest) (*introspection, int, error) {
+	info, err := inspectToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, errTokenInvalid) || errors.Is(err, errSessionRevoked) {
+			return &introspection{Active: false}, 0, nil
+		}
+		return nil, 0, err
+	}
+	if info.TokenType == claims.TypeImpersonation {
+		if err := recordImpersonatedRequest(ctx, info, request); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return &introspection{
+		Active:     true,
+		Scope:      strings.Join(info.Scopes, " "),
+		Username:   info.Email,
+		TokenType:  info.TokenType,
+		Exp:        info.ExpiresAt,
+		Iat:        info.IssuedAt,
+		Sub:        strconv.Itoa(info.UserID),
+		Aud:        info.Audience,
+		Iss:        info.Issuer,
+		Jti:        info.ID,
+		ClientID:   info.ClientID,
+		UserID:     info.UserID,
+		Role:       info.Role,
+		Act:        info.Actor,
+		AllowWrite: info.AllowWrite,
+	}, cacheMaxAge(info), nil
+}
+
+// cacheMaxAge is introspectionMaxAge, shortened so a cached answer never outlives the token
+func cacheMaxAge(info *tokenInfo) int {
+	// Cada uso de um token de personificação precisa passar por aqui para ser registrado
+	if info.TokenType == claims.TypeImpersonation {
+		return 0
+	}
+
+	introspectionMaxAgeOnce.Do(func() {
+		introspectionMaxAge = durationFromEnv("INTROSPECTION_CACHE_TTL", 30*time.Second)
+	})
+
+	maxAge := introspectionMaxAge
+	// expiresAt 0: token de acesso pessoal sem expiração
+	if info.ExpiresAt > 0 {
+		if untilExpiry := time.Until(time.Unix(info.ExpiresAt, 0)); untilExpiry < maxAge {
+			maxAge = untilExpiry
+		}
+	}
+	if maxAge <= 0 {
+		return 0
+	}
+	return int(maxAge.Seconds())
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
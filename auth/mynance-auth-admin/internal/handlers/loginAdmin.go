@@ -1,30 +1,33 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func LoginAdmin(c *gin.Context) {
-	var user postgres.User
+	var user db.User
 	var tokenExpiration time.Duration = 12
 
 	// Verifica se há um token válido no cookie
 	tokenCookie, err := c.Request.Cookie("token-admin")
 	if err == nil {
 		// Valida o token existente
-		claims, err := ValidateToken(tokenCookie.Value)
+		adminClaims, err := validateAdminToken(c.Request.Context(), tokenCookie.Value)
 		if err == nil {
-			logger.Log.Info("Admin already has a valid token", zap.Int("userID", claims.UserID))
+			logger.Ctx(c.Request.Context()).Info("Admin already has a valid token", zap.Int("userID", adminClaims.UserID))
 			// Atualiza o token e o cookie
-			newToken, _, err := GenerateToken(claims.UserID, claims.Email, claims.Role)
+			newToken, _, err := GenerateToken(c.Request.Context(), claims.TypeAdmin, adminClaims.UserID, adminClaims.Email, adminClaims.Role)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
 				return
@@ -52,29 +55,33 @@ func LoginAdmin(c *gin.Context) {
 		return
 	}
 
-	exists, err := db.UserExists(adminDBName, user.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	if !loginGuard.Allowed(c, user.Email) {
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+
+	storedUser, err := db.GetUserByEmail(c.Request.Context(), adminDBName, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	storedUser, err := db.GetUserByEmail(adminDBName, user.Email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if !passwordMatches(c.Request.Context(), storedUser, user.Password) {
+		var userID *int
+		if storedUser != nil {
+			userID = &storedUser.ID
+		}
+		loginGuard.Failed(c, user.Email, userID)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	// Contas de serviço usam /auth/service/login e nunca recebem token de admin
+	if storedUser.Role == "service" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not an admin account"})
 		return
 	}
 
 	// Gerar novo token
-	token, _, err := GenerateToken(storedUser.ID, storedUser.Email, storedUser.Role)
+	token, _, err := GenerateToken(c.Request.Context(), claims.TypeAdmin, storedUser.ID, storedUser.Email, storedUser.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -91,6 +98,31 @@ func LoginAdmin(c *gin.Context) {
 		Path:     "/",
 	})
 
-	logger.Log.Info("Admin logged in", zap.Int("userID", storedUser.ID), zap.String("userEmail", storedUser.Email), zap.String("userName", storedUser.Name))
+	loginGuard.Succeeded(c, storedUser.Email, storedUser.ID, "")
+
+	logger.Ctx(c.Request.Context()).Info("Admin logged in", zap.Int("userID", storedUser.ID), zap.String("userEmail", storedUser.Email), zap.String("userName", storedUser.Name))
 	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
 }
+
+// validateAdminToken verifies a token signed by this service for an admin account
+func validateAdminToken(ctx context.Context, tokenString string) (*Claims, error) {
+	tokenClaims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, tokenClaims, jwks.Keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := tokenClaims.Expect(claims.TypeAdmin, claims.IssuerAdmin, claims.AudienceAuthAdmin); err != nil {
+		return nil, err
+	}
+
+	lastPasswordChange, err := db.UserLastPasswordChange(ctx, adminDBName, tokenClaims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tokenClaims.LastPasswordChange < lastPasswordChange.Unix() {
+		return nil, errors.New("token invalid due to password change")
+	}
+
+	return tokenClaims, nil
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
 )
 
 type LoginInput struct {
@@ -20,41 +25,76 @@ func LoginService(c *gin.Context) {
 		return
 	}
 
-	exists, err := db.UserExists(adminDBName, input.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	if !loginGuard.Allowed(c, input.Email) {
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+
+	user, err := db.GetUserByEmail(c.Request.Context(), adminDBName, input.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	user, err := db.GetUserByEmail(adminDBName, input.Email)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	matched := passwordMatches(c.Request.Context(), user, input.Password)
+	// Token obtido com a credencial anterior não pode durar além da transição da rotação
+	var notAfter time.Time
+	if !matched {
+		matched, notAfter = previousSecretMatches(c.Request.Context(), user, input.Password)
+	}
+	if !matched {
+		var userID *int
+		if user != nil {
+			userID = &user.ID
+		}
+		loginGuard.Failed(c, input.Email, userID)
 		return
 	}
 
-	// Verifica se é service
+	// Verifica se é service, só depois da senha para não revelar o tipo da conta
 	if user.Role != "service" {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not a service account"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if !user.Active {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Service account revoked"})
 		return
 	}
 
-	token, exp, err := GenerateToken(user.ID, user.Email, user.Role)
+	token, exp, err := GenerateTokenUntil(c.Request.Context(), claims.TypeService, user.ID, user.Email, user.Role, notAfter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
+	loginGuard.Succeeded(c, user.Email, user.ID, "")
+
 	c.JSON(http.StatusOK, gin.H{
 		"token":     token,
 		"expiresAt": exp,
 	})
 }
+
+// previousSecretMatches accepts the secret replaced by the last rotation while its overlap lasts
+// and returns when the overlap ends
+func previousSecretMatches(ctx context.Context, user *db.User, secret string) (bool, time.Time) {
+	if user == nil || user.Role != "service" {
+		return false, time.Time{}
+	}
+
+	previous, expiresAt, err := db.ServicePreviousPassword(ctx, adminDBName, user.ID)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to load previous service secret", zap.Int("serviceID", user.ID), zap.Error(err))
+		return false, time.Time{}
+	}
+	if previous == "" {
+		return false, time.Time{}
+	}
+
+	ok, _, err := hasher.Verify(secret, previous)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to verify previous service secret", zap.Int("serviceID", user.ID), zap.Error(err))
+		return false, time.Time{}
+	}
+	return ok, expiresAt
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const maxRedirectURIs = 10
+
+type createOAuthClientInput struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirectUris"`
+	// Scopes defaults to openid only
+	Scopes     []string `json:"scopes"`
+	FirstParty bool     `json:"firstParty"`
+	// Public clients (mobile app, SPA) get no secret and rely on PKCE alone
+	Public bool `json:"public"`
+}
+
+// CreateOAuthClient registers an application that signs users in through mynance-auth; the secret is only shown in this response
+func CreateOAuthClient(c *gin.Context) {
+	var input createOAuthClientInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	client := db.OAuthClient{Name: strings.TrimSpace(input.Name), FirstParty: input.FirstParty, Confidential: !input.Public}
+	if client.Name == "" || len(client.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required and must have at most 100 characters"})
+		return
+	}
+
+	var ok bool
+	if client.RedirectURIs, ok = redirectURIs(c, input.RedirectURIs); !ok {
+		return
+	}
+	if client.Scopes, ok = clientScopes(c, input.Scopes); !ok {
+		return
+	}
+
+	clientID, err := newClientID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate client id"})
+		return
+	}
+	client.ClientID = clientID
+
+	var secret, secretHash string
+	if client.Confidential {
+		if secret, secretHash, err = newHashedSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+	}
+
+	var id int
+	err = audited(c, adminDBName, auditOAuthClientCreate, auditTargetOAuthClient, func(tx *sql.Tx) (string, gin.H, error) {
+		var err error
+		id, err = db.CreateOAuthClient(c.Request.Context(), tx, client, secretHash, c.GetInt("adminId"))
+		return strconv.Itoa(id), gin.H{
+			"clientId":     client.ClientID,
+			"name":         client.Name,
+			"redirectUris": client.RedirectURIs,
+			"scopes":       client.Scopes,
+			"firstParty":   client.FirstParty,
+			"confidential": client.Confidential,
+		}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OAuth client"})
+		logger.Ctx(c.Request.Context()).Error("Failed to create OAuth client", zap.String("name", client.Name), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("OAuth client created", zap.Int("id", id), zap.String("clientID", client.ClientID), zap.String("admin", c.GetString("adminEmail")))
+
+	response := gin.H{"message": "OAuth client created successfully", "id": id, "clientId": client.ClientID}
+	if client.Confidential {
+		response["clientSecret"] = secret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListOAuthClients returns every OAuth client
+func ListOAuthClients(c *gin.Context) {
+	clients, err := db.GetOAuthClients(c.Request.Context(), adminDBName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients, "validScopes": validClientScopes()})
+}
+
+// GetOAuthClient returns one OAuth client
+func GetOAuthClient(c *gin.Context) {
+	client, ok := targetOAuthClient(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"client": client})
+}
+
+type updateOAuthClientInput struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirectUris"`
+	Scopes       []string `json:"scopes"`
+	FirstParty   *bool    `json:"firstParty"`
+}
+
+// UpdateOAuthClient changes an OAuth client; omitted fields are kept.
+// Removing scopes does not touch the consents users already gave, mynance-auth only grants what the client still has.
+func UpdateOAuthClient(c *gin.Context) {
+	client, ok := targetOAuthClient(c)
+	if !ok {
+		return
+	}
+
+	var input updateOAuthClientInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	updated := *client
+	if name := strings.TrimSpace(input.Name); name != "" {
+		if len(name) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must have at most 100 characters"})
+			return
+		}
+		updated.Name = name
+	}
+	if input.RedirectURIs != nil {
+		if updated.RedirectURIs, ok = redirectURIs(c, input.RedirectURIs); !ok {
+			return
+		}
+	}
+	if input.Scopes != nil {
+		if updated.Scopes, ok = clientScopes(c, input.Scopes); !ok {
+			return
+		}
+	}
+	if input.FirstParty != nil {
+		updated.FirstParty = *input.FirstParty
+	}
+
+	err := audited(c, adminDBName, auditOAuthClientUpdate, auditTargetOAuthClient, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.UpdateOAuthClient(c.Request.Context(), tx, updated)
+		return strconv.Itoa(client.ID), gin.H{
+			"name":         updated.Name,
+			"redirectUris": updated.RedirectURIs,
+			"scopesFrom":   client.Scopes,
+			"scopesTo":     updated.Scopes,
+			"firstParty":   updated.FirstParty,
+		}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update OAuth client"})
+		logger.Ctx(c.Request.Context()).Error("Failed to update OAuth client", zap.Int("id", client.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("OAuth client updated", zap.Int("id", client.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "OAuth client updated successfully"})
+}
+
+// RotateOAuthClientSecret generates a new secret for a confidential client; the old one stops working immediately
+func RotateOAuthClientSecret(c *gin.Context) {
+	client, ok := targetOAuthClient(c)
+	if !ok {
+		return
+	}
+	if client.RevokedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "OAuth client is revoked"})
+		return
+	}
+	if !client.Confidential {
+		c.JSON(http.StatusConflict, gin.H{"error": "Public clients have no secret"})
+		return
+	}
+
+	secret, secretHash, err := newHashedSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	err = audited(c, adminDBName, auditOAuthClientRotate, auditTargetOAuthClient, func(tx *sql.Tx) (string, gin.H, error) {
+		return strconv.Itoa(client.ID), nil, db.RotateOAuthClientSecret(c.Request.Context(), tx, client.ID, secretHash)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate secret"})
+		logger.Ctx(c.Request.Context()).Error("Failed to rotate OAuth client secret", zap.Int("id", client.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("OAuth client secret rotated", zap.Int("id", client.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"clientSecret": secret})
+}
+
+// RevokeOAuthClient disables an OAuth client; sessions it already opened end when their tokens expire
+func RevokeOAuthClient(c *gin.Context) {
+	client, ok := targetOAuthClient(c)
+	if !ok {
+		return
+	}
+	if client.RevokedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "OAuth client is already revoked"})
+		return
+	}
+
+	err := audited(c, adminDBName, auditOAuthClientRevoke, auditTargetOAuthClient, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.RevokeOAuthClient(c.Request.Context(), tx, client.ID)
+		return strconv.Itoa(client.ID), gin.H{"clientId": client.ClientID}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke OAuth client"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke OAuth client", zap.Int("id", client.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("OAuth client revoked", zap.Int("id", client.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "OAuth client revoked successfully"})
+}
+
+// targetOAuthClient loads the OAuth client in the :id param, answering 400 or 404 when it cannot
+func targetOAuthClient(c *gin.Context) (*db.OAuthClient, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth client id"})
+		return nil, false
+	}
+
+	client, err := db.GetOAuthClient(c.Request.Context(), adminDBName, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	if client == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OAuth client not found"})
+		return nil, false
+	}
+	return client, true
+}
+
+// redirectURIs validates the redirect URIs of a client, answering 400 on invalid ones.
+// Plain http is only accepted for loopback addresses; custom schemes are allowed for mobile apps.
+func redirectURIs(c *gin.Context, requested []string) ([]string, bool) {
+	if len(requested) == 0 || len(requested) > maxRedirectURIs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and " + strconv.Itoa(maxRedirectURIs) + " redirect URIs are required"})
+		return nil, false
+	}
+
+	uris := make([]string, 0, len(requested))
+	for _, value := range requested {
+		uri, err := url.Parse(value)
+		if err != nil || strings.Contains(value, "#") || !allowedRedirectURI(uri) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect URI: " + value})
+			return nil, false
+		}
+		if !slices.Contains(uris, value) {
+			uris = append(uris, value)
+		}
+	}
+	return uris, true
+}
+
+func allowedRedirectURI(uri *url.URL) bool {
+	switch uri.Scheme {
+	case "":
+		return false
+	case "https":
+		return uri.Host != ""
+	case "http":
+		host := uri.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return true
+	}
+}
+
+// clientScopes validates the scopes of a client, answering 400 on unknown ones; openid is always required
+func clientScopes(c *gin.Context, requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return []string{claims.ScopeOpenID}, true
+	}
+
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if !claims.ValidClientScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope, "validScopes": validClientScopes()})
+			return nil, false
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !slices.Contains(scopes, claims.ScopeOpenID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scopes must include " + claims.ScopeOpenID})
+		return nil, false
+	}
+	return scopes, true
+}
+
+func validClientScopes() []string {
+	scopes := append([]string(nil), claims.OpenIDScopes...)
+	for _, resource := range claims.ScopeResources {
+		scopes = append(scopes, resource+":"+claims.ScopeRead, resource+":"+claims.ScopeWrite)
+	}
+	return scopes
+}
+
+// newClientID returns a random public identifier for an OAuth client
+func newClientID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "mnc_" + hex.EncodeToString(b), nil
+}
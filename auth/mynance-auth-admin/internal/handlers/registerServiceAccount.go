@@ -1,24 +1,46 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/logger"
 	"go.uber.org/zap"
 )
 
+type registerServiceInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Password is optional; without it a random secret is generated and returned once
+	Password string   `json:"password"`
+	Scopes   []string `json:"scopes"`
+}
+
 func RegisterServiceAccount(c *gin.Context) {
-	var user postgres.User
+	var input registerServiceInput
 
-	if err := c.BindJSON(&user); err != nil {
+	if err := c.BindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	exists, err := db.UserExists(adminDBName, user.Email)
+	input.Name = strings.TrimSpace(input.Name)
+	input.Email = strings.TrimSpace(input.Email)
+	if input.Name == "" || input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and email are required"})
+		return
+	}
+
+	scopes, ok := serviceScopes(c, input.Scopes)
+	if !ok {
+		return
+	}
+
+	exists, err := db.UserExists(c.Request.Context(), adminDBName, input.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -28,18 +50,34 @@ func RegisterServiceAccount(c *gin.Context) {
 		return
 	}
 
-	if !isValidPassword(user.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long, include an uppercase letter, a lowercase letter, a number, and a special character."})
+	secret := input.Password
+	if secret == "" {
+		secret, err = newRandomSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+	} else if !checkPassword(c, input.Password, input.Email, input.Name) {
 		return
 	}
 
-	userID, err := db.CreateServiceAccount(adminDBName, user.Name, user.Email, user.Password)
+	var userID int
+	err = audited(c, adminDBName, auditServiceAccountCreate, auditTargetServiceAccount, func(tx *sql.Tx) (string, gin.H, error) {
+		var err error
+		userID, err = db.CreateServiceAccount(c.Request.Context(), tx, input.Name, input.Email, secret, scopes)
+		return strconv.Itoa(userID), gin.H{"name": input.Name, "email": input.Email, "scopes": scopes}, err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		logger.Log.Error("Failed to create user", zap.String("userName", user.Name), zap.String("userEmail", user.Email), zap.Error(err))
+		logger.Ctx(c.Request.Context()).Error("Failed to create user", zap.String("userName", input.Name), zap.String("userEmail", input.Email), zap.Error(err))
 		return
 	}
 
-	logger.Log.Info("ServiceAccount registered successfully", zap.Int("userID", userID))
-	c.JSON(http.StatusOK, gin.H{"message": "ServiceAccount registered successfully"})
+	logger.Ctx(c.Request.Context()).Info("ServiceAccount registered successfully", zap.Int("userID", userID))
+
+	response := gin.H{"message": "ServiceAccount registered successfully", "id": userID}
+	if input.Password == "" {
+		response["secret"] = secret
+	}
+	c.JSON(http.StatusOK, response)
 }
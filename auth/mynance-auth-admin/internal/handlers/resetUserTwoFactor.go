@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// ResetUserTwoFactor turns off 2FA for a user who lost both the authenticator and the recovery codes
+func ResetUserTwoFactor(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	err = audited(c, userDBName, auditUserTwoFactorReset, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		reset, err := db.ResetTwoFactor(c.Request.Context(), tx, userID)
+		if err == nil && !reset {
+			err = errNoChange
+		}
+		return strconv.Itoa(userID), nil, err
+	})
+	if errors.Is(err, errNoChange) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no two-factor authentication"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User two-factor authentication reset", zap.Int("userID", userID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/internal/middleware"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRotationOverlap keeps the old secret usable while the new one is rolled out
+	defaultRotationOverlap = 24 * time.Hour
+	maxRotationOverlap     = 7 * 24 * time.Hour
+)
+
+// ListServiceAccounts returns every service account with its scopes and credential state
+func ListServiceAccounts(c *gin.Context) {
+	accounts, err := db.GetServiceAccounts(c.Request.Context(), adminDBName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"serviceAccounts": accounts, "validScopes": middleware.ServiceScopes})
+}
+
+// GetServiceAccount returns one service account
+func GetServiceAccount(c *gin.Context) {
+	account, ok := targetServiceAccount(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"serviceAccount": account})
+}
+
+type updateServiceInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// UpdateServiceAccount renames a service account and replaces its allowed scopes
+func UpdateServiceAccount(c *gin.Context) {
+	account, ok := targetServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var input updateServiceInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = account.Name
+	}
+	scopes := account.Scopes
+	if input.Scopes != nil {
+		if scopes, ok = serviceScopes(c, input.Scopes); !ok {
+			return
+		}
+	}
+
+	err := audited(c, adminDBName, auditServiceAccountUpdate, auditTargetServiceAccount, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.UpdateServiceAccount(c.Request.Context(), tx, account.ID, name, scopes)
+		return strconv.Itoa(account.ID), gin.H{"name": name, "scopesFrom": account.Scopes, "scopesTo": scopes}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update service account"})
+		logger.Ctx(c.Request.Context()).Error("Failed to update service account", zap.Int("serviceID", account.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Service account updated", zap.Int("serviceID", account.ID), zap.Strings("scopes", scopes), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Service account updated successfully"})
+}
+
+type rotateServiceInput struct {
+	// OverlapHours is how long the current secret and its tokens keep working; 0 cuts them off right away
+	OverlapHours *int `json:"overlapHours"`
+}
+
+// RotateServiceAccountSecret generates a new secret for a service account; it is only shown in this response
+func RotateServiceAccountSecret(c *gin.Context) {
+	account, ok := targetServiceAccount(c)
+	if !ok {
+		return
+	}
+	if !account.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service account is revoked"})
+		return
+	}
+
+	var input rotateServiceInput
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	overlap := defaultRotationOverlap
+	if input.OverlapHours != nil {
+		overlap = time.Duration(*input.OverlapHours) * time.Hour
+		if overlap < 0 || overlap > maxRotationOverlap {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "overlapHours must be between 0 and " + strconv.Itoa(int(maxRotationOverlap.Hours()))})
+			return
+		}
+	}
+
+	secret, err := newRandomSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	newHash, err := hasher.Hash(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	err = audited(c, adminDBName, auditServiceAccountRotate, auditTargetServiceAccount, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.RotateServiceAccountSecret(c.Request.Context(), tx, account.ID, newHash, overlap)
+		return strconv.Itoa(account.ID), gin.H{"overlapHours": int(overlap.Hours())}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate secret"})
+		logger.Ctx(c.Request.Context()).Error("Failed to rotate service account secret", zap.Int("serviceID", account.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Service account secret rotated", zap.Int("serviceID", account.ID), zap.Duration("overlap", overlap), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{
+		"secret":                  secret,
+		"previousSecretExpiresAt": time.Now().Add(overlap).Unix(),
+	})
+}
+
+// RevokeServiceAccount disables a service account; ServiceAuth refuses its tokens from the next request on
+func RevokeServiceAccount(c *gin.Context) {
+	account, ok := targetServiceAccount(c)
+	if !ok {
+		return
+	}
+	if !account.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service account is already revoked"})
+		return
+	}
+
+	err := audited(c, adminDBName, auditServiceAccountRevoke, auditTargetServiceAccount, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.RevokeServiceAccount(c.Request.Context(), tx, account.ID)
+		return strconv.Itoa(account.ID), gin.H{"email": account.Email}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke service account"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke service account", zap.Int("serviceID", account.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Service account revoked", zap.Int("serviceID", account.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Service account revoked successfully"})
+}
+
+// targetServiceAccount loads the service account in the :id param, answering 400 or 404 when it cannot
+func targetServiceAccount(c *gin.Context) (*db.ServiceAccount, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account id"})
+		return nil, false
+	}
+
+	account, err := db.GetServiceAccount(c.Request.Context(), adminDBName, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	if account == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return nil, false
+	}
+	return account, true
+}
+
+// serviceScopes validates requested scopes, answering 400 on unknown ones; no scopes means validate-token only
+func serviceScopes(c *gin.Context, requested []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return []string{middleware.ServiceScopeValidateToken}, true
+	}
+
+	scopes := make([]string, 0, len(requested))
+	seen := map[string]bool{}
+	for _, scope := range requested {
+		valid := false
+		for _, known := range middleware.ServiceScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope, "validScopes": middleware.ServiceScopes})
+			return nil, false
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, true
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	testServiceID    = 12
+	testServiceEmail = "mynance-banks@services.mynance"
+	testAdminID      = 1
+	testAdminEmail   = "root@mynance.test"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	// Chave efêmera e hash barato: os testes não medem custo
+	os.Setenv("JWT_KEYS_DIR", "")
+	jwks.Init()
+	hasher.Default = hasher.NewArgon2id(hasher.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	hasher.Register(hasher.Default)
+
+	os.Exit(m.Run())
+}
+
+// mockDB registers sqlmock under the name the handlers read the database from
+func mockDB(t *testing.T, name string) sqlmock.Sqlmock {
+	t.Helper()
+
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.GetDB(name)
+	db.Register(name, database)
+	t.Cleanup(func() {
+		db.Register(name, previous)
+		database.Close()
+	})
+	return mock
+}
+
+// captured keeps the value the handler sent to the database
+type captured struct{ value string }
+
+func (a *captured) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	a.value = s
+	return ok
+}
+
+func hash(t *testing.T, secret string) string {
+	t.Helper()
+
+	hashed, err := hasher.Hash(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hashed
+}
+
+// expectLoginAllowed answers the lockout checks of loginGuard.Allowed
+func expectLoginAllowed(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM login_lockouts").WillReturnRows(sqlmock.NewRows([]string{"seconds"}))
+	mock.ExpectQuery("FROM login_attempts").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
+func expectServiceUser(mock sqlmock.Sqlmock, passwordHash string) {
+	mock.ExpectQuery("FROM users WHERE email").WithArgs(testServiceEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password", "active", "created_at", "last_password_change", "role"}).
+			AddRow(testServiceID, "Banks", testServiceEmail, passwordHash, true, time.Now(), time.Now().Add(-time.Hour), "service"))
+}
+
+func serviceLogin(secret string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.POST("/auth/service/login", LoginService)
+
+	body, _ := json.Marshal(LoginInput{Email: testServiceEmail, Password: secret})
+	req := httptest.NewRequest(http.MethodPost, "/auth/service/login", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginServiceDuringRotation(t *testing.T) {
+	const (
+		currentSecret  = "current-secret"
+		previousSecret = "previous-secret"
+	)
+	fullTTL := time.Now().Add(90 * 24 * time.Hour).Unix()
+
+	tests := []struct {
+		name string
+		// secret sent by the service
+		secret string
+		// previousExpiresAt is the end of the overlap; zero means it already ended
+		previousExpiresAt time.Time
+		// wantExpiresAt is the expiry of the token issued; zero means refused
+		wantExpiresAt int64
+	}{
+		{"current secret", currentSecret, time.Time{}, fullTTL},
+		{"previous secret during the overlap", previousSecret, time.Now().Add(2 * time.Hour), time.Now().Add(2 * time.Hour).Unix()},
+		{"previous secret after the overlap", previousSecret, time.Time{}, 0},
+		{"overlap longer than the token", previousSecret, time.Now().Add(100 * 24 * time.Hour), fullTTL},
+		{"unknown secret", "another-secret", time.Now().Add(2 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t, adminDBName)
+			expectLoginAllowed(mock)
+			expectServiceUser(mock, hash(t, currentSecret))
+
+			if tt.secret != currentSecret {
+				// O banco só devolve a credencial anterior enquanto a transição não acabou
+				rows := sqlmock.NewRows([]string{"previous_password", "previous_password_expires_at"})
+				if !tt.previousExpiresAt.IsZero() {
+					rows.AddRow(hash(t, previousSecret), tt.previousExpiresAt)
+				}
+				mock.ExpectQuery("FROM service_accounts\\s+WHERE user_id = \\$1 AND previous_password IS NOT NULL AND previous_password_expires_at > NOW\\(\\) AND revoked_at IS NULL").
+					WithArgs(testServiceID).WillReturnRows(rows)
+			}
+
+			if tt.wantExpiresAt == 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO login_attempts").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO login_lockouts").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"failed_count", "since"}).AddRow(0, 0))
+				mock.ExpectExec("UPDATE login_lockouts").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectQuery("SELECT last_password_change FROM users").WithArgs(testServiceID).
+					WillReturnRows(sqlmock.NewRows([]string{"last_password_change"}).AddRow(time.Now().Add(-time.Hour)))
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO login_attempts").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("DELETE FROM login_lockouts").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			}
+
+			w := serviceLogin(tt.secret)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantExpiresAt == 0 {
+				if w.Code != http.StatusUnauthorized {
+					t.Fatalf("status = %d, body %s; want 401", w.Code, w.Body)
+				}
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+
+			var response struct {
+				Token     string `json:"token"`
+				ExpiresAt int64  `json:"expiresAt"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			tokenClaims := &Claims{}
+			if _, err := jwt.ParseWithClaims(response.Token, tokenClaims, jwks.Keys.Keyfunc); err != nil {
+				t.Fatal(err)
+			}
+			// Tolerância de um segundo entre a montagem do caso e a emissão
+			if diff := tokenClaims.ExpiresAt - tt.wantExpiresAt; diff < -1 || diff > 1 || response.ExpiresAt != tokenClaims.ExpiresAt {
+				t.Fatalf("token expires at %d (answered %d), want %d", tokenClaims.ExpiresAt, response.ExpiresAt, tt.wantExpiresAt)
+			}
+		})
+	}
+}
+
+func rotate(body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.POST("/auth/admin/service-accounts/:id/rotate", func(c *gin.Context) {
+		c.Set("adminId", testAdminID)
+		c.Set("adminEmail", testAdminEmail)
+	}, RotateServiceAccountSecret)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/admin/service-accounts/12/rotate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func expectServiceAccount(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM users u LEFT JOIN service_accounts s").WithArgs(testServiceID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "scopes", "active", "created_at",
+			"previous_password_expires_at", "rotated_at", "last_used_at", "revoked_at"}).
+			AddRow(testServiceID, "Banks", testServiceEmail, "{validate-token}", true, time.Now(), nil, nil, nil, nil))
+}
+
+func TestRotateServiceAccountSecret(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		overlapSeconds int
+	}{
+		{"default overlap", "", int(defaultRotationOverlap.Seconds())},
+		{"immediate cutoff", `{"overlapHours":0}`, 0},
+		{"longest overlap", `{"overlapHours":168}`, int(maxRotationOverlap.Seconds())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t, adminDBName)
+			expectServiceAccount(mock)
+
+			newHash := &captured{}
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT password FROM users WHERE id = \\$1 AND role = 'service' FOR UPDATE").WithArgs(testServiceID).
+				WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("old-hash"))
+			mock.ExpectExec("UPDATE users SET password").WithArgs(newHash, testServiceID).WillReturnResult(sqlmock.NewResult(0, 1))
+			// A credencial atual vira a anterior, aceita só até o fim da transição
+			mock.ExpectExec("INSERT INTO service_accounts \\(user_id, previous_password, previous_password_expires_at, rotated_at\\)").
+				WithArgs(testServiceID, "old-hash", tt.overlapSeconds).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery("INSERT INTO admin_audit_log").
+				WithArgs(testAdminID, testAdminEmail, auditServiceAccountRotate, auditTargetServiceAccount, "12", sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+
+			w := rotate(tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+
+			var response struct {
+				Secret                  string `json:"secret"`
+				PreviousSecretExpiresAt int64  `json:"previousSecretExpiresAt"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if ok, _, err := hasher.Verify(response.Secret, newHash.value); !ok || err != nil {
+				t.Fatalf("the secret answered does not match the hash stored: %v", err)
+			}
+			if diff := response.PreviousSecretExpiresAt - time.Now().Unix() - int64(tt.overlapSeconds); diff < -1 || diff > 1 {
+				t.Fatalf("previousSecretExpiresAt = %d, want now + %ds", response.PreviousSecretExpiresAt, tt.overlapSeconds)
+			}
+		})
+	}
+}
+
+func TestRotateServiceAccountSecretRejectsOverlaps(t *testing.T) {
+	for _, body := range []string{`{"overlapHours":-1}`, `{"overlapHours":169}`} {
+		t.Run(body, func(t *testing.T) {
+			mock := mockDB(t, adminDBName)
+			expectServiceAccount(mock)
+
+			if w := rotate(body); w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, body %s; want 400", w.Code, w.Body)
+			}
+			// Nada é gravado: qualquer outra consulta falharia no sqlmock
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
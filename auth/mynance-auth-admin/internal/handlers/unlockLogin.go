@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/loginguard"
+	"go.uber.org/zap"
+)
+
+type unlockLoginInput struct {
+	Email string `json:"email"`
+	// Account is "user" for mynance-auth logins and "admin" for admin and service logins
+	Account string `json:"account"`
+}
+
+// UnlockLogin lifts the lockout of an email before it expires
+func UnlockLogin(c *gin.Context) {
+	var input unlockLoginInput
+	if err := c.BindJSON(&input); err != nil || input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var dbName string
+	switch input.Account {
+	case "", "user":
+		dbName = userDBName
+	case "admin":
+		dbName = adminDBName
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account type"})
+		return
+	}
+
+	email := loginguard.NormalizeEmail(input.Email)
+	err := audited(c, dbName, auditLoginUnlock, auditTargetLogin, func(tx *sql.Tx) (string, gin.H, error) {
+		unlocked, err := db.UnlockLogin(c.Request.Context(), tx, email)
+		if err == nil && !unlocked {
+			err = errNoChange
+		}
+		return email, gin.H{"account": input.Account}, err
+	})
+	if errors.Is(err, errNoChange) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed logins for this email"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Login unlocked", zap.String("email", email), zap.String("account", input.Account), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked successfully"})
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+	// forcedResetTTL is longer than the self-service link because the user did not ask for it
+	forcedResetTTL = 24 * time.Hour
+)
+
+// userRoles are the plans a user account can have
+var userRoles = map[string]bool{"user": true, "plus": true, "pro": true}
+
+var userStatuses = map[string]bool{"pending_verification": true, "verified": true}
+
+// ListUsers searches users by name or email (?search) and filters by ?role, ?status and ?active, paginated
+func ListUsers(c *gin.Context) {
+	filter := db.UserFilter{
+		Search: c.Query("search"),
+		Role:   c.Query("role"),
+		Status: c.Query("status"),
+	}
+	if filter.Role != "" && !userRoles[filter.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if filter.Status != "" && !userStatuses[filter.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if value := c.Query("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active filter"})
+			return
+		}
+		filter.Active = &active
+	}
+
+	limit, offset, ok := pagination(c, defaultUserPageSize, maxUserPageSize)
+	if !ok {
+		return
+	}
+
+	users, total, err := db.SearchUsers(c.Request.Context(), userDBName, filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to search users", zap.Error(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total, "page": offset/limit + 1, "pageSize": limit})
+}
+
+// GetUser returns a user with its 2FA, session and last login information
+func GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := db.GetUserDetails(c.Request.Context(), userDBName, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// DeactivateUser disables a user account and signs it out everywhere
+func DeactivateUser(c *gin.Context) {
+	user, ok := targetUser(c)
+	if !ok {
+		return
+	}
+	if !user.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already inactive"})
+		return
+	}
+
+	err := audited(c, userDBName, auditUserDeactivate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		if err := db.DeleteUser(c.Request.Context(), tx, user.ID); err != nil {
+			return "", nil, err
+		}
+		revoked, err := db.RevokeUserSessions(c.Request.Context(), tx, user.ID)
+		return strconv.Itoa(user.ID), gin.H{"email": user.Email, "revokedSessions": revoked}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
+		logger.Ctx(c.Request.Context()).Error("Failed to deactivate user", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User deactivated", zap.Int("userID", user.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
+}
+
+// ReactivateUser enables a deactivated user account
+func ReactivateUser(c *gin.Context) {
+	user, ok := targetUser(c)
+	if !ok {
+		return
+	}
+	if user.Active {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already active"})
+		return
+	}
+
+	err := audited(c, userDBName, auditUserReactivate, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.RecoverUser(c.Request.Context(), tx, user.ID)
+		return strconv.Itoa(user.ID), gin.H{"email": user.Email}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user"})
+		logger.Ctx(c.Request.Context()).Error("Failed to reactivate user", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User reactivated", zap.Int("userID", user.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated successfully"})
+}
+
+type changeRoleInput struct {
+	Role string `json:"role"`
+}
+
+// ChangeUserRole moves a user to another plan (user, plus or pro)
+func ChangeUserRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input changeRoleInput
+	if err := c.BindJSON(&input); err != nil || !userRoles[input.Role] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be user, plus or pro"})
+		return
+	}
+
+	user, err := db.GetUserDetails(c.Request.Context(), userDBName, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role == input.Role {
+		c.JSON(http.StatusOK, gin.H{"message": "User already has this role"})
+		return
+	}
+
+	err = audited(c, userDBName, auditUserRoleChange, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		updated, err := db.UpdateUserRole(c.Request.Context(), tx, userID, input.Role)
+		if err == nil && !updated {
+			err = errNoChange
+		}
+		return strconv.Itoa(userID), gin.H{"from": user.Role, "to": input.Role}, err
+	})
+	if errors.Is(err, errNoChange) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		logger.Ctx(c.Request.Context()).Error("Failed to change user role", zap.Int("userID", userID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User role changed", zap.Int("userID", userID), zap.String("role", input.Role), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
+
+// ForceUserPasswordReset invalidates the current password, signs the user out, revokes the personal access tokens and emails a reset link
+func ForceUserPasswordReset(c *gin.Context) {
+	user, ok := targetUser(c)
+	if !ok {
+		return
+	}
+
+	token, tokenHash, err := newHashedSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	// Senha aleatória descartada: ninguém consegue entrar até o usuário usar o link
+	unusable, err := newRandomSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	lockedHash, err := hasher.Hash(unusable)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	err = audited(c, userDBName, auditUserPasswordReset, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		err := db.ForcePasswordReset(c.Request.Context(), tx, user.ID, lockedHash, tokenHash, forcedResetTTL, forcedResetEmail(user, token))
+		return strconv.Itoa(user.ID), gin.H{"email": user.Email}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		logger.Ctx(c.Request.Context()).Error("Failed to force password reset", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User password reset forced", zap.Int("userID", user.ID), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+}
+
+// RevokeUserSessions signs a user out of every device and revokes the personal access tokens
+func RevokeUserSessions(c *gin.Context) {
+	user, ok := targetUser(c)
+	if !ok {
+		return
+	}
+
+	var revoked int64
+	err := audited(c, userDBName, auditUserSessionsRevoke, auditTargetUser, func(tx *sql.Tx) (string, gin.H, error) {
+		var err error
+		revoked, err = db.RevokeUserSessions(c.Request.Context(), tx, user.ID)
+		return strconv.Itoa(user.ID), gin.H{"revokedSessions": revoked}, err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke user sessions", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("User sessions revoked", zap.Int("userID", user.ID), zap.Int64("sessions", revoked), zap.String("admin", c.GetString("adminEmail")))
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// targetUser loads the user in the :id param, answering 400 or 404 when it cannot
+func targetUser(c *gin.Context) (*db.Profile, bool) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := db.GetProfileByID(c.Request.Context(), userDBName, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+func forcedResetEmail(user *db.Profile, token string) db.OutboxEmail {
+	link := os.Getenv("PASSWORD_RESET_URL") + "?token=" + url.QueryEscape(token)
+	return db.OutboxEmail{
+		To:      user.Email,
+		Subject: "Reset your Mynance password",
+		Body: fmt.Sprintf("Hi %s,\n\nFor your security, our team has reset your Mynance password and signed you out of every device. "+
+			"Choose a new password using the link below within %d hours:\n\n%s\n\n"+
+			"If you have questions, contact support.\n", user.Name, int(forcedResetTTL.Hours()), link),
+	}
+}
@@ -1,12 +1,20 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
 	"os"
-	"regexp"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
 )
 
 var (
@@ -14,47 +22,68 @@ var (
 	adminDBName = os.Getenv("ADMIN_DB_NAME")
 )
 
-type Claims struct {
-	UserID             int    `json:"userId"`
-	Email              string `json:"email"`
-	Role               string `json:"role"`
-	LastPasswordChange int64  `json:"lastPasswordChange"`
-	jwt.StandardClaims
+// Claims is the shared token payload, see mynance-platform/claims
+type Claims = claims.Claims
+
+// checkPassword answers 400 with every policy rule the password breaks; personal is the user's email and name
+func checkPassword(c *gin.Context, password string, personal ...string) bool {
+	violations := passwordpolicy.Check(password, personal...)
+	if len(violations) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the policy", "violations": violations})
+	return false
 }
 
-func isValidPassword(password string) bool {
-	var (
-		upperCase = regexp.MustCompile(`[A-Z]`)
-		lowerCase = regexp.MustCompile(`[a-z]`)
-		digit     = regexp.MustCompile(`\d`)
-		special   = regexp.MustCompile(`[!@#$%^&*(),.?":{}|<>]`)
-	)
-	return len(password) >= 8 && upperCase.MatchString(password) && lowerCase.MatchString(password) && digit.MatchString(password) && special.MatchString(password)
+// GenerateToken issues an admin or service token; both are only accepted by this service
+func GenerateToken(ctx context.Context, tokenType string, userID int, email, role string) (string, int64, error) {
+	return GenerateTokenUntil(ctx, tokenType, userID, email, role, time.Time{})
 }
 
-func GenerateToken(userID int, email, role string) (string, int64, error) {
-	lastPasswordChange, err := db.UserLastPasswordChange(adminDBName, userID)
+// GenerateTokenUntil is GenerateToken with the expiry capped at notAfter, unless it is zero
+func GenerateTokenUntil(ctx context.Context, tokenType string, userID int, email, role string, notAfter time.Time) (string, int64, error) {
+	lastPasswordChange, err := db.UserLastPasswordChange(ctx, adminDBName, userID)
 	if err != nil {
 		return "", 0, err
 	}
 
-	exp := time.Now().Add(24 * time.Hour).Unix()
-	if role == "service" {
-		exp = time.Now().Add(90 * 24 * time.Hour).Unix()
+	ttl := 24 * time.Hour
+	if tokenType == claims.TypeService {
+		ttl = 90 * 24 * time.Hour
 	}
 
-	claims := jwt.MapClaims{
-		"userId":             userID,
-		"email":              email,
-		"role":               role,
-		"lastPasswordChange": lastPasswordChange.Unix(),
-		"exp":                exp,
+	tokenClaims, err := claims.New(tokenType, claims.IssuerAdmin, []string{claims.AudienceAuthAdmin}, userID, email, role, ttl)
+	if err != nil {
+		return "", 0, err
+	}
+	tokenClaims.LastPasswordChange = lastPasswordChange.Unix()
+	if !notAfter.IsZero() && notAfter.Unix() < tokenClaims.ExpiresAt {
+		tokenClaims.ExpiresAt = notAfter.Unix()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	assignedToken, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	assignedToken, err := jwks.Keys.Sign(tokenClaims)
 	if err != nil {
 		return "", 0, err
 	}
-	return assignedToken, exp, nil
+	return assignedToken, tokenClaims.ExpiresAt, nil
+}
+
+// newRandomSecret returns 32 random bytes, URL-safe encoded; used for generated credentials and reset tokens
+func newRandomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newHashedSecret returns a random secret and its SHA-256 in hex, the format mynance-auth expects
+// in password_resets and for OAuth client secrets
+func newHashedSecret() (string, string, error) {
+	secret, err := newRandomSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return secret, hex.EncodeToString(sum[:]), nil
 }
@@ -1,38 +1,163 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
 )
 
-// ValidateToken verifies and returns the claims if the token is valid
-func ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+var (
+	errTokenInvalid   = errors.New("invalid or expired token")
+	errSessionRevoked = errors.New("session revoked")
+)
+
+// tokenInfo is what validate-token and introspection know about a valid user token
+type tokenInfo struct {
+	UserID    int
+	Email     string
+	Role      string
+	TokenType string
+	Scopes    []string
+	// ExpiresAt is 0 for personal access tokens without expiry
+	ExpiresAt int64
+	IssuedAt  int64
+	Issuer    string
+	Audience  []string
+	ID        string
+	// Actor and AllowWrite are only set for impersonation tokens
+	Actor      *claims.Actor
+	AllowWrite bool
+	// ClientID is set for tokens issued to OAuth clients, limited to Scopes like personal access tokens
+	ClientID string
+}
+
+// userKeys verifies the user tokens issued by mynance-auth
+var userKeys *jwks.RemoteKeySet
+
+// InitUserKeys configures the key set published by mynance-auth at USER_JWKS_URL
+func InitUserKeys() {
+	userKeys = jwks.NewRemoteKeySet(os.Getenv("USER_JWKS_URL"))
+	if err := userKeys.Refresh(); err != nil {
+		// O mynance-auth pode subir depois; as chaves são buscadas de novo na primeira validação
+		logger.Log.Warn("Failed to fetch user signing keys", zap.Error(err))
+	}
+}
+
+// ValidateToken verifies a user token signed by mynance-auth and returns its claims
+func ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	tokenClaims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, tokenClaims, userKeys.Keyfunc)
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens de admin e de serviço nunca passam pela validação de usuário
+	if err := tokenClaims.Expect(claims.TypeUser, claims.IssuerUser, claims.AudienceAuthAdmin); err != nil {
+		return nil, err
+	}
+
 	// Pegar a data da última alteração de senha do banco de dados
-	lastPasswordChange, err := db.UserLastPasswordChange(userDBName, claims.UserID)
+	lastPasswordChange, err := db.UserLastPasswordChange(ctx, userDBName, tokenClaims.UserID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Se o token foi gerado antes da última alteração de senha, ele é inválido
-	if claims.LastPasswordChange < lastPasswordChange.Unix() {
+	if tokenClaims.LastPasswordChange < lastPasswordChange.Unix() {
 		return nil, errors.New("token invalid due to password change")
 	}
 
-	return claims, nil
+	return tokenClaims, nil
+}
+
+// inspectToken checks a session token or a personal access token.
+// It returns errTokenInvalid or errSessionRevoked when the token must be refused, any other error is a server failure.
+func inspectToken(ctx context.Context, token string) (*tokenInfo, error) {
+	// Tokens de acesso pessoal são opacos: a validação é toda feita no banco
+	if claims.IsPersonalAccessToken(token) {
+		return inspectPersonalAccessToken(ctx, token)
+	}
+	// Tokens de personificação são assinados por este serviço, não pelo mynance-auth
+	if isImpersonationToken(token) {
+		return inspectImpersonationToken(ctx, token)
+	}
+
+	tokenClaims, err := ValidateToken(ctx, token)
+	if err != nil {
+		return nil, errTokenInvalid
+	}
+
+	// Tokens de usuário pertencem a uma sessão, que pode ter sido revogada no logout
+	if tokenClaims.SessionID == "" {
+		return nil, errTokenInvalid
+	}
+
+	active, err := db.SessionActive(ctx, userDBName, tokenClaims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errSessionRevoked
+	}
+
+	info := &tokenInfo{
+		UserID:    tokenClaims.UserID,
+		Email:     tokenClaims.Email,
+		Role:      tokenClaims.Role,
+		TokenType: claims.TypeUser,
+		ExpiresAt: tokenClaims.ExpiresAt,
+		IssuedAt:  tokenClaims.IssuedAt,
+		Issuer:    tokenClaims.Issuer,
+		Audience:  tokenClaims.Audience,
+		ID:        tokenClaims.ID,
+	}
+	// Tokens de clientes OAuth só alcançam os escopos de recurso que o usuário consentiu
+	if tokenClaims.ClientID != "" {
+		info.ClientID = tokenClaims.ClientID
+		info.Scopes = claims.ResourceScopes(tokenClaims.Scope)
+	}
+	return info, nil
+}
+
+func inspectPersonalAccessToken(ctx context.Context, token string) (*tokenInfo, error) {
+	sum := sha256.Sum256([]byte(token))
+	pat, err := db.UsePersonalAccessToken(ctx, userDBName, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, err
+	}
+	if pat == nil {
+		return nil, errTokenInvalid
+	}
+
+	// Sem expiração o token vale até ser revogado; expiresAt 0 indica isso
+	var expiresAt int64
+	if pat.ExpiresAt != nil {
+		expiresAt = pat.ExpiresAt.Unix()
+	}
+
+	return &tokenInfo{
+		UserID:    pat.UserID,
+		Email:     pat.Email,
+		Role:      pat.Role,
+		TokenType: claims.TypePersonalAccessToken,
+		Scopes:    pat.Scopes,
+		ExpiresAt: expiresAt,
+		Issuer:    claims.IssuerUser,
+		ID:        "pat-" + strconv.Itoa(pat.ID),
+	}, nil
 }
 
 func ValidateUserToken(c *gin.Context) {
@@ -46,22 +171,70 @@ func ValidateUserToken(c *gin.Context) {
 		return
 	}
 
-	// Valida o token
-	claims, err := ValidateToken(token)
+	info, err := inspectToken(c.Request.Context(), token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"valid": false,
-			"error": "Invalid or expired token",
-		})
+		switch {
+		case errors.Is(err, errTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"valid": false,
+				"error": "Invalid or expired token",
+			})
+		case errors.Is(err, errSessionRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"valid": false,
+				"error": "Session revoked",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"valid": false,
+				"error": "Database error",
+			})
+		}
 		return
 	}
 
+	// Toda requisição feita com um token de personificação fica registrada; sem o registro ela não é atendida
+	if info.TokenType == claims.TypeImpersonation {
+		if err := recordImpersonatedRequest(c.Request.Context(), info, reportedRequest(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"valid": false,
+				"error": "Database error",
+			})
+			logger.Ctx(c.Request.Context()).Error("Failed to record impersonated request", zap.String("impersonationID", info.ID), zap.Error(err))
+			return
+		}
+	}
+
+	setCacheHint(c, cacheMaxAge(info))
+
 	// Retorna os dados do usuário do token
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"valid":     true,
-		"userId":    claims.UserID,
-		"email":     claims.Email,
-		"role":      claims.Role,
-		"expiresAt": claims.ExpiresAt,
-	})
+		"userId":    info.UserID,
+		"email":     info.Email,
+		"role":      info.Role,
+		"expiresAt": info.ExpiresAt,
+		"tokenType": info.TokenType,
+	}
+	if info.TokenType == claims.TypePersonalAccessToken {
+		response["scopes"] = info.Scopes
+	}
+	if info.ClientID != "" {
+		response["clientId"] = info.ClientID
+		response["scopes"] = info.Scopes
+	}
+	if info.TokenType == claims.TypeImpersonation {
+		response["actor"] = info.Actor
+		response["allowWrite"] = info.AllowWrite
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// setCacheHint tells the caller for how many seconds a positive answer may be reused
+func setCacheHint(c *gin.Context, maxAge int) {
+	if maxAge <= 0 {
+		c.Header("Cache-Control", "no-store")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
 }
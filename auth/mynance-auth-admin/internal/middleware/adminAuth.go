@@ -2,12 +2,18 @@ package middleware
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
 )
 
+// AdminAuth accepts admin tokens of active accounts; the role is read from the database so changes apply right away
 func AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -22,23 +28,49 @@ func AdminAuth() gin.HandlerFunc {
 			tokenStr = authHeader[7:]
 		}
 
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		tokenClaims := &claims.Claims{}
+		token, err := jwt.ParseWithClaims(tokenStr, tokenClaims, jwks.Keys.Keyfunc)
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		if claims["type"] != "admin" {
+		if err := tokenClaims.Expect(claims.TypeAdmin, claims.IssuerAdmin, claims.AudienceAuthAdmin); err != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("serviceName", claims["email"])
+		admin, err := db.GetAdminAuth(c.Request.Context(), adminDBName, tokenClaims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		// Conta desativada, de serviço ou com senha trocada depois da emissão do token
+		if admin == nil || !admin.Active || admin.Role == rbac.RoleService || tokenClaims.LastPasswordChange < admin.LastPasswordChange.Unix() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		c.Set("adminEmail", tokenClaims.Email)
+		c.Set("adminId", tokenClaims.UserID)
+		c.Set("adminRole", admin.Role)
+		c.Request = c.Request.WithContext(logger.WithFields(c.Request.Context(), zap.Int("adminID", tokenClaims.UserID)))
+		c.Next()
+	}
+}
+
+// RequirePermission lets through only admins whose role grants permission; it must run after AdminAuth
+func RequirePermission(permission rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.Allowed(c.GetString("adminRole"), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + string(permission)})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
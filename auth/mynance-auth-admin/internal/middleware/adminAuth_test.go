@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/rbac"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+)
+
+const (
+	testAdminID    = 1
+	testAdminEmail = "auditor@mynance.test"
+)
+
+func adminToken(t *testing.T) string {
+	t.Helper()
+
+	tokenClaims, err := claims.New(claims.TypeAdmin, claims.IssuerAdmin, []string{claims.AudienceAuthAdmin}, testAdminID, testAdminEmail, rbac.RoleSuperadmin, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenClaims.LastPasswordChange = time.Now().Add(-time.Hour).Unix()
+	signed, err := jwks.Keys.Sign(tokenClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		permission rbac.Permission
+		status     int
+	}{
+		{"auditor reads the audit log", rbac.RoleAuditor, rbac.PermAuditRead, http.StatusOK},
+		{"auditor signs out", rbac.RoleAuditor, rbac.PermOwnAccount, http.StatusOK},
+		{"auditor deactivates a user", rbac.RoleAuditor, rbac.PermUsersManage, http.StatusForbidden},
+		{"auditor rotates a service secret", rbac.RoleAuditor, rbac.PermServiceAccountsManage, http.StatusForbidden},
+		{"support deactivates a user", rbac.RoleSupport, rbac.PermUsersManage, http.StatusOK},
+		{"support creates an admin", rbac.RoleSupport, rbac.PermAdminsManage, http.StatusForbidden},
+		{"support revokes an OAuth client", rbac.RoleSupport, rbac.PermOAuthClientsManage, http.StatusForbidden},
+		// O token diz superadmin; vale o papel do banco, então rebaixar um admin vale já no próximo request
+		{"demoted superadmin", rbac.RoleAuditor, rbac.PermAdminsManage, http.StatusForbidden},
+		{"service account", rbac.RoleService, rbac.PermOwnAccount, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery("SELECT role, COALESCE\\(active, FALSE\\), last_password_change FROM users").WithArgs(testAdminID).
+				WillReturnRows(sqlmock.NewRows([]string{"role", "active", "last_password_change"}).AddRow(tt.role, true, time.Now().Add(-2*time.Hour)))
+
+			r := gin.New()
+			r.POST("/auth/admin/action", AdminAuth(), RequirePermission(tt.permission), func(c *gin.Context) {
+				// O admin não se passa por serviço: as duas identidades ficam em chaves separadas
+				if c.GetString("adminEmail") != testAdminEmail || c.GetString("serviceName") != "" {
+					t.Errorf("adminEmail = %q, serviceName = %q", c.GetString("adminEmail"), c.GetString("serviceName"))
+				}
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/auth/admin/action", nil)
+			req.Header.Set("Authorization", "Bearer "+adminToken(t))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, body %s; want %d", w.Code, w.Body, tt.status)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
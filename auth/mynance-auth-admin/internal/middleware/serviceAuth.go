@@ -6,8 +6,26 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
 )
 
+// Scopes a service account can be allowed to use
+const (
+	ServiceScopeValidateToken = "validate-token"
+	// ServiceScopeIntrospect allows the RFC 7662 endpoints under /oauth
+	ServiceScopeIntrospect = "introspect"
+)
+
+// ServiceScopes lists every valid service account scope
+var ServiceScopes = []string{ServiceScopeValidateToken, ServiceScopeIntrospect}
+
+var adminDBName = os.Getenv("ADMIN_DB_NAME")
+
+// ServiceAuth accepts service tokens of active accounts; revocation and rotation are checked on every request
 func ServiceAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -22,23 +40,62 @@ func ServiceAuth() gin.HandlerFunc {
 			tokenStr = authHeader[7:]
 		}
 
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		tokenClaims := &claims.Claims{}
+		token, err := jwt.ParseWithClaims(tokenStr, tokenClaims, jwks.Keys.Keyfunc)
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
 			c.Abort()
 			return
 		}
 
-		if claims["type"] != "service" {
+		if err := tokenClaims.Expect(claims.TypeService, claims.IssuerAdmin, claims.AudienceAuthAdmin); err != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Token is not a service token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("serviceName", claims["email"])
+		account, err := db.GetServiceAccountAuth(c.Request.Context(), adminDBName, tokenClaims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		if account == nil || !account.Active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Service account revoked"})
+			c.Abort()
+			return
+		}
+		// Depois da rotação, tokens antigos só valem durante o período de transição
+		if account.RotatedAt != nil && account.OverlapEnded && tokenClaims.IssuedAt < account.RotatedAt.Unix() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Service credentials were rotated, log in again"})
+			c.Abort()
+			return
+		}
+
+		if account.NeedsTouch {
+			if err := db.TouchServiceAccount(c.Request.Context(), adminDBName, tokenClaims.UserID); err != nil {
+				logger.Ctx(c.Request.Context()).Error("Failed to record service account use", zap.Int("serviceID", tokenClaims.UserID), zap.Error(err))
+			}
+		}
+
+		c.Set("serviceName", tokenClaims.Email)
+		c.Set("serviceId", tokenClaims.UserID)
+		c.Set("serviceScopes", account.Scopes)
+		c.Request = c.Request.WithContext(logger.WithFields(c.Request.Context(), zap.String("service", tokenClaims.Email)))
 		c.Next()
 	}
 }
+
+// RequireServiceScope lets through only service accounts allowed to use scope; it must run after ServiceAuth
+func RequireServiceScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, allowed := range c.GetStringSlice("serviceScopes") {
+			if allowed == scope {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Service account is not allowed to use " + scope})
+		c.Abort()
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const testServiceID = 12
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	os.Setenv("JWT_KEYS_DIR", "")
+	jwks.Init()
+
+	os.Exit(m.Run())
+}
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.GetDB(adminDBName)
+	db.Register(adminDBName, database)
+	t.Cleanup(func() {
+		db.Register(adminDBName, previous)
+		database.Close()
+	})
+	return mock
+}
+
+// serviceToken signs a service token issued at issuedAt, as LoginService does
+func serviceToken(t *testing.T, issuedAt time.Time) string {
+	t.Helper()
+
+	tokenClaims, err := claims.New(claims.TypeService, claims.IssuerAdmin, []string{claims.AudienceAuthAdmin}, testServiceID, "mynance-banks@services.mynance", "service", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenClaims.IssuedAt = issuedAt.Unix()
+	signed, err := jwks.Keys.Sign(tokenClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestServiceAuthAfterRotation(t *testing.T) {
+	rotatedAt := time.Now().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name         string
+		issuedAt     time.Time
+		overlapEnded bool
+		status       int
+	}{
+		{"token of the previous secret during the overlap", rotatedAt.Add(-time.Hour), false, http.StatusOK},
+		{"token of the previous secret after the overlap", rotatedAt.Add(-time.Hour), true, http.StatusUnauthorized},
+		{"token of the new secret after the overlap", rotatedAt.Add(time.Minute), true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery("FROM users u LEFT JOIN service_accounts s").WithArgs(testServiceID).
+				WillReturnRows(sqlmock.NewRows([]string{"active", "scopes", "rotated_at", "overlap_ended", "needs_touch"}).
+					AddRow(true, "{validate-token}", rotatedAt, tt.overlapEnded, false))
+
+			r := gin.New()
+			r.GET("/auth/service/validate-token", ServiceAuth(), RequireServiceScope(ServiceScopeValidateToken), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/auth/service/validate-token", nil)
+			req.Header.Set("Authorization", "Bearer "+serviceToken(t, tt.issuedAt))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, body %s; want %d", w.Code, w.Body, tt.status)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
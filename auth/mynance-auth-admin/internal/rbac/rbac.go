@@ -0,0 +1,77 @@
+package rbac
+
+// Permission is an action an admin role may be granted
+type Permission string
+
+const (
+	// PermOwnAccount is reading and signing out of one's own admin account, granted to every admin role
+	PermOwnAccount            Permission = "own-account"
+	PermUsersRead             Permission = "users:read"
+	PermUsersManage           Permission = "users:manage"
+	PermUsersSecurity         Permission = "users:security"
+	PermUsersImpersonate      Permission = "users:impersonate"
+	PermUsersImpersonateWrite Permission = "users:impersonate-write" // tokens that can change the user's data
+	PermServiceAccountsRead   Permission = "service-accounts:read"
+	PermServiceAccountsManage Permission = "service-accounts:manage"
+	PermOAuthClientsRead      Permission = "oauth-clients:read"
+	PermOAuthClientsManage    Permission = "oauth-clients:manage"
+	PermAuditRead             Permission = "audit:read"
+	PermAdminsManage          Permission = "admins:manage"
+)
+
+// Admin roles stored in users.role of the admin database
+const (
+	RoleSuperadmin = "superadmin"
+	RoleSupport    = "support"
+	RoleAuditor    = "auditor"
+	// RoleService is not an admin role: service accounts never get admin tokens
+	RoleService = "service"
+)
+
+// rolePermissions is the whole access model; a role not listed here has no permission
+var rolePermissions = map[string][]Permission{
+	RoleSuperadmin: {
+		PermOwnAccount,
+		PermUsersRead, PermUsersManage, PermUsersSecurity,
+		PermUsersImpersonate, PermUsersImpersonateWrite,
+		PermServiceAccountsRead, PermServiceAccountsManage,
+		PermOAuthClientsRead, PermOAuthClientsManage,
+		PermAuditRead, PermAdminsManage,
+	},
+	// Atendimento: age sobre contas de usuários, sem mexer em contas de serviço, clientes OAuth nem em admins
+	RoleSupport: {
+		PermOwnAccount,
+		PermUsersRead, PermUsersManage, PermUsersSecurity,
+		PermUsersImpersonate,
+		PermServiceAccountsRead, PermOAuthClientsRead,
+	},
+	// Auditoria: somente leitura
+	RoleAuditor: {
+		PermOwnAccount,
+		PermUsersRead, PermServiceAccountsRead, PermOAuthClientsRead, PermAuditRead,
+	},
+}
+
+// AdminRoles lists the roles an admin account can have
+var AdminRoles = []string{RoleSuperadmin, RoleSupport, RoleAuditor}
+
+// ValidAdminRole reports whether role is one of AdminRoles
+func ValidAdminRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Allowed reports whether role grants permission
+func Allowed(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the permissions granted to role
+func Permissions(role string) []Permission {
+	return append([]Permission(nil), rolePermissions[role]...)
+}
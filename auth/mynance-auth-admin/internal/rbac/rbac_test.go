@@ -0,0 +1,76 @@
+package rbac
+
+import "testing"
+
+// writes are the permissions that change something, besides the own account
+var writes = []Permission{
+	PermUsersManage, PermUsersSecurity, PermUsersImpersonate, PermUsersImpersonateWrite,
+	PermServiceAccountsManage, PermOAuthClientsManage, PermAdminsManage,
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		role    string
+		allowed []Permission
+	}{
+		{RoleSuperadmin, append([]Permission{PermOwnAccount, PermUsersRead, PermServiceAccountsRead, PermOAuthClientsRead, PermAuditRead}, writes...)},
+		{RoleSupport, []Permission{
+			PermOwnAccount, PermUsersRead, PermServiceAccountsRead, PermOAuthClientsRead,
+			PermUsersManage, PermUsersSecurity, PermUsersImpersonate,
+		}},
+		{RoleAuditor, []Permission{PermOwnAccount, PermUsersRead, PermServiceAccountsRead, PermOAuthClientsRead, PermAuditRead}},
+		{RoleService, nil},
+		{"", nil},
+		{"unknown", nil},
+	}
+
+	every := append([]Permission{PermOwnAccount, PermUsersRead, PermServiceAccountsRead, PermOAuthClientsRead, PermAuditRead}, writes...)
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			granted := map[Permission]bool{}
+			for _, permission := range tt.allowed {
+				granted[permission] = true
+			}
+			for _, permission := range every {
+				if got := Allowed(tt.role, permission); got != granted[permission] {
+					t.Errorf("Allowed(%q, %s) = %v, want %v", tt.role, permission, got, granted[permission])
+				}
+			}
+		})
+	}
+}
+
+func TestAuditorCannotWrite(t *testing.T) {
+	for _, permission := range writes {
+		if Allowed(RoleAuditor, permission) {
+			t.Errorf("auditor granted %s", permission)
+		}
+	}
+}
+
+func TestSupportCannotManageOutsideUsers(t *testing.T) {
+	for _, permission := range []Permission{PermUsersImpersonateWrite, PermServiceAccountsManage, PermOAuthClientsManage, PermAdminsManage} {
+		if Allowed(RoleSupport, permission) {
+			t.Errorf("support granted %s", permission)
+		}
+	}
+}
+
+func TestValidAdminRole(t *testing.T) {
+	for _, role := range AdminRoles {
+		if !ValidAdminRole(role) {
+			t.Errorf("admin role %q not valid", role)
+		}
+	}
+	if ValidAdminRole(RoleService) {
+		t.Error("service accounts are not admins")
+	}
+}
+
+func TestPermissionsIsACopy(t *testing.T) {
+	permissions := Permissions(RoleAuditor)
+	permissions[0] = PermAdminsManage
+	if Allowed(RoleAuditor, PermAdminsManage) {
+		t.Fatal("changing the returned slice changed the role")
+	}
+}
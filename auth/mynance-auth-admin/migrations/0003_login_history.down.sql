@@ -0,0 +1 @@
+ALTER TABLE login_attempts DROP COLUMN IF EXISTS user_agent;
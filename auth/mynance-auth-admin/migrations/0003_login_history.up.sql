@@ -0,0 +1 @@
+ALTER TABLE login_attempts ADD COLUMN IF NOT EXISTS user_agent TEXT;
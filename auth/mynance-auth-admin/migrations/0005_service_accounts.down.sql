@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS service_accounts;
+-- Falha enquanto existirem contas de serviço
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'plus', 'pro'));
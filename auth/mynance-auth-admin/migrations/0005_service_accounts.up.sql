@@ -0,0 +1,21 @@
+-- O CHECK do init.sql original não aceita contas de serviço
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'plus', 'pro', 'service'));
+
+-- Dados das contas de serviço: escopos permitidos, rotação de credencial e último uso
+CREATE TABLE IF NOT EXISTS service_accounts (
+    user_id INT PRIMARY KEY REFERENCES users(id),
+    scopes TEXT[] NOT NULL DEFAULT '{validate-token}',
+    -- Credencial anterior continua aceita até previous_password_expires_at depois de uma rotação
+    previous_password TEXT,
+    previous_password_expires_at TIMESTAMP,
+    rotated_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Contas de serviço sem linha aqui seriam tratadas como revogadas
+INSERT INTO service_accounts (user_id)
+SELECT id FROM users WHERE role = 'service'
+ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+UPDATE users SET role = 'user' WHERE role IN ('superadmin', 'support', 'auditor');
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'plus', 'pro', 'service'));
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
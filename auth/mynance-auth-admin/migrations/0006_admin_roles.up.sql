@@ -0,0 +1,8 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+
+-- Admins de antes dos papéis ficam só com leitura; o bootstrap-admin promove quem precisar
+UPDATE users SET role = 'auditor' WHERE role IN ('user', 'plus', 'pro');
+
+-- Papéis de admin (superadmin, support, auditor) ou conta de serviço
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('superadmin', 'support', 'auditor', 'service'));
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'auditor';
@@ -0,0 +1,28 @@
+-- Personificação: um admin vendo o app como o usuário; o id é o jti do token emitido
+CREATE TABLE IF NOT EXISTS impersonations (
+    id VARCHAR(64) PRIMARY KEY,
+    admin_id INT NOT NULL REFERENCES users(id),
+    -- Usuário do banco db-auth
+    user_id INT NOT NULL,
+    reason TEXT NOT NULL,
+    allow_write BOOLEAN NOT NULL DEFAULT FALSE,
+    ip VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_user_id ON impersonations(user_id, created_at);
+
+-- Cada requisição feita com um token de personificação, registrada pelo validate-token
+CREATE TABLE IF NOT EXISTS impersonation_requests (
+    id SERIAL PRIMARY KEY,
+    impersonation_id VARCHAR(64) NOT NULL REFERENCES impersonations(id),
+    service VARCHAR(100) NOT NULL,
+    method VARCHAR(10),
+    path TEXT,
+    allowed BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_requests_impersonation_id ON impersonation_requests(impersonation_id, created_at);
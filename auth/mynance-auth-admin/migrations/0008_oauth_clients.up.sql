@@ -0,0 +1,17 @@
+-- Clientes OAuth/OpenID Connect que autenticam usuários pelo mynance-auth
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id SERIAL PRIMARY KEY,
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    -- Nulo para clientes públicos (app móvel, SPA), que dependem só do PKCE
+    secret_hash CHAR(64),
+    -- Comparadas exatamente com o redirect_uri pedido
+    redirect_uris TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{openid}',
+    -- Apps do próprio Mynance não pedem consentimento ao usuário
+    first_party BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
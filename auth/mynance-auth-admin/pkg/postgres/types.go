@@ -1,23 +0,0 @@
-package postgres
-
-import "time"
-
-type User struct {
-	ID                 int       `json:"id"`
-	Name               string    `json:"name"`
-	Email              string    `json:"email"`
-	Password           string    `json:"password"`
-	Role               string    `json:"role"`
-	LastPasswordChange int64     `json:"lastPasswordChange"`
-	CreatedAt          time.Time `json:"createdAt"`
-	Active             bool      `json:"active"`
-}
-
-type Profile struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
-	Active    bool      `json:"active"`
-}
@@ -1,58 +1,132 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
+	"os"
 
+	"github.com/jvlerner/my-finance-api/internal/db"
 	"github.com/jvlerner/my-finance-api/internal/handlers"
 	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/my-finance-api/internal/outbox"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/my-finance-api/pkg/federation"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/my-finance-api/pkg/totp"
+	"github.com/jvlerner/mynance-platform/config"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/migrate"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/jvlerner/mynance-platform/prometheus"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
+	// "migrate [up|down|status|verify]" roda as migrações do db-auth no lugar do servidor
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		logger.InitLogger()
+		config.LoadEnv()
+		postgres.InitDB()
+		code := migrate.Command(postgres.DB, migrations.FS, os.Args[2:])
+		postgres.CloseDB()
+		logger.CloseLogger()
+		os.Exit(code)
+	}
 
-	logger.Log.Info("[INFO] Starting MyFinance Auth...")
+	// Este serviço emite os tokens e usa dois bancos, então abre as conexões por conta própria
+	r := server.New(server.Config{Name: "MyFinance Auth"})
 
 	postgres.InitDB()
-	defer postgres.CloseDB()
+	r.OnShutdown(postgres.CloseDB)
+	migrate.Apply(postgres.DB, migrations.FS)
+	r.AddCheck("database", postgres.DB.PingContext)
+	r.AddCheck("migrations", migrate.Check(postgres.DB, migrations.FS))
+
+	// Clientes OAuth ficam no banco do auth-admin
+	db.InitAdminDB()
+	r.OnShutdown(db.CloseAdminDB)
+	r.AddCheck("admin database", db.AdminDB.PingContext)
+
+	// Carrega as chaves de assinatura dos tokens
+	jwks.Init()
+	r.OnShutdown(jwks.Close)
+
+	// E-mails transacionais saem pela outbox em segundo plano
+	mailer.Init()
+	outbox.Start(mailer.Mail)
+	r.OnShutdown(outbox.Stop)
+	// Roda antes do fim da outbox e dos bancos: os pedidos de reset em andamento ainda gravam
+	r.OnShutdown(handlers.WaitPasswordResets)
+
+	// Chave que cifra os segredos de 2FA no banco
+	totp.Init()
+
+	// Algoritmo e custo do hash das senhas
+	hasher.Init()
+
+	// Regras de senha e lista de senhas vazadas
+	passwordpolicy.Init()
+
+	// Provedores externos de login (Google, Microsoft...)
+	federation.Init()
 
 	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
+	dbName := os.Getenv("DB_NAME")
+	prometheus.SetDBForMonitoring(postgres.DB, dbName)
+	r.OnShutdown(func() { prometheus.RemoveDBFromMonitoring(dbName) })
+
+	// Chaves públicas para validação dos tokens pelos outros serviços
+	r.GET("/.well-known/jwks.json", jwks.Handler)
+
+	// Provedor OpenID Connect para aplicações de terceiros
+	r.GET("/.well-known/openid-configuration", handlers.Discovery)
+	r.GET("/oauth/authorize", handlers.Authorize)
+	r.POST("/oauth/token", handlers.Token)
+	r.GET("/oauth/userinfo", handlers.UserInfo)
+	r.POST("/oauth/userinfo", handlers.UserInfo)
+
+	// Tela de consentimento, com o usuário logado no Mynance
+	oauthRoutes := r.Group("/oauth")
+	oauthRoutes.Use(middleware.Auth(handlers.ValidateToken))
+	oauthRoutes.GET("/consent", handlers.ConsentDetails)
+	oauthRoutes.POST("/consent", handlers.Consent)
 
 	// Rotas de autenticação
 	r.POST("/auth/register", handlers.Register)
 	r.POST("/auth/login", handlers.Login)
+	r.POST("/auth/refresh", handlers.Refresh)
 	r.POST("/auth/logout", handlers.Logout)
+	r.POST("/auth/password/forgot", handlers.ForgotPassword)
+	r.POST("/auth/password/reset", handlers.ResetPassword)
+	r.POST("/auth/verify-email", handlers.VerifyEmail)
+	r.POST("/auth/verify-email/resend", handlers.ResendVerification)
+	r.POST("/auth/login/2fa", handlers.LoginTwoFactor)
+
+	// Login com provedores externos (OIDC)
+	r.GET("/auth/federation/providers", handlers.FederationProviders)
+	r.GET("/auth/federation/:provider/login", handlers.FederationLogin)
+	r.GET("/auth/federation/:provider/callback", handlers.FederationCallback)
+
+	// Rotas que exigem um usuário logado
+	userRoutes := r.Group("/auth")
+	userRoutes.Use(middleware.Auth(handlers.ValidateToken))
+	userRoutes.POST("/2fa/enroll", handlers.EnrollTwoFactor)
+	userRoutes.POST("/2fa/activate", handlers.ActivateTwoFactor)
+	userRoutes.POST("/2fa/disable", handlers.DisableTwoFactor)
+	userRoutes.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+	userRoutes.GET("/sessions", handlers.ListSessions)
+	userRoutes.DELETE("/sessions", handlers.RevokeAllSessions)
+	userRoutes.DELETE("/sessions/:id", handlers.RevokeSession)
+	userRoutes.GET("/login-history", handlers.LoginHistory)
+	userRoutes.GET("/tokens", handlers.ListPersonalAccessTokens)
+	userRoutes.POST("/tokens", handlers.CreatePersonalAccessToken)
+	userRoutes.DELETE("/tokens/:id", handlers.RevokePersonalAccessToken)
+	userRoutes.GET("/consents", handlers.ListConsents)
+	userRoutes.DELETE("/consents/:clientId", handlers.RevokeConsent)
+	userRoutes.GET("/identities", handlers.ListIdentities)
+	userRoutes.POST("/identities/:provider", handlers.LinkIdentity)
+	userRoutes.DELETE("/identities/:provider", handlers.UnlinkIdentity)
 
-	// Iniciar o servidor
-	r.Run(":8080")
+	r.Run()
 }
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"go.uber.org/zap"
+)
+
+// AdminDB is the db-auth-admin database, where the OAuth clients are registered; db-auth is postgres.DB
+var AdminDB *sql.DB
+
+// InitAdminDB connects to db-auth-admin, configured by the DB_ADMIN_* variables; mynance-auth only reads
+// the OAuth clients there
+func InitAdminDB() {
+	AdminDB = postgres.Connect("DB_ADMIN")
+}
+
+// CloseAdminDB closes the db-auth-admin connection
+func CloseAdminDB() {
+	if AdminDB != nil {
+		if err := AdminDB.Close(); err != nil {
+			logger.Log.Error("Failed to close admin database connection", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+var (
+	// ErrIdentityInUse is returned when the external identity is already linked to another account
+	ErrIdentityInUse = errors.New("identity linked to another user")
+	// ErrProviderAlreadyLinked is returned when the account already has an identity of the provider
+	ErrProviderAlreadyLinked = errors.New("provider already linked")
+	// ErrLastSignInMethod is returned when unlinking would leave an account without password nor identity
+	ErrLastSignInMethod = errors.New("last sign-in method")
+)
+
+// CreateFederationRequest stores a pending sign-in with an external provider, valid once within ttl
+func CreateFederationRequest(ctx context.Context, request FederationRequest, ttl time.Duration) error {
+	_, err := postgres.DB.ExecContext(ctx, `INSERT INTO federation_requests
+		(state_hash, provider, nonce, code_verifier, return_to, user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')`,
+		request.StateHash, request.Provider, request.Nonce, request.CodeVerifier, request.ReturnTo, request.UserID, int(ttl.Seconds()))
+	return err
+}
+
+// RedeemFederationRequest marks a pending sign-in as used and returns it; nil if unknown, expired or already used
+func RedeemFederationRequest(ctx context.Context, stateHash string) (*FederationRequest, error) {
+	request := FederationRequest{StateHash: stateHash}
+	err := postgres.DB.QueryRowContext(ctx, `UPDATE federation_requests SET used_at = NOW()
+		WHERE state_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING provider, nonce, code_verifier, return_to, user_id`, stateHash).
+		Scan(&request.Provider, &request.Nonce, &request.CodeVerifier, &request.ReturnTo, &request.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetUserByIdentity returns the user linked to an external identity and records the sign-in; nil if none
+func GetUserByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	var user User
+	err := postgres.DB.QueryRowContext(ctx, `UPDATE identities i SET last_login_at = NOW() FROM users u
+		WHERE i.provider = $1 AND i.subject = $2 AND u.id = i.user_id
+		RETURNING u.id, u.name, u.email, u.role, u.active, u.status`, provider, subject).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Active, &user.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateFederatedUser creates an account without password, already verified by the provider, linked to the identity
+func CreateFederatedUser(ctx context.Context, name, email, provider, subject string) (int, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO users (name, email, status, email_verified_at)
+		VALUES ($1, $2, 'verified', NOW()) RETURNING id`, name, email).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW())`, userID, provider, subject, email); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// LinkIdentity links an external identity to a user; linking the same identity again does nothing
+func LinkIdentity(ctx context.Context, userID int, provider, subject, email string) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM identities WHERE provider = $1 AND subject = $2", provider, subject).Scan(&ownerID)
+	switch {
+	case err == nil && ownerID == userID:
+		return nil
+	case err == nil:
+		return ErrIdentityInUse
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, NULLIF($4, '')) ON CONFLICT (user_id, provider) DO NOTHING`, userID, provider, subject, email)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// Outra conta do mesmo provedor já está vinculada: precisa ser desvinculada antes
+	if rows == 0 {
+		return ErrProviderAlreadyLinked
+	}
+	return tx.Commit()
+}
+
+// GetUserIdentities lists the external identities linked to a user
+func GetUserIdentities(ctx context.Context, userID int) ([]Identity, error) {
+	rows, err := postgres.DB.QueryContext(ctx, `SELECT provider, email, created_at, last_login_at FROM identities
+		WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(&identity.Provider, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// HasPassword reports whether the user can sign in with a password
+func HasPassword(ctx context.Context, userID int) (bool, error) {
+	var hasPassword bool
+	err := postgres.DB.QueryRowContext(ctx, "SELECT password IS NOT NULL FROM users WHERE id = $1", userID).Scan(&hasPassword)
+	return hasPassword, err
+}
+
+// UnlinkIdentity removes the identity of a provider from a user. It returns false if none was linked and
+// ErrLastSignInMethod if the account has no password and no other identity to sign in with.
+func UnlinkIdentity(ctx context.Context, userID int, provider string) (bool, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Bloqueia a conta para que dois desvínculos simultâneos não removam as duas últimas identidades
+	var hasPassword bool
+	if err := tx.QueryRowContext(ctx, "SELECT password IS NOT NULL FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&hasPassword); err != nil {
+		return false, err
+	}
+
+	var linked, others int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FILTER (WHERE provider = $2), COUNT(*) FILTER (WHERE provider <> $2)
+		FROM identities WHERE user_id = $1`, userID, provider).Scan(&linked, &others)
+	if err != nil {
+		return false, err
+	}
+	if linked == 0 {
+		return false, nil
+	}
+	if !hasPassword && others == 0 {
+		return false, ErrLastSignInMethod
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM identities WHERE user_id = $1 AND provider = $2", userID, provider); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// GetOAuthClient returns an active OAuth client from db-auth-admin, or nil if there is none with this client_id
+func GetOAuthClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	var client OAuthClient
+	err := AdminDB.QueryRowContext(ctx, `SELECT client_id, name, COALESCE(secret_hash, ''), redirect_uris, scopes, first_party
+		FROM oauth_clients WHERE client_id = $1 AND revoked_at IS NULL`, clientID).
+		Scan(&client.ClientID, &client.Name, &client.SecretHash, pq.Array(&client.RedirectURIs), pq.Array(&client.Scopes), &client.FirstParty)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// GetOAuthClientNames maps client_id to name for the given clients, revoked ones included
+func GetOAuthClientNames(ctx context.Context, clientIDs []string) (map[string]string, error) {
+	rows, err := AdminDB.QueryContext(ctx, "SELECT client_id, name FROM oauth_clients WHERE client_id = ANY($1)", pq.Array(clientIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]string, len(clientIDs))
+	for rows.Next() {
+		var clientID, name string
+		if err := rows.Scan(&clientID, &name); err != nil {
+			return nil, err
+		}
+		names[clientID] = name
+	}
+	return names, rows.Err()
+}
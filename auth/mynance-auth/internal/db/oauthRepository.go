@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/lib/pq"
+)
+
+// GetConsent returns the scopes a user granted to a client, or nil if there is no active consent
+func GetConsent(ctx context.Context, userID int, clientID string) ([]string, error) {
+	var scopes []string
+	err := postgres.DB.QueryRowContext(ctx, "SELECT scopes FROM oauth_consents WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL",
+		userID, clientID).Scan(pq.Array(&scopes))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// SaveConsent records the scopes a user granted to a client, replacing a previous or revoked consent
+func SaveConsent(ctx context.Context, userID int, clientID string, scopes []string) error {
+	_, err := postgres.DB.ExecContext(ctx, `INSERT INTO oauth_consents (user_id, client_id, scopes) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, granted_at = NOW(), revoked_at = NULL`,
+		userID, clientID, pq.Array(scopes))
+	return err
+}
+
+// GetUserConsents lists the clients a user has granted access to, most recent first; ClientName is left empty
+func GetUserConsents(ctx context.Context, userID int) ([]OAuthConsent, error) {
+	rows, err := postgres.DB.QueryContext(ctx, `SELECT client_id, scopes, granted_at FROM oauth_consents
+		WHERE user_id = $1 AND revoked_at IS NULL ORDER BY granted_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []OAuthConsent{}
+	for rows.Next() {
+		var consent OAuthConsent
+		if err := rows.Scan(&consent.ClientID, pq.Array(&consent.Scopes), &consent.GrantedAt); err != nil {
+			return nil, err
+		}
+		consents = append(consents, consent)
+	}
+	return consents, rows.Err()
+}
+
+// RevokeConsent withdraws a consent and signs the client out of the user's account.
+// It returns false if the user had no active consent for the client.
+func RevokeConsent(ctx context.Context, userID int, clientID string) (bool, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE oauth_consents SET revoked_at = NOW() WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL", userID, clientID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL", userID, clientID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// CreateAuthorizationCode stores an authorization code that can be exchanged once within ttl
+func CreateAuthorizationCode(ctx context.Context, code AuthorizationCode, ttl time.Duration) error {
+	_, err := postgres.DB.ExecContext(ctx, `INSERT INTO oauth_authorization_codes
+		(code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NOW() + $8 * INTERVAL '1 second')`,
+		code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, pq.Array(code.Scopes), code.Nonce, code.CodeChallenge, int(ttl.Seconds()))
+	return err
+}
+
+// GetAuthorizationCode returns a code as stored, used or not; nil if it does not exist
+func GetAuthorizationCode(ctx context.Context, codeHash string) (*AuthorizationCode, error) {
+	code := AuthorizationCode{CodeHash: codeHash}
+	err := postgres.DB.QueryRowContext(ctx, `SELECT client_id, user_id, redirect_uri, scopes, COALESCE(nonce, ''), code_challenge, expires_at, used_at, session_id
+		FROM oauth_authorization_codes WHERE code_hash = $1`, codeHash).
+		Scan(&code.ClientID, &code.UserID, &code.RedirectURI, pq.Array(&code.Scopes), &code.Nonce, &code.CodeChallenge,
+			&code.ExpiresAt, &code.UsedAt, &code.SessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RedeemAuthorizationCode marks a code as used; call it only after the request was checked against the code.
+// It returns false when another request redeemed the code first.
+func RedeemAuthorizationCode(ctx context.Context, codeHash string) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, "UPDATE oauth_authorization_codes SET used_at = NOW() WHERE code_hash = $1 AND used_at IS NULL", codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// AttachCodeSession links a redeemed code to the session it opened, so replaying the code can revoke it
+func AttachCodeSession(ctx context.Context, codeHash, sessionID string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE oauth_authorization_codes SET session_id = $2 WHERE code_hash = $1", codeHash, sessionID)
+	return err
+}
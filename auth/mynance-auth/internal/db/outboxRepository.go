@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+// outboxLease is how long a claimed email stays hidden from other workers
+const outboxLease = 5 * time.Minute
+
+// enqueueEmail stores an email in the outbox inside the caller's transaction
+func enqueueEmail(ctx context.Context, tx *sql.Tx, msg mailer.Message) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO email_outbox (recipient, subject, body) VALUES ($1, $2, $3)", msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+// ClaimPendingEmails leases up to limit due emails so that concurrent workers don't send them twice
+func ClaimPendingEmails(ctx context.Context, limit, maxAttempts int) ([]OutboxEmail, error) {
+	rows, err := postgres.DB.QueryContext(ctx, `UPDATE email_outbox SET next_attempt_at = NOW() + $1 * INTERVAL '1 second', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE sent_at IS NULL AND next_attempt_at <= NOW() AND attempts < $2
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, body, attempts`, int(outboxLease.Seconds()), maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []OutboxEmail
+	for rows.Next() {
+		var email OutboxEmail
+		if err := rows.Scan(&email.ID, &email.Recipient, &email.Subject, &email.Body, &email.Attempts); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records a successful delivery
+func MarkEmailSent(ctx context.Context, id int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE email_outbox SET sent_at = NOW(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+// MarkEmailFailed records a failed delivery and schedules the next attempt
+func MarkEmailFailed(ctx context.Context, id int, sendErr error, retryIn time.Duration) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE email_outbox SET last_error = $1, next_attempt_at = NOW() + $2 * INTERVAL '1 second' WHERE id = $3",
+		sendErr.Error(), int(retryIn.Seconds()), id)
+	return err
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+// ErrResetTokenInvalid is returned for unknown, expired or already used reset tokens
+var ErrResetTokenInvalid = errors.New("reset token is invalid or expired")
+
+// CreatePasswordReset stores a reset token and queues its email in the same transaction. It returns false
+// without doing anything if a reset was requested for the user within cooldown.
+func CreatePasswordReset(ctx context.Context, userID int, tokenHash, ip string, expiresAt time.Time, cooldown time.Duration, msg mailer.Message) (bool, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Bloqueia o usuário para que pedidos simultâneos não passem juntos pelo intervalo mínimo
+	if _, err := tx.ExecContext(ctx, "SELECT 1 FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+		return false, err
+	}
+
+	var recent bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM password_resets
+		WHERE user_id = $1 AND created_at > NOW() - $2 * INTERVAL '1 second')`, userID, int(cooldown.Seconds())).Scan(&recent)
+	if err != nil {
+		return false, err
+	}
+	if recent {
+		return false, nil
+	}
+
+	// Só o link mais recente continua valendo
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO password_resets (user_id, token_hash, ip, expires_at) VALUES ($1, $2, $3, $4)",
+		userID, tokenHash, ip, expiresAt); err != nil {
+		return false, err
+	}
+
+	if err := enqueueEmail(ctx, tx, msg); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetPasswordResetUser returns the owner of a usable reset token, or nil if the token is invalid
+func GetPasswordResetUser(ctx context.Context, tokenHash string) (*Profile, error) {
+	var user Profile
+	err := postgres.DB.QueryRowContext(ctx, `SELECT u.id, u.name, u.email FROM password_resets r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = $1 AND r.used_at IS NULL AND r.expires_at > NOW() AND u.active`, tokenHash).Scan(&user.ID, &user.Name, &user.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes every session of the user.
+// notify builds the confirmation email sent to the account owner.
+func ResetPassword(ctx context.Context, tokenHash, password string, notify func(user *Profile) mailer.Message) (int, error) {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE impede que duas requisições usem o mesmo token ao mesmo tempo
+	var resetID int
+	var user Profile
+	err = tx.QueryRowContext(ctx, `SELECT r.id, u.id, u.name, u.email FROM password_resets r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = $1 AND r.used_at IS NULL AND r.expires_at > NOW() AND u.active
+		FOR UPDATE OF r`, tokenHash).Scan(&resetID, &user.ID, &user.Name, &user.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE password_resets SET used_at = NOW() WHERE id = $1", resetID); err != nil {
+		return 0, err
+	}
+
+	// last_password_change invalida todos os JWTs emitidos antes da troca
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", hashedPassword, user.ID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", user.ID); err != nil {
+		return 0, err
+	}
+
+	// Tokens de acesso pessoal não dependem da senha, então também são revogados
+	if _, err := tx.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", user.ID); err != nil {
+		return 0, err
+	}
+
+	if err := enqueueEmail(ctx, tx, notify(&user)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
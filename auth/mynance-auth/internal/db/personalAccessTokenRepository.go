@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/lib/pq"
+)
+
+// ErrTooManyTokens is returned when the user already has the maximum number of active tokens
+var ErrTooManyTokens = errors.New("too many personal access tokens")
+
+// CreatePersonalAccessToken stores a new token unless the user already has limit active ones
+func CreatePersonalAccessToken(ctx context.Context, userID int, name, tokenHash, prefix string, scopes []string, expiresAt *time.Time, limit int) (*PersonalAccessToken, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Trava o usuário para que criações simultâneas não passem do limite
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+		return nil, err
+	}
+
+	var active int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`, userID).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if active >= limit {
+		return nil, ErrTooManyTokens
+	}
+
+	token := PersonalAccessToken{UserID: userID, Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt}
+	err = tx.QueryRowContext(ctx, `INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		userID, name, tokenHash, prefix, pq.Array(scopes), expiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, tx.Commit()
+}
+
+// GetPersonalAccessTokens lists the active tokens of a user, newest first
+func GetPersonalAccessTokens(ctx context.Context, userID int) ([]PersonalAccessToken, error) {
+	rows, err := postgres.DB.QueryContext(ctx, `SELECT id, name, token_prefix, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		token := PersonalAccessToken{UserID: userID}
+		if err := rows.Scan(&token.ID, &token.Name, &token.Prefix, pq.Array(&token.Scopes), &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokePersonalAccessToken revokes a token of the user; it returns false if there was no such active token
+func RevokePersonalAccessToken(ctx context.Context, userID, tokenID int) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
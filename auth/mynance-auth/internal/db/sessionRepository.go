@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+// ErrSessionAlreadyRotated is returned when a refresh token was already exchanged for a new one
+var ErrSessionAlreadyRotated = errors.New("session already rotated")
+
+// CreateSession starts a new session family and returns its ID
+func CreateSession(ctx context.Context, userID int, refreshTokenHash, ip, userAgent string, expiresAt time.Time) (string, error) {
+	var familyID string
+	err := postgres.DB.QueryRowContext(ctx, `INSERT INTO sessions (user_id, refresh_token_hash, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING family_id`,
+		userID, refreshTokenHash, ip, userAgent, expiresAt).Scan(&familyID)
+	if err != nil {
+		return "", err
+	}
+	return familyID, nil
+}
+
+// GetSessionByRefreshHash retrieves the session row that owns a refresh token
+func GetSessionByRefreshHash(ctx context.Context, refreshTokenHash string) (*Session, error) {
+	var session Session
+	err := postgres.DB.QueryRowContext(ctx, `SELECT id, family_id, user_id, refresh_token_hash, COALESCE(ip, ''), COALESCE(user_agent, ''),
+		created_at, expires_at, rotated_at, revoked_at, client_id, COALESCE(scope, '')
+		FROM sessions WHERE refresh_token_hash = $1`, refreshTokenHash).
+		Scan(&session.ID, &session.FamilyID, &session.UserID, &session.RefreshTokenHash, &session.IP, &session.UserAgent,
+			&session.CreatedAt, &session.ExpiresAt, &session.RotatedAt, &session.RevokedAt, &session.ClientID, &session.Scope)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession marks the current refresh token as used and stores its successor in the same family
+func RotateSession(ctx context.Context, current *Session, refreshTokenHash, ip, userAgent string, expiresAt time.Time) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE sessions SET rotated_at = NOW() WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL", current.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// Outra requisição já trocou este refresh token
+	if rows == 0 {
+		return ErrSessionAlreadyRotated
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO sessions (family_id, user_id, refresh_token_hash, ip, user_agent, expires_at, client_id, scope)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))`,
+		current.FamilyID, current.UserID, refreshTokenHash, ip, userAgent, expiresAt, current.ClientID, current.Scope)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateClientSession starts a session family for an OAuth client with the granted scope and returns its ID
+func CreateClientSession(ctx context.Context, userID int, clientID, scope, refreshTokenHash, ip, userAgent string, expiresAt time.Time) (string, error) {
+	var familyID string
+	err := postgres.DB.QueryRowContext(ctx, `INSERT INTO sessions (user_id, refresh_token_hash, ip, user_agent, expires_at, client_id, scope)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING family_id`,
+		userID, refreshTokenHash, ip, userAgent, expiresAt, clientID, scope).Scan(&familyID)
+	if err != nil {
+		return "", err
+	}
+	return familyID, nil
+}
+
+// RevokeSessionFamily revokes every refresh token of a session
+func RevokeSessionFamily(ctx context.Context, familyID string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID)
+	return err
+}
+
+// SessionActive reports whether a session still has a usable refresh token
+func SessionActive(ctx context.Context, familyID string) (bool, error) {
+	var active bool
+	err := postgres.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sessions
+		WHERE family_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW())`, familyID).Scan(&active)
+	if err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// GetActiveSessions lists the signed-in devices of a user, most recently used first
+func GetActiveSessions(ctx context.Context, userID int) ([]Session, error) {
+	// Cada família tem uma única linha ativa (o refresh token atual); a primeira linha marca o login
+	rows, err := postgres.DB.QueryContext(ctx, `SELECT s.family_id, COALESCE(s.ip, ''), COALESCE(s.user_agent, ''), f.created_at, s.created_at, s.expires_at, s.client_id
+		FROM sessions s
+		JOIN (SELECT family_id, MIN(created_at) AS created_at FROM sessions WHERE user_id = $1 GROUP BY family_id) f ON f.family_id = s.family_id
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL AND s.rotated_at IS NULL AND s.expires_at > NOW()
+		ORDER BY s.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session := Session{UserID: userID}
+		if err := rows.Scan(&session.FamilyID, &session.IP, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.ClientID); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeUserSession revokes a session owned by the user; it returns false if there was no such active session
+func RevokeUserSession(ctx context.Context, userID int, familyID string) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND family_id::text = $2 AND revoked_at IS NULL`, userID, familyID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RevokeAllUserSessions signs a user out of every device.
+// Besides revoking the sessions and personal access tokens it bumps last_password_change, so access tokens already checked by other services stop being accepted too.
+func RevokeAllUserSessions(ctx context.Context, userID int) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET last_password_change = NOW() WHERE id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
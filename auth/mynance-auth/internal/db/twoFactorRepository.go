@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+// ErrTwoFactorNotPending is returned when there is no enrollment waiting for confirmation
+var ErrTwoFactorNotPending = errors.New("no pending two-factor enrollment")
+
+// GetTOTP retrieves the TOTP enrollment of a user, confirmed or not
+func GetTOTP(ctx context.Context, userID int) (*TOTP, error) {
+	var totp TOTP
+	err := postgres.DB.QueryRowContext(ctx, "SELECT user_id, secret, last_used_step, created_at, enabled_at FROM user_totp WHERE user_id = $1", userID).
+		Scan(&totp.UserID, &totp.Secret, &totp.LastUsedStep, &totp.CreatedAt, &totp.EnabledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// TwoFactorEnabled reports whether the user confirmed a TOTP enrollment
+func TwoFactorEnabled(ctx context.Context, userID int) (bool, error) {
+	var enabled bool
+	err := postgres.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL)", userID).Scan(&enabled)
+	if err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// SavePendingTOTP stores a new unconfirmed secret, replacing a previous unconfirmed one.
+// It returns false if 2FA is already enabled.
+func SavePendingTOTP(ctx context.Context, userID int, sealedSecret string) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.enabled_at IS NULL`, userID, sealedSecret)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ActivateTOTP confirms the pending enrollment and replaces the user's recovery codes
+func ActivateTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE user_totp SET enabled_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrTwoFactorNotPending
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the step of an accepted code; it returns false if that step or a later one was already used
+func UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, "UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// UseRecoveryCode consumes an unused recovery code
+func UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, `UPDATE recovery_codes SET used_at = NOW()
+		WHERE id = (SELECT id FROM recovery_codes WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1 FOR UPDATE)`,
+		userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// RegenerateRecoveryCodes invalidates the previous recovery codes of a user with 2FA enabled
+func RegenerateRecoveryCodes(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP removes the enrollment and the recovery codes of a user
+func DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
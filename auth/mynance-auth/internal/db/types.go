@@ -0,0 +1,136 @@
+package db
+
+import "time"
+
+type User struct {
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email"`
+	Password           string     `json:"password"`
+	Role               string     `json:"role"`
+	LastPasswordChange int64      `json:"lastPasswordChange"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	Active             bool       `json:"active"`
+	Status             string     `json:"status"`
+	EmailVerifiedAt    *time.Time `json:"emailVerifiedAt"`
+}
+
+type Profile struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  string    `json:"-"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	Active    bool      `json:"active"`
+}
+
+type Session struct {
+	ID               int        `json:"-"`
+	FamilyID         string     `json:"id"`
+	UserID           int        `json:"userId"`
+	RefreshTokenHash string     `json:"-"`
+	IP               string     `json:"ip"`
+	UserAgent        string     `json:"userAgent"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastSeenAt       time.Time  `json:"lastSeenAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RotatedAt        *time.Time `json:"-"`
+	RevokedAt        *time.Time `json:"-"`
+	// ClientID and Scope are set on sessions opened by an OAuth client
+	ClientID *string `json:"clientId,omitempty"`
+	Scope    string  `json:"-"`
+}
+
+type LoginAttempt struct {
+	Email     string    `json:"-"`
+	UserID    *int      `json:"-"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Success   bool      `json:"success"`
+	SessionID string    `json:"sessionId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OutboxEmail struct {
+	ID        int
+	Recipient string
+	Subject   string
+	Body      string
+	Attempts  int
+}
+
+type TOTP struct {
+	UserID       int
+	Secret       string
+	LastUsedStep int64
+	CreatedAt    time.Time
+	EnabledAt    *time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+// OAuthClient is an application registered in db-auth-admin to sign users in through OpenID Connect
+type OAuthClient struct {
+	ClientID string
+	Name     string
+	// SecretHash is empty for public clients, which rely on PKCE alone
+	SecretHash   string
+	RedirectURIs []string
+	Scopes       []string
+	// FirstParty clients are Mynance apps and skip the consent screen
+	FirstParty bool
+}
+
+// OAuthConsent records the scopes a user granted to an OAuth client
+type OAuthConsent struct {
+	ClientID   string    `json:"clientId"`
+	ClientName string    `json:"clientName"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"grantedAt"`
+}
+
+// AuthorizationCode is a pending authorization code + PKCE grant
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+	// SessionID is the session opened when the code was exchanged
+	SessionID *string
+}
+
+// Identity is an external identity provider account linked to a user
+type Identity struct {
+	Provider    string     `json:"provider"`
+	Email       *string    `json:"email"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+}
+
+// FederationRequest is a sign-in with an external provider waiting for its callback
+type FederationRequest struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ReturnTo     string
+	// UserID is set when a signed-in user is linking the identity instead of signing in
+	UserID *int
+}
@@ -1,44 +1,106 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // UserExists checks if a user exists by email
-func UserExists(email string) (bool, error) {
+func UserExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	err := postgres.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+	err := postgres.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-// CreateUser inserts a new user into the database
-func CreateUser(name, email, password string) (int, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// CreateUser inserts a new user pending email verification and queues the verification email built by verification
+func CreateUser(ctx context.Context, name, email, password string, verification func(userID int) (mailer.Message, error)) (int, error) {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := postgres.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
 	var userID int
-	err = postgres.DB.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id", name, email, string(hashedPassword)).Scan(&userID)
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (name, email, password, verification_sent_at) VALUES ($1, $2, $3, NOW()) RETURNING id", name, email, hashedPassword).Scan(&userID)
 	if err != nil {
 		return 0, err
 	}
 
+	msg, err := verification(userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := enqueueEmail(ctx, tx, msg); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 	return userID, nil
 }
 
-func UserLastPasswordChange(userID int) (time.Time, error) {
+// VerifyEmail marks the email as verified if it still belongs to the user
+func VerifyEmail(ctx context.Context, userID int, email string) (bool, error) {
+	result, err := postgres.DB.ExecContext(ctx, `UPDATE users SET status = 'verified', email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2 AND active`, userID, email)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// QueueVerificationEmail queues a new verification email unless one was sent less than cooldown ago.
+// It returns false when the user is already verified or still in the cooldown.
+func QueueVerificationEmail(ctx context.Context, userID int, cooldown time.Duration, msg mailer.Message) (bool, error) {
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET verification_sent_at = NOW()
+		WHERE id = $1 AND status = 'pending_verification'
+		AND (verification_sent_at IS NULL OR verification_sent_at < NOW() - $2 * INTERVAL '1 second')`,
+		userID, int(cooldown.Seconds()))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := enqueueEmail(ctx, tx, msg); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func UserLastPasswordChange(ctx context.Context, userID int) (time.Time, error) {
 	var lastPasswordChange time.Time
-	err := postgres.DB.QueryRow("SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
+	err := postgres.DB.QueryRowContext(ctx, "SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return time.Time{}, nil
@@ -49,22 +111,24 @@ func UserLastPasswordChange(userID int) (time.Time, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func GetUserByEmail(email string) (*postgres.User, error) {
-	var user postgres.User
-	err := postgres.DB.QueryRow("SELECT id, name, email, password,  active, created_at, last_password_change, role FROM users WHERE email = $1", email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt, &user.LastPasswordChange, &user.Role)
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	var lastPasswordChange time.Time
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, name, email, COALESCE(password, ''), active, created_at, last_password_change, role, status, email_verified_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt, &lastPasswordChange, &user.Role, &user.Status, &user.EmailVerifiedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
+	user.LastPasswordChange = lastPasswordChange.Unix()
 	return &user, nil
 }
 
 // GetProfileByID retrieves user profile by ID
-func GetProfileByID(userID int) (*postgres.Profile, error) {
-	var user postgres.Profile
-	err := postgres.DB.QueryRow("SELECT id, name, email, active, created_at FROM users WHERE id = $1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.Active, &user.CreatedAt)
+func GetProfileByID(ctx context.Context, userID int) (*Profile, error) {
+	var user Profile
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, name, email, role, active, created_at FROM users WHERE id = $1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Active, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -75,29 +139,36 @@ func GetProfileByID(userID int) (*postgres.Profile, error) {
 }
 
 // UpdateUser updates user details
-func UpdateUser(userID int, name string) error {
-	_, err := postgres.DB.Exec("UPDATE users SET name = $1 WHERE id = $2", name, userID)
+func UpdateUser(ctx context.Context, userID int, name string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", name, userID)
 	return err
 }
 
 // UpdateUserPassword updates user password
-func UpdateUserPassword(userID int, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func UpdateUserPassword(ctx context.Context, userID int, password string) error {
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
-	_, err = postgres.DB.Exec("UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", string(hashedPassword), userID)
+	_, err = postgres.DB.ExecContext(ctx, "UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", hashedPassword, userID)
+	return err
+}
+
+// UpgradePasswordHash replaces the stored hash with a stronger one for the same password.
+// It does not touch last_password_change, so sessions stay valid, and does nothing if the password changed meanwhile.
+func UpgradePasswordHash(ctx context.Context, userID int, currentHash, newHash string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET password = $3 WHERE id = $1 AND password = $2", userID, currentHash, newHash)
 	return err
 }
 
 // DeleteUser marks a user as inactive instead of permanent deletion
-func DeleteUser(userID int) error {
-	_, err := postgres.DB.Exec("UPDATE users SET active = FALSE WHERE id = $1", userID)
+func DeleteUser(ctx context.Context, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET active = FALSE WHERE id = $1", userID)
 	return err
 }
 
 // RecoverUser reactivates a user account
-func RecoverUser(userID int) error {
-	_, err := postgres.DB.Exec("UPDATE users SET active = TRUE WHERE id = $1", userID)
+func RecoverUser(ctx context.Context, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET active = TRUE WHERE id = $1", userID)
 	return err
 }
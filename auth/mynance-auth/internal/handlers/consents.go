@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// ListConsents returns the applications the user has allowed to access the account
+func ListConsents(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	consents, err := db.GetUserConsents(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	clientIDs := make([]string, 0, len(consents))
+	for _, consent := range consents {
+		clientIDs = append(clientIDs, consent.ClientID)
+	}
+	names, err := db.GetOAuthClientNames(c.Request.Context(), clientIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	for i := range consents {
+		consents[i].ClientName = names[consents[i].ClientID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// RevokeConsent removes an application's access to the account and ends the sessions it opened
+func RevokeConsent(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+	clientID := c.Param("clientId")
+
+	revoked, err := db.RevokeConsent(c.Request.Context(), user.UserID, clientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke consent"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke consent", zap.Int("userID", user.UserID), zap.String("clientID", clientID), zap.Error(err))
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Consent not found"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Consent revoked by user", zap.Int("userID", user.UserID), zap.String("clientID", clientID))
+	c.JSON(http.StatusOK, gin.H{"message": "Consent revoked successfully"})
+}
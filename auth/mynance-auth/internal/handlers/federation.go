@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/pkg/federation"
+	"github.com/jvlerner/mynance-platform/config"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	federationRequestTTL = 10 * time.Minute
+
+	federationCookieName = "federation_state"
+	federationCookiePath = "/auth/federation"
+
+	// Limite da coluna users.name
+	maxUserNameLength = 100
+)
+
+type linkIdentityInput struct {
+	ReturnTo string `json:"returnTo"`
+}
+
+// FederationProviders lists the external identity providers users can sign in with
+func FederationProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": federation.Providers()})
+}
+
+// FederationLogin sends the browser to the provider's sign-in page; return_to is where it lands afterwards
+func FederationLogin(c *gin.Context) {
+	provider, ok := federationProvider(c)
+	if !ok {
+		return
+	}
+
+	authURL, ok := startFederation(c, provider, c.Query("return_to"), nil)
+	if !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkIdentity starts linking an external identity to the signed-in user; the page must send the browser to redirectTo
+func LinkIdentity(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	provider, ok := federationProvider(c)
+	if !ok {
+		return
+	}
+
+	var input linkIdentityInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	authURL, ok := startFederation(c, provider, input.ReturnTo, &user.UserID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirectTo": authURL})
+}
+
+// FederationCallback receives the browser back from the provider and signs the user in or finishes the link.
+// Errors go back to the frontend as ?error=: to FEDERATION_LOGIN_URL on sign-in and to return_to on link.
+func FederationCallback(c *gin.Context) {
+	provider, ok := federationProvider(c)
+	if !ok {
+		return
+	}
+
+	// O state precisa ser o mesmo do cookie deste navegador, senão alguém poderia logar a vítima na conta dele
+	state := c.Query("state")
+	cookie, err := c.Cookie(federationCookieName)
+	clearFederationCookie(c)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sign-in attempt"})
+		return
+	}
+
+	request, err := db.RedeemFederationRequest(c.Request.Context(), hashOpaqueToken(state))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if request == nil || request.Provider != provider.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sign-in attempt"})
+		return
+	}
+
+	// Usuário cancelou ou o provedor recusou
+	if providerError := c.Query("error"); providerError != "" {
+		logger.Ctx(c.Request.Context()).Info("Identity provider returned an error", zap.String("provider", provider.ID), zap.String("error", providerError))
+		federationFailed(c, request, "provider_denied")
+		return
+	}
+
+	identity, err := provider.Exchange(c.Query("code"), request.CodeVerifier, federationRedirectURI(provider), request.Nonce)
+	if err != nil {
+		logger.Ctx(c.Request.Context()).Warn("Failed to sign in with identity provider", zap.String("provider", provider.ID), zap.Error(err))
+		federationFailed(c, request, "provider_error")
+		return
+	}
+
+	if request.UserID != nil {
+		finishLink(c, provider, request, identity)
+		return
+	}
+	finishFederatedLogin(c, provider, request, identity)
+}
+
+func finishLink(c *gin.Context, provider *federation.Provider, request *db.FederationRequest, identity *federation.Identity) {
+	userID := *request.UserID
+
+	err := db.LinkIdentity(c.Request.Context(), userID, provider.ID, identity.Subject, identity.Email)
+	switch {
+	case errors.Is(err, db.ErrIdentityInUse):
+		federationFailed(c, request, "identity_in_use")
+		return
+	case errors.Is(err, db.ErrProviderAlreadyLinked):
+		federationFailed(c, request, "provider_already_linked")
+		return
+	case err != nil:
+		logger.Ctx(c.Request.Context()).Error("Failed to link identity", zap.Int("userID", userID), zap.String("provider", provider.ID), zap.Error(err))
+		federationFailed(c, request, "server_error")
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Identity linked", zap.Int("userID", userID), zap.String("provider", provider.ID))
+	c.Redirect(http.StatusFound, redirectURL(request.ReturnTo, url.Values{"linked": {provider.ID}}))
+}
+
+func finishFederatedLogin(c *gin.Context, provider *federation.Provider, request *db.FederationRequest, identity *federation.Identity) {
+	user, err := db.GetUserByIdentity(c.Request.Context(), provider.ID, identity.Subject)
+	if err != nil {
+		federationFailed(c, request, "server_error")
+		return
+	}
+
+	if user == nil {
+		// Primeiro login: só cria a conta com um e-mail confirmado pelo provedor
+		if identity.Email == "" || !identity.EmailVerified || !isValidEmail(identity.Email) {
+			federationFailed(c, request, "email_not_verified")
+			return
+		}
+
+		// Vincular sozinho a uma conta existente pelo e-mail permitiria tomar a conta de alguém
+		// através de um provedor que aceite e-mails de terceiros; o dono entra com a senha e vincula
+		exists, err := db.UserExists(c.Request.Context(), identity.Email)
+		if err != nil {
+			federationFailed(c, request, "server_error")
+			return
+		}
+		if exists {
+			federationFailed(c, request, "account_exists")
+			return
+		}
+
+		userID, err := db.CreateFederatedUser(c.Request.Context(), federatedUserName(identity), identity.Email, provider.ID, identity.Subject)
+		if err != nil {
+			logger.Ctx(c.Request.Context()).Error("Failed to create federated user", zap.String("provider", provider.ID), zap.Error(err))
+			federationFailed(c, request, "server_error")
+			return
+		}
+		logger.Ctx(c.Request.Context()).Info("User registered with identity provider", zap.Int("userID", userID), zap.String("provider", provider.ID))
+
+		user = &db.User{ID: userID, Email: identity.Email, Role: "user", Active: true, Status: "verified"}
+	}
+
+	if !user.Active {
+		federationFailed(c, request, "account_inactive")
+		return
+	}
+
+	// O provedor substitui a senha, não o segundo fator
+	twoFactor, err := db.TwoFactorEnabled(c.Request.Context(), user.ID)
+	if err != nil {
+		federationFailed(c, request, "server_error")
+		return
+	}
+	if twoFactor {
+		mfaToken, err := newMFAChallenge(user.ID, user.Email, user.Role)
+		if err != nil {
+			federationFailed(c, request, "server_error")
+			return
+		}
+		// No fragmento o token não aparece em logs de acesso nem no Referer
+		fragment := url.Values{"mfaToken": {mfaToken}, "return_to": {request.ReturnTo}}
+		c.Redirect(http.StatusFound, os.Getenv("FEDERATION_LOGIN_URL")+"#"+fragment.Encode())
+		return
+	}
+
+	if _, ok := openSession(c, user.ID, user.Email, user.Role); !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, request.ReturnTo)
+}
+
+// ListIdentities returns the external identities linked to the user and whether the account has a password
+func ListIdentities(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	identities, err := db.GetUserIdentities(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	hasPassword, err := db.HasPassword(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities, "hasPassword": hasPassword})
+}
+
+// UnlinkIdentity removes an external identity from the user; the last way to sign in cannot be removed
+func UnlinkIdentity(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+	provider := c.Param("provider")
+
+	unlinked, err := db.UnlinkIdentity(c.Request.Context(), user.UserID, provider)
+	if errors.Is(err, db.ErrLastSignInMethod) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Set a password before unlinking your only sign-in method"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		logger.Ctx(c.Request.Context()).Error("Failed to unlink identity", zap.Int("userID", user.UserID), zap.String("provider", provider), zap.Error(err))
+		return
+	}
+	if !unlinked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Identity unlinked", zap.Int("userID", user.UserID), zap.String("provider", provider))
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
+
+// startFederation stores a pending sign-in and returns the provider URL, answering the error when it cannot
+func startFederation(c *gin.Context, provider *federation.Provider, returnTo string, userID *int) (string, bool) {
+	if !allowedReturnTo(returnTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return_to"})
+		return "", false
+	}
+
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return "", false
+	}
+	nonce, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return "", false
+	}
+	codeVerifier, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return "", false
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	authURL, err := provider.AuthCodeURL(federationRedirectURI(provider), state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		logger.Ctx(c.Request.Context()).Error("Failed to reach identity provider", zap.String("provider", provider.ID), zap.Error(err))
+		return "", false
+	}
+
+	if err := db.CreateFederationRequest(c.Request.Context(), db.FederationRequest{
+		StateHash:    stateHash,
+		Provider:     provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ReturnTo:     returnTo,
+		UserID:       userID,
+	}, federationRequestTTL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to store federation request", zap.String("provider", provider.ID), zap.Error(err))
+		return "", false
+	}
+
+	// Lax: o cookie precisa voltar no redirecionamento vindo do site do provedor
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     federationCookieName,
+		Value:    state,
+		Expires:  time.Now().Add(federationRequestTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     federationCookiePath,
+	})
+	return authURL, true
+}
+
+// federationFailed sends the browser back to the frontend with an error code
+func federationFailed(c *gin.Context, request *db.FederationRequest, code string) {
+	if request.UserID != nil {
+		c.Redirect(http.StatusFound, redirectURL(request.ReturnTo, url.Values{"error": {code}}))
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL(os.Getenv("FEDERATION_LOGIN_URL"), url.Values{"error": {code}, "return_to": {request.ReturnTo}}))
+}
+
+func federationProvider(c *gin.Context) (*federation.Provider, bool) {
+	provider, ok := federation.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return nil, false
+	}
+	return provider, true
+}
+
+func clearFederationCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     federationCookieName,
+		Value:    "",
+		Path:     federationCookiePath,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// federationRedirectURI is the callback registered with the provider
+func federationRedirectURI(provider *federation.Provider) string {
+	return oidcIssuer() + federationCookiePath + "/" + provider.ID + "/callback"
+}
+
+// allowedReturnTo accepts only URLs of the frontend (CORS origins) or of mynance-auth itself, so the
+// callback cannot be turned into an open redirect
+func allowedReturnTo(returnTo string) bool {
+	target, err := url.Parse(returnTo)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return false
+	}
+	origin := target.Scheme + "://" + target.Host
+
+	allowed := append(config.GetCORS(), oidcIssuer())
+	return slices.ContainsFunc(allowed, func(candidate string) bool {
+		return strings.EqualFold(strings.TrimRight(strings.TrimSpace(candidate), "/"), origin)
+	})
+}
+
+// federatedUserName uses the name given by the provider, falling back to the start of the email
+func federatedUserName(identity *federation.Identity) string {
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if runes := []rune(name); len(runes) > maxUserNameLength {
+		name = string(runes[:maxUserNameLength])
+	}
+	return name
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/pkg/federation"
+	"github.com/jvlerner/my-finance-api/pkg/federation/federationtest"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserID   = 7
+	testFrontend = "https://app.mynance.test"
+)
+
+var federatedUser = federationtest.User{Subject: "subject-1", Email: "ana@example.com", EmailVerified: true, Name: "Ana"}
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// captured keeps the value the handler sent to the database, so the test can play the next step of the flow
+type captured struct{ value string }
+
+func (a *captured) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	a.value = s
+	return ok && s != ""
+}
+
+// setupFederation configures the provider "mock" against a mock issuer and replaces db-auth with sqlmock
+func setupFederation(t *testing.T) (*federationtest.Issuer, sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+
+	issuer := federationtest.NewIssuer(t, "mynance-test")
+	t.Setenv("FEDERATION_PROVIDERS", "mock")
+	t.Setenv("FEDERATION_MOCK_ISSUER", issuer.URL)
+	t.Setenv("FEDERATION_MOCK_CLIENT_ID", issuer.ClientID)
+	t.Setenv("OIDC_ISSUER", "https://auth.mynance.test")
+	t.Setenv("CORS", testFrontend)
+	federation.Init()
+
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := postgres.DB
+	postgres.DB = database
+	t.Cleanup(func() {
+		postgres.DB = previous
+		database.Close()
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	signedIn := r.Group("/auth", func(c *gin.Context) {
+		c.Set("claims", &Claims{UserID: testUserID})
+	})
+	signedIn.POST("/identities/:provider", LinkIdentity)
+	signedIn.DELETE("/identities/:provider", UnlinkIdentity)
+	r.GET("/auth/federation/:provider/callback", FederationCallback)
+	return issuer, mock, r
+}
+
+// startLink asks to link the provider and returns the authorization URL, the state cookie and what was stored
+func startLink(t *testing.T, mock sqlmock.Sqlmock, r *gin.Engine) (string, *http.Cookie, *captured, *captured) {
+	t.Helper()
+
+	nonce, verifier := &captured{}, &captured{}
+	mock.ExpectExec("INSERT INTO federation_requests").
+		WithArgs(sqlmock.AnyArg(), "mock", nonce, verifier, testFrontend+"/settings", testUserID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/identities/mock", strings.NewReader(`{"returnTo":"`+testFrontend+`/settings"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("link status = %d, body %s", w.Code, w.Body)
+	}
+
+	var body struct {
+		RedirectTo string `json:"redirectTo"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	var cookie *http.Cookie
+	for _, candidate := range w.Result().Cookies() {
+		if candidate.Name == federationCookieName {
+			cookie = candidate
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("state cookie missing or insecure: %+v", cookie)
+	}
+	return body.RedirectTo, cookie, nonce, verifier
+}
+
+// expectRedeem returns, for the state in the cookie, the request stored by startLink
+func expectRedeem(mock sqlmock.Sqlmock, cookie *http.Cookie, nonce, verifier *captured) {
+	mock.ExpectQuery("UPDATE federation_requests SET used_at").
+		WithArgs(hashOpaqueToken(cookie.Value)).
+		WillReturnRows(sqlmock.NewRows([]string{"provider", "nonce", "code_verifier", "return_to", "user_id"}).
+			AddRow("mock", nonce.value, verifier.value, testFrontend+"/settings", testUserID))
+}
+
+// callback sends the browser back from the provider with the code
+func callback(r *gin.Engine, cookie *http.Cookie, code string) *httptest.ResponseRecorder {
+	query := url.Values{"state": {cookie.Value}, "code": {code}}
+	req := httptest.NewRequest(http.MethodGet, "/auth/federation/mock/callback?"+query.Encode(), nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func expectRedirect(t *testing.T, w *httptest.ResponseRecorder, key, value string) {
+	t.Helper()
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302; body %s", w.Code, w.Body)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), testFrontend+"/settings") {
+		t.Fatalf("redirected to %q, want the return_to", w.Header().Get("Location"))
+	}
+	if got := location.Query().Get(key); got != value {
+		t.Fatalf("redirect %s = %q, want %q (%s)", key, got, value, location)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	issuer, mock, r := setupFederation(t)
+
+	authURL, cookie, nonce, verifier := startLink(t, mock, r)
+	code := issuer.Authorize(t, authURL, federatedUser)
+
+	expectRedeem(mock, cookie, nonce, verifier)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities").WithArgs("mock", federatedUser.Subject).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectExec("INSERT INTO identities").WithArgs(testUserID, "mock", federatedUser.Subject, federatedUser.Email).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	expectRedirect(t, callback(r, cookie, code), "linked", "mock")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkIdentityInUseByAnotherAccount(t *testing.T) {
+	issuer, mock, r := setupFederation(t)
+
+	authURL, cookie, nonce, verifier := startLink(t, mock, r)
+	code := issuer.Authorize(t, authURL, federatedUser)
+
+	expectRedeem(mock, cookie, nonce, verifier)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities").WithArgs("mock", federatedUser.Subject).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(testUserID + 1))
+	mock.ExpectRollback()
+
+	expectRedirect(t, callback(r, cookie, code), "error", "identity_in_use")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkIdentityRejectsBadIDToken(t *testing.T) {
+	issuer, mock, r := setupFederation(t)
+
+	authURL, cookie, nonce, verifier := startLink(t, mock, r)
+	code := issuer.Authorize(t, authURL, federatedUser)
+	// Token de outra tentativa: nada pode ser vinculado
+	issuer.EditClaims = func(claims jwt.MapClaims) { claims["nonce"] = "another-attempt" }
+	expectRedeem(mock, cookie, nonce, verifier)
+
+	expectRedirect(t, callback(r, cookie, code), "error", "provider_error")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFederationCallbackRejectsStateOfAnotherBrowser(t *testing.T) {
+	_, mock, r := setupFederation(t)
+
+	_, cookie, _, _ := startLink(t, mock, r)
+
+	query := url.Values{"state": {cookie.Value}, "code": {"code"}}
+	req := httptest.NewRequest(http.MethodGet, "/auth/federation/mock/callback?"+query.Encode(), nil)
+	req.AddCookie(&http.Cookie{Name: federationCookieName, Value: "state-of-the-attacker"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkIdentityRejectsReturnToOfAnotherSite(t *testing.T) {
+	_, mock, r := setupFederation(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/identities/mock", strings.NewReader(`{"returnTo":"https://evil.example.com"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	tests := []struct {
+		name        string
+		hasPassword bool
+		linked      int
+		others      int
+		status      int
+	}{
+		{"with a password", true, 1, 0, http.StatusOK},
+		{"with another identity", false, 1, 1, http.StatusOK},
+		{"last sign-in method", false, 1, 0, http.StatusConflict},
+		{"not linked", true, 0, 1, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock, r := setupFederation(t)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT password IS NOT NULL FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(testUserID).
+				WillReturnRows(sqlmock.NewRows([]string{"has_password"}).AddRow(tt.hasPassword))
+			mock.ExpectQuery("SELECT COUNT").WithArgs(testUserID, "mock").
+				WillReturnRows(sqlmock.NewRows([]string{"linked", "others"}).AddRow(tt.linked, tt.others))
+			if tt.status == http.StatusOK {
+				mock.ExpectExec("DELETE FROM identities").WithArgs(testUserID, "mock").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/auth/identities/mock", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
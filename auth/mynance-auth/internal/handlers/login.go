@@ -6,26 +6,23 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/logger"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Login(c *gin.Context) {
-	var user postgres.User
-	var tokenExpiration time.Duration = 12
+	var user db.User
 
 	// Verifica se há um token válido no cookie
 	tokenCookie, err := c.Request.Cookie("token")
 	if err == nil {
 		// Valida o token existente
-		claims, err := ValidateToken(tokenCookie.Value)
+		claims, err := ValidateToken(c.Request.Context(), tokenCookie.Value)
 		if err == nil {
-			logger.Log.Info("User already has a valid token", zap.Int("userID", claims.UserID))
+			logger.Ctx(c.Request.Context()).Info("User already has a valid token", zap.Int("userID", claims.UserID))
 
 			// Atualiza o token e o cookie
-			newToken, err := GenerateToken(claims.UserID, claims.Email, claims.Role)
+			newToken, err := GenerateToken(c.Request.Context(), claims.UserID, claims.Email, claims.Role, claims.SessionID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
 				return
@@ -35,7 +32,7 @@ func Login(c *gin.Context) {
 			http.SetCookie(c.Writer, &http.Cookie{
 				Name:     "token",
 				Value:    newToken,
-				Expires:  time.Now().Add(tokenExpiration * time.Hour),
+				Expires:  time.Now().Add(accessTokenTTL),
 				HttpOnly: true,
 				Secure:   true,
 				SameSite: http.SameSiteStrictMode,
@@ -53,43 +50,83 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	exists, err := db.UserExists(user.Email)
+	if !loginGuard.Allowed(c, user.Email) {
+		return
+	}
+
+	// E-mail inexistente e senha errada seguem o mesmo caminho, com o mesmo custo de hash
+	storedUser, err := db.GetUserByEmail(c.Request.Context(), user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+
+	if !passwordMatches(c.Request.Context(), storedUser, user.Password) {
+		var userID *int
+		if storedUser != nil {
+			userID = &storedUser.ID
+		}
+		loginGuard.Failed(c, user.Email, userID)
+		return
+	}
+
+	// Só depois da senha correta, para não revelar o estado de contas alheias
+	if storedUser.Status != "verified" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email not verified", "code": "email_not_verified"})
 		return
 	}
 
-	storedUser, err := db.GetUserByEmail(user.Email)
+	// Com 2FA ativo a sessão só é criada depois do código em /auth/login/2fa
+	twoFactor, err := db.TwoFactorEnabled(c.Request.Context(), storedUser.ID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if twoFactor {
+		mfaToken, err := newMFAChallenge(storedUser.ID, storedUser.Email, storedUser.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfaRequired": true, "mfaToken": mfaToken})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	startSession(c, storedUser.ID, storedUser.Email, storedUser.Role)
+}
+
+// startSession creates a session, sets the token cookies and writes the login response
+func startSession(c *gin.Context, userID int, email, role string) {
+	if _, ok := openSession(c, userID, email, role); !ok {
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+}
 
-	token, err := GenerateToken(storedUser.ID, storedUser.Email, storedUser.Role)
+// openSession creates a session and sets the token cookies, answering 500 when it cannot
+func openSession(c *gin.Context, userID int, email, role string) (string, bool) {
+	refreshToken, refreshTokenHash, err := newOpaqueToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		return "", false
 	}
 
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Expires:  time.Now().Add(tokenExpiration * time.Hour),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-		Path:     "/",
-	})
-
-	logger.Log.Info("User logged in", zap.Int("userID", storedUser.ID))
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	sessionID, err := db.CreateSession(c.Request.Context(), userID, refreshTokenHash, c.ClientIP(), c.Request.UserAgent(), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		logger.Ctx(c.Request.Context()).Error("Failed to create session", zap.Int("userID", userID), zap.Error(err))
+		return "", false
+	}
+
+	token, err := GenerateToken(c.Request.Context(), userID, email, role, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return "", false
+	}
+
+	setSessionCookies(c, token, refreshToken)
+	loginGuard.Succeeded(c, email, userID, sessionID)
+
+	logger.Ctx(c.Request.Context()).Info("User logged in", zap.Int("userID", userID), zap.String("sessionID", sessionID))
+	return sessionID, true
 }
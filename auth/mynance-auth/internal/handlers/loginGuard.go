@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/loginguard"
+	"go.uber.org/zap"
+)
+
+// loginGuard locks emails and IPs after repeated login failures, with the attempts kept in this service's database
+var loginGuard = loginguard.New(db.LoginStore{})
+
+// dummyPasswordHash is checked when the email is unknown, so that both cases take the same time.
+// It is created on first use, after hasher.Init picked the algorithm.
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// passwordMatches always runs the hasher, even for unknown emails and accounts without a password.
+// A matching hash made with an old algorithm or old parameters is replaced on the fly.
+func passwordMatches(ctx context.Context, user *db.User, password string) bool {
+	// Conta criada por login externo ainda sem senha: mesmo custo de um e-mail inexistente
+	if user == nil || user.Password == "" {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = hasher.Hash("mynance-timing-equalizer")
+		})
+		hasher.Verify(password, dummyPasswordHash)
+		return false
+	}
+
+	ok, rehash, err := hasher.Verify(password, user.Password)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to verify password hash", zap.Int("userID", user.ID), zap.Error(err))
+		return false
+	}
+	if ok && rehash {
+		upgradePasswordHash(ctx, user, password)
+	}
+	return ok
+}
+
+func upgradePasswordHash(ctx context.Context, user *db.User, password string) {
+	newHash, err := hasher.Hash(password)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to rehash password", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+	if err := db.UpgradePasswordHash(ctx, user.ID, user.Password, newHash); err != nil {
+		logger.Ctx(ctx).Error("Failed to store rehashed password", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+	logger.Ctx(ctx).Info("Password hash upgraded", zap.Int("userID", user.ID), zap.String("algorithm", hasher.Default.ID()))
+}
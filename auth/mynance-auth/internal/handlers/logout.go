@@ -2,22 +2,41 @@ package handlers
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
 )
 
 func Logout(c *gin.Context) {
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		Expires:  time.Unix(0, 0),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	// Revoga a sessão do refresh token; o access token deixa de ser aceito junto com ela
+	if refreshCookie, err := c.Request.Cookie(refreshCookieName); err == nil && refreshCookie.Value != "" {
+		session, err := db.GetSessionByRefreshHash(c.Request.Context(), hashOpaqueToken(refreshCookie.Value))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if session != nil {
+			if err := db.RevokeSessionFamily(c.Request.Context(), session.FamilyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+				logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", session.FamilyID), zap.Error(err))
+				return
+			}
+			logger.Ctx(c.Request.Context()).Info("User logged out", zap.Int("userID", session.UserID), zap.String("sessionID", session.FamilyID))
+		}
+	} else if tokenCookie, err := c.Request.Cookie("token"); err == nil {
+		if claims, err := ValidateToken(c.Request.Context(), tokenCookie.Value); err == nil {
+			if err := db.RevokeSessionFamily(c.Request.Context(), claims.SessionID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+				logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", claims.SessionID), zap.Error(err))
+				return
+			}
+			logger.Ctx(c.Request.Context()).Info("User logged out", zap.Int("userID", claims.UserID), zap.String("sessionID", claims.SessionID))
+		}
+	}
+
+	clearSessionCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logout efetuado com sucesso"})
 }
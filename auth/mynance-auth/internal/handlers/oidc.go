@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	authorizationCodeTTL = time.Minute
+	maxNonceLength       = 255
+)
+
+// codeChallengePattern is a base64url S256 code challenge (RFC 7636)
+var codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43,128}$`)
+
+// authorizeRequest holds the parameters of an OpenID Connect authorization request.
+// The consent page sends them back as JSON with the user's decision.
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	// Prompt supports none (fail instead of showing login or consent) and consent (always ask)
+	Prompt string `form:"prompt" json:"prompt"`
+}
+
+// oauthError is an error of the authorization endpoint; once the redirect URI is trusted it goes back to the client
+type oauthError struct {
+	Code        string
+	Description string
+	Redirect    bool
+}
+
+// Discovery serves /.well-known/openid-configuration
+func Discovery(c *gin.Context) {
+	issuer := oidcIssuer()
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"userinfo_endpoint":                     issuer + "/oauth/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      append(append([]string(nil), claims.OpenIDScopes...), validScopes()...),
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"prompt_values_supported":               []string{"none", "consent"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "nonce", "at_hash", "name", "email", "email_verified"},
+	})
+}
+
+// Authorize is the authorization endpoint. The user signs in on the frontend, which sends the browser back here;
+// clients without a previous consent go through the consent page before getting a code.
+func Authorize(c *gin.Context) {
+	var request authorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	client, scopes, oauthErr := checkAuthorizeRequest(c.Request.Context(), &request)
+	if oauthErr != nil {
+		respondAuthorizeError(c, &request, oauthErr)
+		return
+	}
+
+	// O cookie é SameSite=Strict: vindo do site do cliente ele não chega e a página de login devolve o usuário para cá
+	user := currentUser(c)
+	if user == nil {
+		if request.Prompt == "none" {
+			c.Redirect(http.StatusFound, authorizeErrorURL(&request, "login_required", ""))
+			return
+		}
+		returnTo := oidcIssuer() + "/oauth/authorize?" + c.Request.URL.RawQuery
+		c.Redirect(http.StatusFound, os.Getenv("OIDC_LOGIN_URL")+"?return_to="+url.QueryEscape(returnTo))
+		return
+	}
+
+	needed, err := consentNeeded(c.Request.Context(), user.UserID, client, scopes, request.Prompt == "consent")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if needed {
+		if request.Prompt == "none" {
+			c.Redirect(http.StatusFound, authorizeErrorURL(&request, "consent_required", ""))
+			return
+		}
+		c.Redirect(http.StatusFound, os.Getenv("OIDC_CONSENT_URL")+"?"+c.Request.URL.RawQuery)
+		return
+	}
+
+	redirectTo, err := issueAuthorizationCode(c.Request.Context(), user, &request, scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to issue authorization code", zap.Int("userID", user.UserID), zap.String("clientID", client.ClientID), zap.Error(err))
+		return
+	}
+	c.Redirect(http.StatusFound, redirectTo)
+}
+
+// ConsentDetails tells the consent page which client is asking for which scopes
+func ConsentDetails(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var request authorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	client, scopes, oauthErr := checkAuthorizeRequest(c.Request.Context(), &request)
+	if oauthErr != nil {
+		consentError(c, &request, oauthErr)
+		return
+	}
+
+	granted, err := db.GetConsent(c.Request.Context(), user.UserID, client.ClientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	alreadyGranted := []string{}
+	for _, scope := range scopes {
+		if slices.Contains(granted, scope) {
+			alreadyGranted = append(alreadyGranted, scope)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"client":         gin.H{"clientId": client.ClientID, "name": client.Name},
+		"scopes":         scopes,
+		"alreadyGranted": alreadyGranted,
+	})
+}
+
+type consentInput struct {
+	authorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// Consent records the user's decision on the consent page and returns where to send the browser
+func Consent(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var input consentInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	request := &input.authorizeRequest
+
+	client, scopes, oauthErr := checkAuthorizeRequest(c.Request.Context(), request)
+	if oauthErr != nil {
+		consentError(c, request, oauthErr)
+		return
+	}
+
+	if !input.Approve {
+		logger.Ctx(c.Request.Context()).Info("OAuth consent denied", zap.Int("userID", user.UserID), zap.String("clientID", client.ClientID))
+		c.JSON(http.StatusOK, gin.H{"redirectTo": authorizeErrorURL(request, "access_denied", "The user denied the request")})
+		return
+	}
+
+	// O consentimento acumula os escopos já concedidos antes
+	granted, err := db.GetConsent(c.Request.Context(), user.UserID, client.ClientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	if err := db.SaveConsent(c.Request.Context(), user.UserID, client.ClientID, granted); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to save OAuth consent", zap.Int("userID", user.UserID), zap.String("clientID", client.ClientID), zap.Error(err))
+		return
+	}
+
+	redirectTo, err := issueAuthorizationCode(c.Request.Context(), user, request, scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue authorization code"})
+		logger.Ctx(c.Request.Context()).Error("Failed to issue authorization code", zap.Int("userID", user.UserID), zap.String("clientID", client.ClientID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("OAuth consent granted", zap.Int("userID", user.UserID), zap.String("clientID", client.ClientID), zap.Strings("scopes", granted))
+	c.JSON(http.StatusOK, gin.H{"redirectTo": redirectTo})
+}
+
+// checkAuthorizeRequest validates the client, the redirect URI, PKCE and the requested scopes
+func checkAuthorizeRequest(ctx context.Context, request *authorizeRequest) (*db.OAuthClient, []string, *oauthError) {
+	if request.ClientID == "" {
+		return nil, nil, &oauthError{Code: "invalid_request", Description: "client_id is required"}
+	}
+	client, err := db.GetOAuthClient(ctx, request.ClientID)
+	if err != nil {
+		return nil, nil, &oauthError{Code: "server_error"}
+	}
+	if client == nil {
+		return nil, nil, &oauthError{Code: "invalid_client", Description: "Unknown client"}
+	}
+	// Sem um redirect_uri cadastrado o erro não pode voltar ao cliente
+	if !slices.Contains(client.RedirectURIs, request.RedirectURI) {
+		return nil, nil, &oauthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	if request.ResponseType != "code" {
+		return nil, nil, &oauthError{Code: "unsupported_response_type", Description: "Only the code flow is supported", Redirect: true}
+	}
+	if request.CodeChallengeMethod != "S256" || !codeChallengePattern.MatchString(request.CodeChallenge) {
+		return nil, nil, &oauthError{Code: "invalid_request", Description: "PKCE with code_challenge_method S256 is required", Redirect: true}
+	}
+	if len(request.Nonce) > maxNonceLength {
+		return nil, nil, &oauthError{Code: "invalid_request", Description: "nonce is too long", Redirect: true}
+	}
+	if request.Prompt != "" && request.Prompt != "none" && request.Prompt != "consent" {
+		return nil, nil, &oauthError{Code: "invalid_request", Description: "Unsupported prompt", Redirect: true}
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(request.Scope) {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, nil, &oauthError{Code: "invalid_scope", Description: "Scope not allowed for this client: " + scope, Redirect: true}
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if !slices.Contains(scopes, claims.ScopeOpenID) {
+		return nil, nil, &oauthError{Code: "invalid_scope", Description: "The openid scope is required", Redirect: true}
+	}
+
+	return client, scopes, nil
+}
+
+// consentNeeded reports whether the user still has to approve the scopes; Mynance's own apps never ask
+func consentNeeded(ctx context.Context, userID int, client *db.OAuthClient, scopes []string, force bool) (bool, error) {
+	if client.FirstParty {
+		return false, nil
+	}
+	if force {
+		return true, nil
+	}
+
+	granted, err := db.GetConsent(ctx, userID, client.ClientID)
+	if err != nil {
+		return false, err
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// issueAuthorizationCode stores a new code and returns the redirect URI that delivers it to the client
+func issueAuthorizationCode(ctx context.Context, user *Claims, request *authorizeRequest, scopes []string) (string, error) {
+	code, codeHash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = db.CreateAuthorizationCode(ctx, db.AuthorizationCode{
+		CodeHash:      codeHash,
+		ClientID:      request.ClientID,
+		UserID:        user.UserID,
+		RedirectURI:   request.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+	}, authorizationCodeTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return redirectURL(request.RedirectURI, url.Values{"code": {code}, "state": {request.State}}), nil
+}
+
+// currentUser returns the signed-in user of the token cookie, or nil
+func currentUser(c *gin.Context) *Claims {
+	tokenCookie, err := c.Cookie("token")
+	if err != nil {
+		return nil
+	}
+	user, err := ValidateToken(c.Request.Context(), tokenCookie)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+func respondAuthorizeError(c *gin.Context, request *authorizeRequest, oauthErr *oauthError) {
+	if oauthErr.Code == "server_error" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if !oauthErr.Redirect {
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	c.Redirect(http.StatusFound, authorizeErrorURL(request, oauthErr.Code, oauthErr.Description))
+}
+
+// consentError answers the consent page; redirectTo is only sent when the error can go back to the client
+func consentError(c *gin.Context, request *authorizeRequest, oauthErr *oauthError) {
+	if oauthErr.Code == "server_error" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	response := gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description}
+	if oauthErr.Redirect {
+		response["redirectTo"] = authorizeErrorURL(request, oauthErr.Code, oauthErr.Description)
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
+
+func authorizeErrorURL(request *authorizeRequest, code, description string) string {
+	params := url.Values{"error": {code}, "state": {request.State}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	return redirectURL(request.RedirectURI, params)
+}
+
+// redirectURL adds params to a registered redirect URI, keeping its own query; an empty state is left out
+func redirectURL(redirectURI string, params url.Values) string {
+	if params.Get("state") == "" {
+		params.Del("state")
+	}
+	uri, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
+
+// oidcIssuer is the public base URL of mynance-auth, used as "iss" of ID tokens and in discovery
+func oidcIssuer() string {
+	return strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/")
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// idTokenClaims is the OpenID Connect ID token; it is only signed here, never accepted as a credential
+type idTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Audience      string `json:"aud"`
+	IssuedAt      int64  `json:"iat"`
+	ExpiresAt     int64  `json:"exp"`
+	Nonce         string `json:"nonce,omitempty"`
+	AccessHash    string `json:"at_hash,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// Valid implements jwt.Claims
+func (c *idTokenClaims) Valid() error {
+	return nil
+}
+
+// Token is the token endpoint: authorization_code (with PKCE) and refresh_token grants, form encoded
+func Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		exchangeAuthorizationCode(c, client)
+	case "refresh_token":
+		refreshClientSession(c, client)
+	default:
+		tokenError(c, http.StatusBadRequest, "unsupported_grant_type", "Use authorization_code or refresh_token")
+	}
+}
+
+func exchangeAuthorizationCode(c *gin.Context, client *db.OAuthClient) {
+	codeHash := hashOpaqueToken(c.PostForm("code"))
+	code, err := db.GetAuthorizationCode(c.Request.Context(), codeHash)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if code == nil {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+	if code.UsedAt != nil {
+		rejectCodeReuse(c, code)
+		return
+	}
+
+	if code.ClientID != client.ClientID || time.Now().After(code.ExpiresAt) || code.RedirectURI != c.PostForm("redirect_uri") {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+	if !verifyCodeChallenge(c.PostForm("code_verifier"), code.CodeChallenge) {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid code_verifier")
+		return
+	}
+
+	// Só depois das verificações: quem interceptou o código sem o code_verifier não consegue queimá-lo
+	redeemed, err := db.RedeemAuthorizationCode(c.Request.Context(), codeHash)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if !redeemed {
+		// Outra requisição trocou o código entre a leitura e aqui
+		if code, err = db.GetAuthorizationCode(c.Request.Context(), codeHash); err != nil || code == nil {
+			tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+			return
+		}
+		rejectCodeReuse(c, code)
+		return
+	}
+
+	user, ok := activeUser(c, code.UserID)
+	if !ok {
+		return
+	}
+
+	// O cliente pode ter perdido escopos desde a autorização
+	scopes := grantedScopes(code.Scopes, client)
+
+	refreshToken, refreshTokenHash, err := newOpaqueToken()
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	// Sem offline_access a sessão termina junto com o access token
+	expiresAt := time.Now().Add(accessTokenTTL)
+	if slices.Contains(scopes, claims.ScopeOfflineAccess) {
+		expiresAt = time.Now().Add(refreshTokenTTL)
+	}
+
+	sessionID, err := db.CreateClientSession(c.Request.Context(), user.ID, client.ClientID, strings.Join(scopes, " "), refreshTokenHash, c.ClientIP(), c.Request.UserAgent(), expiresAt)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		logger.Ctx(c.Request.Context()).Error("Failed to create client session", zap.Int("userID", user.ID), zap.String("clientID", client.ClientID), zap.Error(err))
+		return
+	}
+	if err := db.AttachCodeSession(c.Request.Context(), codeHash, sessionID); err != nil {
+		logger.Ctx(c.Request.Context()).Error("Failed to link authorization code to session", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+
+	if !slices.Contains(scopes, claims.ScopeOfflineAccess) {
+		refreshToken = ""
+	}
+	logger.Ctx(c.Request.Context()).Info("OAuth client signed in", zap.Int("userID", user.ID), zap.String("clientID", client.ClientID), zap.String("sessionID", sessionID))
+	respondTokens(c, user, client, sessionID, scopes, code.Nonce, refreshToken)
+}
+
+// rejectCodeReuse answers a code presented again; the session opened with it may have leaked along and is revoked
+func rejectCodeReuse(c *gin.Context, code *db.AuthorizationCode) {
+	logger.Ctx(c.Request.Context()).Warn("Authorization code reuse detected", zap.Int("userID", code.UserID), zap.String("clientID", code.ClientID), zap.String("ip", c.ClientIP()))
+	if code.SessionID != nil {
+		if err := db.RevokeSessionFamily(c.Request.Context(), *code.SessionID); err != nil {
+			logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", *code.SessionID), zap.Error(err))
+		}
+	}
+	tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+}
+
+func refreshClientSession(c *gin.Context, client *db.OAuthClient) {
+	session, err := db.GetSessionByRefreshHash(c.Request.Context(), hashOpaqueToken(c.PostForm("refresh_token")))
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) ||
+		session.ClientID == nil || *session.ClientID != client.ClientID {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	// Mesmo tratamento do /auth/refresh: refresh token reapresentado revoga a família inteira
+	if session.RotatedAt != nil {
+		revokeReusedClientSession(c, session)
+		return
+	}
+
+	user, ok := activeUser(c, session.UserID)
+	if !ok {
+		return
+	}
+
+	// O usuário pode ter retirado o consentimento; apps do Mynance não dependem dele
+	scopes := grantedScopes(strings.Fields(session.Scope), client)
+	if !client.FirstParty {
+		consent, err := db.GetConsent(c.Request.Context(), user.ID, client.ClientID)
+		if err != nil {
+			tokenError(c, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		if consent == nil {
+			tokenError(c, http.StatusBadRequest, "invalid_grant", "Consent was revoked")
+			return
+		}
+	}
+
+	refreshToken, refreshTokenHash, err := newOpaqueToken()
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	session.Scope = strings.Join(scopes, " ")
+	err = db.RotateSession(c.Request.Context(), session, refreshTokenHash, c.ClientIP(), c.Request.UserAgent(), time.Now().Add(refreshTokenTTL))
+	if errors.Is(err, db.ErrSessionAlreadyRotated) {
+		revokeReusedClientSession(c, session)
+		return
+	}
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		logger.Ctx(c.Request.Context()).Error("Failed to rotate client session", zap.String("sessionID", session.FamilyID), zap.Error(err))
+		return
+	}
+
+	respondTokens(c, user, client, session.FamilyID, scopes, "", refreshToken)
+}
+
+func revokeReusedClientSession(c *gin.Context, session *db.Session) {
+	logger.Ctx(c.Request.Context()).Warn("Refresh token reuse detected, revoking session", zap.Int("userID", session.UserID), zap.String("sessionID", session.FamilyID),
+		zap.String("clientID", *session.ClientID), zap.String("ip", c.ClientIP()))
+
+	if err := db.RevokeSessionFamily(c.Request.Context(), session.FamilyID); err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", session.FamilyID), zap.Error(err))
+		return
+	}
+	tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+}
+
+// respondTokens signs the access and ID tokens of a client session and writes the token response
+func respondTokens(c *gin.Context, user *db.Profile, client *db.OAuthClient, sessionID string, scopes []string, nonce, refreshToken string) {
+	scope := strings.Join(scopes, " ")
+
+	tokenClaims, err := newUserClaims(c.Request.Context(), user.ID, user.Email, user.Role, sessionID)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	tokenClaims.ClientID = client.ClientID
+	tokenClaims.Scope = scope
+
+	accessToken, err := jwks.Keys.Sign(tokenClaims)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	idToken, err := newIDToken(user, client.ClientID, scopes, nonce, accessToken)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		Scope:        scope,
+	})
+}
+
+func newIDToken(user *db.Profile, clientID string, scopes []string, nonce, accessToken string) (string, error) {
+	now := time.Now()
+	// at_hash: metade esquerda do SHA-256 do access token (RS256)
+	sum := sha256.Sum256([]byte(accessToken))
+
+	idClaims := &idTokenClaims{
+		Issuer:     oidcIssuer(),
+		Subject:    strconv.Itoa(user.ID),
+		Audience:   clientID,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(accessTokenTTL).Unix(),
+		Nonce:      nonce,
+		AccessHash: base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]),
+	}
+	if slices.Contains(scopes, claims.ScopeProfile) {
+		idClaims.Name = user.Name
+	}
+	if slices.Contains(scopes, claims.ScopeEmail) {
+		// Só contas com e-mail confirmado conseguem fazer login
+		verified := true
+		idClaims.Email = user.Email
+		idClaims.EmailVerified = &verified
+	}
+	return jwks.Keys.Sign(idClaims)
+}
+
+// UserInfo returns the claims of the user behind an OAuth access token, limited to the granted scopes
+func UserInfo(c *gin.Context) {
+	token := bearerToken(c)
+	tokenClaims, err := ValidateClientToken(c.Request.Context(), token)
+	if token == "" || err != nil || !slices.Contains(strings.Fields(tokenClaims.Scope), claims.ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	user, err := db.GetProfileByID(c.Request.Context(), tokenClaims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if user == nil || !user.Active {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	scopes := strings.Fields(tokenClaims.Scope)
+	response := gin.H{"sub": strconv.Itoa(user.ID)}
+	if slices.Contains(scopes, claims.ScopeProfile) {
+		response["name"] = user.Name
+	}
+	if slices.Contains(scopes, claims.ScopeEmail) {
+		response["email"] = user.Email
+		response["email_verified"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// authenticateClient identifies the client by HTTP Basic or client_id/client_secret in the form.
+// Public clients only send client_id; PKCE is what protects their codes.
+func authenticateClient(c *gin.Context) (*db.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 2.3.1: as credenciais vêm codificadas como formulário
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := db.GetOAuthClient(c.Request.Context(), clientID)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return nil, false
+	}
+
+	valid := client != nil
+	if valid && client.SecretHash != "" {
+		valid = subtle.ConstantTimeCompare([]byte(hashOpaqueToken(secret)), []byte(client.SecretHash)) == 1
+	} else if valid && secret != "" {
+		// Cliente público não tem segredo: um segredo enviado indica configuração errada
+		valid = false
+	}
+	if !valid {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="mynance"`)
+		}
+		tokenError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return nil, false
+	}
+	return client, true
+}
+
+// activeUser loads the user of a grant, answering invalid_grant when the account was deactivated
+func activeUser(c *gin.Context, userID int) (*db.Profile, bool) {
+	user, err := db.GetProfileByID(c.Request.Context(), userID)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "")
+		return nil, false
+	}
+	if user == nil || !user.Active {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "User is inactive")
+		return nil, false
+	}
+	return user, true
+}
+
+// grantedScopes keeps the scopes the client is still allowed to have
+func grantedScopes(scopes []string, client *db.OAuthClient) []string {
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if slices.Contains(client.Scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// verifyCodeChallenge checks the PKCE verifier against the S256 challenge of the authorization request
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+func tokenError(c *gin.Context, status int, code, description string) {
+	response := gin.H{"error": code}
+	if description != "" {
+		response["error_description"] = description
+	}
+	c.JSON(status, response)
+}
+
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+const (
+	testClientID    = "budget-app"
+	testRedirectURI = "https://budget.example.com/callback"
+	testCode        = "authorization-code"
+	testVerifier    = "verifier-with-enough-entropy-for-the-test-0123456789"
+)
+
+// setupTokenEndpoint replaces db-auth and db-auth-admin with sqlmock; the public client is always found
+func setupTokenEndpoint(t *testing.T) (sqlmock.Sqlmock, *gin.Engine) {
+	t.Helper()
+
+	authDB, authMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminDB, adminMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousAuth, previousAdmin := postgres.DB, db.AdminDB
+	postgres.DB, db.AdminDB = authDB, adminDB
+	t.Cleanup(func() {
+		postgres.DB, db.AdminDB = previousAuth, previousAdmin
+		authDB.Close()
+		adminDB.Close()
+	})
+
+	adminMock.ExpectQuery("FROM oauth_clients").WithArgs(testClientID).
+		WillReturnRows(sqlmock.NewRows([]string{"client_id", "name", "secret_hash", "redirect_uris", "scopes", "first_party"}).
+			AddRow(testClientID, "Budget", "", "{"+testRedirectURI+"}", "{openid,banks:read}", false))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/oauth/token", Token)
+	return authMock, r
+}
+
+// expectCode returns the authorization code as stored, issued to testClientID for testRedirectURI
+func expectCode(mock sqlmock.Sqlmock, edit func(values []driver.Value)) {
+	sum := sha256.Sum256([]byte(testVerifier))
+	values := []driver.Value{testClientID, testUserID, testRedirectURI, "{openid}", "", base64.RawURLEncoding.EncodeToString(sum[:]),
+		time.Now().Add(time.Minute), nil, nil}
+	if edit != nil {
+		edit(values)
+	}
+	mock.ExpectQuery("FROM oauth_authorization_codes").WithArgs(hashOpaqueToken(testCode)).
+		WillReturnRows(sqlmock.NewRows([]string{"client_id", "user_id", "redirect_uri", "scopes", "nonce", "code_challenge", "expires_at", "used_at", "session_id"}).
+			AddRow(values...))
+}
+
+func exchange(r *gin.Engine, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func exchangeForm(edit func(form url.Values)) url.Values {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {testClientID},
+		"code":          {testCode},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+	if edit != nil {
+		edit(form)
+	}
+	return form
+}
+
+// TestExchangeRejectionsKeepTheCode checks that a request failing the checks does not consume the code:
+// sqlmock fails on the UPDATE that would mark it used, which none of these cases expect
+func TestExchangeRejectionsKeepTheCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code func(values []driver.Value)
+		form func(form url.Values)
+	}{
+		{"code of another client", func(values []driver.Value) { values[0] = "another-app" }, nil},
+		{"another redirect_uri", nil, func(form url.Values) { form.Set("redirect_uri", "https://evil.example.com/callback") }},
+		{"wrong code_verifier", nil, func(form url.Values) { form.Set("code_verifier", strings.Repeat("x", 43)) }},
+		{"no code_verifier", nil, func(form url.Values) { form.Del("code_verifier") }},
+		{"expired code", func(values []driver.Value) { values[6] = time.Now().Add(-time.Second) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, r := setupTokenEndpoint(t)
+			expectCode(mock, tt.code)
+
+			w := exchange(r, exchangeForm(tt.form))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+				t.Fatalf("status = %d, body %s; want invalid_grant", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestExchangeUsedCodeRevokesItsSession(t *testing.T) {
+	mock, r := setupTokenEndpoint(t)
+	expectCode(mock, func(values []driver.Value) {
+		values[7] = time.Now().Add(-time.Minute)
+		values[8] = "session-family"
+	})
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs("session-family").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if w := exchange(r, exchangeForm(nil)); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeCodeRedeemedConcurrently(t *testing.T) {
+	mock, r := setupTokenEndpoint(t)
+	expectCode(mock, nil)
+	// Entre a leitura e o UPDATE outra requisição trocou o código e abriu uma sessão
+	mock.ExpectExec("UPDATE oauth_authorization_codes SET used_at").WithArgs(hashOpaqueToken(testCode)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectCode(mock, func(values []driver.Value) {
+		values[7] = time.Now()
+		values[8] = "session-family"
+	})
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs("session-family").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if w := exchange(r, exchangeForm(nil)); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	// passwordResetCooldown is the least time between two reset emails to the same account
+	passwordResetCooldown = 2 * time.Minute
+	// passwordResetTimeout bounds the work ForgotPassword leaves running after answering
+	passwordResetTimeout = 10 * time.Second
+)
+
+// passwordResets tracks the resets ForgotPassword left running, so shutdown waits for them
+var passwordResets sync.WaitGroup
+
+type forgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordInput struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPassword emails a single-use reset link. The answer is the same whether the email exists or not and
+// is sent before the email is looked up, so its timing does not reveal which accounts exist either.
+func ForgotPassword(c *gin.Context) {
+	var input forgotPasswordInput
+	if err := c.BindJSON(&input); err != nil || input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	// O token é gerado em todo pedido: até a resposta o trabalho é o mesmo para qualquer e-mail
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Busca e gravação ficam fora da resposta; o contexto mantém o trace e o logger do pedido
+	ctx, ip := context.WithoutCancel(c.Request.Context()), c.ClientIP()
+	passwordResets.Add(1)
+	go func() {
+		defer passwordResets.Done()
+		requestPasswordReset(ctx, input.Email, token, tokenHash, ip)
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// WaitPasswordResets waits for the resets still being created; each one is bounded by passwordResetTimeout
+func WaitPasswordResets() {
+	passwordResets.Wait()
+}
+
+// requestPasswordReset creates the reset and queues its email when the email belongs to an active account
+func requestPasswordReset(ctx context.Context, email, token, tokenHash, ip string) {
+	ctx, cancel := context.WithTimeout(ctx, passwordResetTimeout)
+	defer cancel()
+
+	user, err := db.GetUserByEmail(ctx, email)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to look up user for password reset", zap.Error(err))
+		return
+	}
+	if user == nil || !user.Active {
+		return
+	}
+
+	msg := passwordResetEmail(user.Name, user.Email, token)
+	queued, err := db.CreatePasswordReset(ctx, user.ID, tokenHash, ip, time.Now().Add(passwordResetTTL), passwordResetCooldown, msg)
+	if err != nil {
+		logger.Ctx(ctx).Error("Failed to create password reset", zap.Int("userID", user.ID), zap.Error(err))
+		return
+	}
+	if queued {
+		logger.Ctx(ctx).Info("Password reset requested", zap.Int("userID", user.ID))
+	}
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func ResetPassword(c *gin.Context) {
+	var input resetPasswordInput
+	if err := c.BindJSON(&input); err != nil || input.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	// A política compara a senha com o nome e o e-mail do dono do token
+	owner, err := db.GetPasswordResetUser(c.Request.Context(), hashOpaqueToken(input.Token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if owner == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if !checkPassword(c, input.Password, owner.Email, owner.Name) {
+		return
+	}
+
+	userID, err := db.ResetPassword(c.Request.Context(), hashOpaqueToken(input.Token), input.Password, passwordChangedEmail)
+	if err != nil {
+		if errors.Is(err, db.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		logger.Ctx(c.Request.Context()).Error("Failed to reset password", zap.Error(err))
+		return
+	}
+
+	clearSessionCookies(c)
+
+	logger.Ctx(c.Request.Context()).Info("Password reset", zap.Int("userID", userID))
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+func passwordResetEmail(name, email, token string) mailer.Message {
+	link := os.Getenv("PASSWORD_RESET_URL") + "?token=" + url.QueryEscape(token)
+	return mailer.Message{
+		To:      email,
+		Subject: "Reset your Mynance password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your Mynance password. "+
+			"Use the link below within %d minutes:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n", name, int(passwordResetTTL.Minutes()), link),
+	}
+}
+
+func passwordChangedEmail(user *db.Profile) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Your Mynance password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nYour Mynance password was just reset and every device was signed out. "+
+			"If this wasn't you, contact support immediately.\n", user.Name),
+	}
+}
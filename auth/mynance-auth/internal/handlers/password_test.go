@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func forgotPassword(email string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/password/forgot", ForgotPassword)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/password/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func expectUserByEmail(mock sqlmock.Sqlmock, email string, found bool) {
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "active", "created_at", "last_password_change", "role", "status", "email_verified_at"})
+	if found {
+		rows.AddRow(testUserID, "Ana", email, "", true, time.Now(), time.Now().Add(-time.Hour), "user", "active", time.Now())
+	}
+	mock.ExpectQuery("FROM users WHERE email").WithArgs(email).WillReturnRows(rows)
+}
+
+func TestForgotPasswordQueuesTheEmailBeforeShutdown(t *testing.T) {
+	mock := setupSessions(t)
+	expectUserByEmail(mock, "ana@example.com", true)
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(testUserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM password_resets").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("DELETE FROM password_resets").WithArgs(testUserID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO password_resets").WillReturnResult(sqlmock.NewResult(1, 1))
+	// A gravação demora mais que a resposta: só o WaitPasswordResets garante que ela terminou
+	mock.ExpectExec("INSERT INTO email_outbox").WithArgs("ana@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillDelayFor(100 * time.Millisecond).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if w := forgotPassword("ana@example.com"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	WaitPasswordResets()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForgotPasswordAnswersTheSameForUnknownEmails(t *testing.T) {
+	mock := setupSessions(t)
+	expectUserByEmail(mock, "ana@example.com", true)
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(testUserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Dentro do intervalo mínimo nada é gravado
+	mock.ExpectQuery("FROM password_resets").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	expectUserByEmail(mock, "nobody@example.com", false)
+
+	known := forgotPassword("ana@example.com")
+	WaitPasswordResets()
+	unknown := forgotPassword("nobody@example.com")
+	WaitPasswordResets()
+
+	if known.Code != http.StatusOK || unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Fatalf("known email: %d %s; unknown email: %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func Refresh(c *gin.Context) {
+	refreshCookie, err := c.Request.Cookie(refreshCookieName)
+	if err != nil || refreshCookie.Value == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing refresh token"})
+		return
+	}
+
+	session, err := db.GetSessionByRefreshHash(c.Request.Context(), hashOpaqueToken(refreshCookie.Value))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	// Sessões de clientes OAuth só são renovadas no /oauth/token, com os escopos concedidos
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) || session.ClientID != nil {
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Um refresh token já trocado foi apresentado de novo: a família inteira é considerada comprometida
+	if session.RotatedAt != nil {
+		revokeReusedSession(c, session.FamilyID, session.UserID)
+		return
+	}
+
+	user, err := db.GetProfileByID(c.Request.Context(), session.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if user == nil || !user.Active {
+		if err := db.RevokeSessionFamily(c.Request.Context(), session.FamilyID); err != nil {
+			logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", session.FamilyID), zap.Error(err))
+		}
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	refreshToken, refreshTokenHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	err = db.RotateSession(c.Request.Context(), session, refreshTokenHash, c.ClientIP(), c.Request.UserAgent(), time.Now().Add(refreshTokenTTL))
+	if errors.Is(err, db.ErrSessionAlreadyRotated) {
+		revokeReusedSession(c, session.FamilyID, session.UserID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		logger.Ctx(c.Request.Context()).Error("Failed to rotate session", zap.String("sessionID", session.FamilyID), zap.Error(err))
+		return
+	}
+
+	token, err := GenerateToken(c.Request.Context(), user.ID, user.Email, user.Role, session.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	setSessionCookies(c, token, refreshToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Token refreshed successfully"})
+}
+
+func revokeReusedSession(c *gin.Context, familyID string, userID int) {
+	logger.Ctx(c.Request.Context()).Warn("Refresh token reuse detected, revoking session", zap.Int("userID", userID), zap.String("sessionID", familyID), zap.String("ip", c.ClientIP()))
+
+	if err := db.RevokeSessionFamily(c.Request.Context(), familyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke session", zap.String("sessionID", familyID), zap.Error(err))
+		return
+	}
+
+	clearSessionCookies(c)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/postgres"
+)
+
+const (
+	testRefreshToken = "current-refresh-token"
+	testFamilyID     = "5b0f1a52-8a39-4c3e-9d6c-0d3f5c1e2a41"
+)
+
+// setupSessions replaces db-auth with sqlmock and signs with an ephemeral key
+func setupSessions(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	authDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := postgres.DB
+	postgres.DB = authDB
+	t.Cleanup(func() {
+		postgres.DB = previous
+		authDB.Close()
+	})
+
+	if jwks.Keys == nil {
+		t.Setenv("JWT_KEYS_DIR", "")
+		jwks.Init()
+	}
+	return mock
+}
+
+// expectSession returns the session that owns testRefreshToken; edit changes the row as stored
+func expectSession(mock sqlmock.Sqlmock, edit func(values []driver.Value)) {
+	values := []driver.Value{1, testFamilyID, testUserID, hashOpaqueToken(testRefreshToken), "203.0.113.1", "Firefox",
+		time.Now().Add(-time.Hour), time.Now().Add(time.Hour), nil, nil, nil, ""}
+	if edit != nil {
+		edit(values)
+	}
+	mock.ExpectQuery("FROM sessions WHERE refresh_token_hash").WithArgs(hashOpaqueToken(testRefreshToken)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "family_id", "user_id", "refresh_token_hash", "ip", "user_agent",
+			"created_at", "expires_at", "rotated_at", "revoked_at", "client_id", "scope"}).AddRow(values...))
+}
+
+func expectProfile(mock sqlmock.Sqlmock, active bool) {
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "active", "created_at"}).
+			AddRow(testUserID, "Ana", "ana@example.com", "user", active, time.Now()))
+}
+
+func expectLastPasswordChange(mock sqlmock.Sqlmock, at time.Time) {
+	mock.ExpectQuery("SELECT last_password_change FROM users").WithArgs(testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"last_password_change"}).AddRow(at))
+}
+
+func expectFamilyRevoked(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\) WHERE family_id").WithArgs(testFamilyID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func refresh() *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/auth/refresh", Refresh)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshCookieName, Value: testRefreshToken})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	mock := setupSessions(t)
+	lastPasswordChange := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	expectSession(mock, nil)
+	expectProfile(mock, true)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE sessions SET rotated_at = NOW\\(\\) WHERE id = \\$1 AND rotated_at IS NULL AND revoked_at IS NULL").WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// O sucessor fica na mesma família, com outro refresh token
+	mock.ExpectExec("INSERT INTO sessions").
+		WithArgs(testFamilyID, testUserID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	expectLastPasswordChange(mock, lastPasswordChange)
+
+	w := refresh()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := cookie(w, refreshCookieName)
+	if rotated == nil || rotated.Value == "" || rotated.Value == testRefreshToken {
+		t.Fatalf("refresh cookie = %+v, want a new token", rotated)
+	}
+	access := cookie(w, "token")
+	if access == nil {
+		t.Fatal("no access token cookie")
+	}
+	tokenClaims := &Claims{}
+	if _, err := jwt.ParseWithClaims(access.Value, tokenClaims, jwks.Keys.Keyfunc); err != nil {
+		t.Fatal(err)
+	}
+	if tokenClaims.SessionID != testFamilyID || tokenClaims.LastPasswordChange != lastPasswordChange.Unix() {
+		t.Fatalf("access token of session %q with last password change %d", tokenClaims.SessionID, tokenClaims.LastPasswordChange)
+	}
+}
+
+func TestRefreshReuseRevokesTheFamily(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		{"token already rotated", func(mock sqlmock.Sqlmock) {
+			expectSession(mock, func(values []driver.Value) { values[8] = time.Now().Add(-time.Minute) })
+		}},
+		// Duas requisições leram o mesmo token antes de qualquer uma rotacioná-lo
+		{"token rotated concurrently", func(mock sqlmock.Sqlmock) {
+			expectSession(mock, nil)
+			expectProfile(mock, true)
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE sessions SET rotated_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := setupSessions(t)
+			tt.expect(mock)
+			expectFamilyRevoked(mock)
+
+			w := refresh()
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+			if cleared := cookie(w, refreshCookieName); cleared == nil || cleared.MaxAge >= 0 {
+				t.Fatalf("refresh cookie = %+v, want it cleared", cleared)
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsUnusableSessions(t *testing.T) {
+	clientID := "budget-app"
+
+	tests := []struct {
+		name    string
+		session func(values []driver.Value)
+		revoke  bool
+	}{
+		{"expired", func(values []driver.Value) { values[7] = time.Now().Add(-time.Second) }, false},
+		{"revoked", func(values []driver.Value) { values[9] = time.Now().Add(-time.Minute) }, false},
+		{"session of an OAuth client", func(values []driver.Value) { values[10] = clientID }, false},
+		{"deactivated user", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := setupSessions(t)
+			expectSession(mock, tt.session)
+			if tt.revoke {
+				expectProfile(mock, false)
+				expectFamilyRevoked(mock)
+			}
+
+			w := refresh()
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			// Nada é rotacionado: qualquer outra consulta falharia no sqlmock
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		mock := setupSessions(t)
+		mock.ExpectQuery("FROM sessions WHERE refresh_token_hash").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if w := refresh(); w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestValidateTokenAfterPasswordChange(t *testing.T) {
+	mock := setupSessions(t)
+	issuedWith := time.Now().Add(-time.Hour).Truncate(time.Second)
+	expectLastPasswordChange(mock, issuedWith)
+	token, err := GenerateToken(t.Context(), testUserID, "ana@example.com", "user", testFamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name               string
+		lastPasswordChange time.Time
+		sessionActive      bool
+		valid              bool
+	}{
+		{"unchanged password", issuedWith, true, true},
+		{"password changed after the token", issuedWith.Add(time.Minute), true, false},
+		{"session revoked", issuedWith, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectLastPasswordChange(mock, tt.lastPasswordChange)
+			if !tt.lastPasswordChange.After(issuedWith) {
+				mock.ExpectQuery("SELECT EXISTS").WithArgs(testFamilyID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.sessionActive))
+			}
+
+			_, err := ValidateToken(t.Context(), token)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ValidateToken error = %v, want valid %v", err, tt.valid)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
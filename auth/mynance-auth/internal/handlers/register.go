@@ -5,20 +5,25 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/logger"
 	"go.uber.org/zap"
 )
 
 func Register(c *gin.Context) {
-	var user postgres.User
+	var user db.User
 
 	if err := c.BindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	exists, err := db.UserExists(user.Email)
+	if !isValidEmail(user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	exists, err := db.UserExists(c.Request.Context(), user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -28,18 +33,20 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	if !isValidPassword(user.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long, include an uppercase letter, a lowercase letter, a number, and a special character."})
+	if !checkPassword(c, user.Password, user.Email, user.Name) {
 		return
 	}
 
-	userID, err := db.CreateUser(user.Name, user.Email, user.Password)
+	// A conta nasce pendente e o link de verificação sai pela outbox na mesma transação
+	userID, err := db.CreateUser(c.Request.Context(), user.Name, user.Email, user.Password, func(userID int) (mailer.Message, error) {
+		return verificationEmail(userID, user.Name, user.Email)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		logger.Log.Error("Failed to create user", zap.String("userName", user.Name), zap.String("userEmail", user.Email), zap.Error(err))
+		logger.Ctx(c.Request.Context()).Error("Failed to create user", zap.String("userName", user.Name), zap.String("userEmail", user.Email), zap.Error(err))
 		return
 	}
 
-	logger.Log.Info("User registered successfully", zap.Int("userID", userID))
-	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+	logger.Ctx(c.Request.Context()).Info("User registered successfully", zap.Int("userID", userID))
+	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully, check your email to verify your account"})
 }
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLoginHistoryLimit = 50
+	maxLoginHistoryLimit     = 200
+)
+
+type sessionResponse struct {
+	db.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the devices where the user is signed in
+func ListSessions(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	sessions, err := db.GetActiveSessions(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{Session: session, Current: session.FamilyID == user.SessionID})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// RevokeSession signs one of the user's devices out
+func RevokeSession(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+	sessionID := c.Param("id")
+
+	revoked, err := db.RevokeUserSession(c.Request.Context(), user.UserID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Encerrar a própria sessão equivale a um logout
+	if sessionID == user.SessionID {
+		clearSessionCookies(c)
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Session revoked by user", zap.Int("userID", user.UserID), zap.String("sessionID", sessionID))
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions signs the user out of every device, including the current one
+func RevokeAllSessions(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	if err := db.RevokeAllUserSessions(c.Request.Context(), user.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		logger.Ctx(c.Request.Context()).Error("Failed to revoke all sessions", zap.Int("userID", user.UserID), zap.Error(err))
+		return
+	}
+
+	clearSessionCookies(c)
+
+	logger.Ctx(c.Request.Context()).Info("User signed out everywhere", zap.Int("userID", user.UserID))
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out of all sessions"})
+}
+
+// LoginHistory returns the latest successful and failed logins on the user's account
+func LoginHistory(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	limit := defaultLoginHistoryLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxLoginHistoryLimit)
+	}
+
+	history, err := db.GetLoginHistory(c.Request.Context(), user.UserID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logins": history})
+}
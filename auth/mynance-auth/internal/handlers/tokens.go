@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	maxPersonalAccessTokens   = 20
+	maxPersonalAccessTokenTTL = 365 // dias
+	// personalAccessTokenPrefixLength characters are kept in clear to tell tokens apart in the list
+	personalAccessTokenPrefixLength = 12
+)
+
+type createTokenInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays is optional; without it the token is valid until revoked
+	ExpiresInDays int `json:"expiresInDays"`
+}
+
+// CreatePersonalAccessToken issues a named, scoped token for scripts; the token is only shown in this response
+func CreatePersonalAccessToken(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var input createTokenInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required and must have at most 100 characters"})
+		return
+	}
+
+	if len(input.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one scope is required", "validScopes": validScopes()})
+		return
+	}
+	for _, scope := range input.Scopes {
+		if !claims.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope, "validScopes": validScopes()})
+			return
+		}
+	}
+
+	if input.ExpiresInDays < 0 || input.ExpiresInDays > maxPersonalAccessTokenTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInDays must be between 1 and " + strconv.Itoa(maxPersonalAccessTokenTTL) + ", or omitted for no expiry"})
+		return
+	}
+	var expiresAt *time.Time
+	if input.ExpiresInDays > 0 {
+		expiry := time.Now().Add(time.Duration(input.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &expiry
+	}
+
+	secret, _, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	token := claims.PersonalAccessTokenPrefix + secret
+
+	created, err := db.CreatePersonalAccessToken(c.Request.Context(), user.UserID, input.Name, hashOpaqueToken(token), token[:personalAccessTokenPrefixLength],
+		input.Scopes, expiresAt, maxPersonalAccessTokens)
+	if err != nil {
+		if errors.Is(err, db.ErrTooManyTokens) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Too many active tokens, revoke one first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		logger.Ctx(c.Request.Context()).Error("Failed to create personal access token", zap.Int("userID", user.UserID), zap.Error(err))
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Personal access token created", zap.Int("userID", user.UserID), zap.Int("tokenID", created.ID), zap.Strings("scopes", created.Scopes))
+	c.JSON(http.StatusCreated, gin.H{"token": token, "details": created})
+}
+
+// ListPersonalAccessTokens returns the user's active tokens, without the token values
+func ListPersonalAccessTokens(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	tokens, err := db.GetPersonalAccessTokens(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// RevokePersonalAccessToken revokes one of the user's tokens
+func RevokePersonalAccessToken(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token id"})
+		return
+	}
+
+	revoked, err := db.RevokePersonalAccessToken(c.Request.Context(), user.UserID, tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Personal access token revoked", zap.Int("userID", user.UserID), zap.Int("tokenID", tokenID))
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
+
+func validScopes() []string {
+	scopes := make([]string, 0, len(claims.ScopeResources)*2)
+	for _, resource := range claims.ScopeResources {
+		scopes = append(scopes, resource+":"+claims.ScopeRead, resource+":"+claims.ScopeWrite)
+	}
+	return scopes
+}
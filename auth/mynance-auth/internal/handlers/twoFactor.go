@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/pkg/totp"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	totpIssuer         = "Mynance"
+	mfaChallengeTTL    = 5 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type twoFactorCodeInput struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type loginTwoFactorInput struct {
+	MFAToken string `json:"mfaToken"`
+	twoFactorCodeInput
+}
+
+// EnrollTwoFactor creates a pending TOTP secret and returns what the authenticator app needs
+func EnrollTwoFactor(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	sealedSecret, err := totp.Encrypt(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		logger.Ctx(c.Request.Context()).Error("Failed to encrypt TOTP secret", zap.Error(err))
+		return
+	}
+
+	saved, err := db.SavePendingTOTP(c.Request.Context(), user.UserID, sealedSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !saved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	uri := totp.ProvisioningURI(totpIssuer, user.Email, secret)
+	c.JSON(http.StatusOK, gin.H{
+		"secret":          secret,
+		"provisioningUri": uri,
+		// Conteúdo a ser desenhado como QR code pelo frontend
+		"qrPayload": uri,
+	})
+}
+
+// ActivateTwoFactor confirms the enrollment with a first code and returns the recovery codes, shown only once
+func ActivateTwoFactor(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil || input.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	enrollment, err := db.GetTOTP(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enrollment == nil || enrollment.EnabledAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No pending two-factor enrollment"})
+		return
+	}
+
+	secret, err := totp.Decrypt(enrollment.Secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read secret"})
+		logger.Ctx(c.Request.Context()).Error("Failed to decrypt TOTP secret", zap.Int("userID", user.UserID), zap.Error(err))
+		return
+	}
+
+	step, ok := totp.Validate(secret, input.Code, time.Now(), enrollment.LastUsedStep)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := db.ActivateTOTP(c.Request.Context(), user.UserID, step, hashes); err != nil {
+		if errors.Is(err, db.ErrTwoFactorNotPending) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No pending two-factor enrollment"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Two-factor authentication enabled", zap.Int("userID", user.UserID))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recoveryCodes": codes})
+}
+
+// DisableTwoFactor turns 2FA off after checking a current code or a recovery code
+func DisableTwoFactor(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if err := verifySecondFactor(c.Request.Context(), user.UserID, input); err != nil {
+		secondFactorError(c, user.UserID, err)
+		return
+	}
+
+	if err := db.DisableTOTP(c.Request.Context(), user.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Two-factor authentication disabled", zap.Int("userID", user.UserID))
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a current code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user := c.MustGet("claims").(*Claims)
+
+	var input twoFactorCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	// Só o código do app: um código de recuperação não deve gerar novos códigos
+	input.RecoveryCode = ""
+
+	if err := verifySecondFactor(c.Request.Context(), user.UserID, input); err != nil {
+		secondFactorError(c, user.UserID, err)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := db.RegenerateRecoveryCodes(c.Request.Context(), user.UserID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// LoginTwoFactor is the second login step: it exchanges the MFA challenge and a code for a session
+func LoginTwoFactor(c *gin.Context) {
+	var input loginTwoFactorInput
+	if err := c.BindJSON(&input); err != nil || input.MFAToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	challenge, err := parseMFAChallenge(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login attempt"})
+		return
+	}
+
+	if !loginGuard.Allowed(c, challenge.Email) {
+		return
+	}
+
+	// Códigos errados contam como falhas de login da conta
+	if err := verifySecondFactor(c.Request.Context(), challenge.UserID, input.twoFactorCodeInput); err != nil {
+		if errors.Is(err, errInvalidCode) {
+			loginGuard.Failed(c, challenge.Email, &challenge.UserID)
+			return
+		}
+		secondFactorError(c, challenge.UserID, err)
+		return
+	}
+
+	startSession(c, challenge.UserID, challenge.Email, challenge.Role)
+}
+
+var (
+	errInvalidCode         = errors.New("invalid code")
+	errMissingCode         = errors.New("missing code")
+	errTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+)
+
+// verifySecondFactor validates a TOTP code or consumes a recovery code
+func verifySecondFactor(ctx context.Context, userID int, input twoFactorCodeInput) error {
+	if input.Code == "" && input.RecoveryCode == "" {
+		return errMissingCode
+	}
+
+	if input.Code == "" {
+		used, err := db.UseRecoveryCode(ctx, userID, hashOpaqueToken(normalizeRecoveryCode(input.RecoveryCode)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errInvalidCode
+		}
+		logger.Ctx(ctx).Info("Recovery code used", zap.Int("userID", userID))
+		return nil
+	}
+
+	enrollment, err := db.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enrollment == nil || enrollment.EnabledAt == nil {
+		return errTwoFactorNotEnabled
+	}
+
+	secret, err := totp.Decrypt(enrollment.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, input.Code, time.Now(), enrollment.LastUsedStep)
+	if !ok {
+		return errInvalidCode
+	}
+
+	// Grava o passo usado: o mesmo código não serve duas vezes
+	fresh, err := db.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errInvalidCode
+	}
+	return nil
+}
+
+// secondFactorError writes the response for an error returned by verifySecondFactor
+func secondFactorError(c *gin.Context, userID int, err error) {
+	switch {
+	case errors.Is(err, errMissingCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	case errors.Is(err, errTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+	case errors.Is(err, errInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		logger.Ctx(c.Request.Context()).Error("Failed to verify second factor", zap.Int("userID", userID), zap.Error(err))
+	}
+}
+
+func newMFAChallenge(userID int, email, role string) (string, error) {
+	tokenClaims, err := claims.New(claims.TypeMFAChallenge, claims.IssuerUser, []string{claims.AudienceAuth}, userID, email, role, mfaChallengeTTL)
+	if err != nil {
+		return "", err
+	}
+	return jwks.Keys.Sign(tokenClaims)
+}
+
+func parseMFAChallenge(tokenString string) (*Claims, error) {
+	tokenClaims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, tokenClaims, jwks.Keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := tokenClaims.Expect(claims.TypeMFAChallenge, claims.IssuerUser, claims.AudienceAuth); err != nil {
+		return nil, err
+	}
+	return tokenClaims, nil
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:recoveryCodeLength]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashOpaqueToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -1,74 +1,188 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"os"
-	"regexp"
+	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
 )
 
-type Claims struct {
-	UserID             int    `json:"userId"`
-	Email              string `json:"email"`
-	Role               string `json:"role"`
-	LastPasswordChange int64  `json:"lastPasswordChange"`
-	jwt.StandardClaims
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	refreshCookieName = "refresh_token"
+	refreshCookiePath = "/auth"
+)
+
+// Claims is the shared token payload, see mynance-platform/claims
+type Claims = claims.Claims
+
+func isValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email && len(email) <= 100
 }
 
-func isValidPassword(password string) bool {
-	var (
-		upperCase = regexp.MustCompile(`[A-Z]`)
-		lowerCase = regexp.MustCompile(`[a-z]`)
-		digit     = regexp.MustCompile(`\d`)
-		special   = regexp.MustCompile(`[!@#$%^&*(),.?":{}|<>]`)
-	)
-	return len(password) >= 8 && upperCase.MatchString(password) && lowerCase.MatchString(password) && digit.MatchString(password) && special.MatchString(password)
+// checkPassword answers 400 with every policy rule the password breaks; personal is the user's email and name
+func checkPassword(c *gin.Context, password string, personal ...string) bool {
+	violations := passwordpolicy.Check(password, personal...)
+	if len(violations) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the policy", "violations": violations})
+	return false
 }
 
-func GenerateToken(userID int, email, role string) (string, error) {
-	lastPasswordChange, err := db.UserLastPasswordChange(userID)
+func GenerateToken(ctx context.Context, userID int, email, role, sessionID string) (string, error) {
+	tokenClaims, err := newUserClaims(ctx, userID, email, role, sessionID)
 	if err != nil {
 		return "", err
 	}
+	return jwks.Keys.Sign(tokenClaims)
+}
 
-	exp := time.Now().Add(24 * time.Hour).Unix()
+// newUserClaims fills the claims of an access token bound to a session
+func newUserClaims(ctx context.Context, userID int, email, role, sessionID string) (*Claims, error) {
+	lastPasswordChange, err := db.UserLastPasswordChange(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	claims := jwt.MapClaims{
-		"userId":             userID,
-		"email":              email,
-		"role":               role,
-		"lastPasswordChange": lastPasswordChange.Unix(),
-		"exp":                exp,
+	tokenClaims, err := claims.New(claims.TypeUser, claims.IssuerUser, claims.UserAudience(), userID, email, role, accessTokenTTL)
+	if err != nil {
+		return nil, err
 	}
+	tokenClaims.SessionID = sessionID
+	tokenClaims.LastPasswordChange = lastPasswordChange.Unix()
+	return tokenClaims, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// ValidateToken verifies and returns the claims if the token is valid.
+// Tokens issued to OAuth clients are refused: they must not reach the account routes nor be renewed as a full session.
+func ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	tokenClaims, err := verifyUserToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if tokenClaims.ClientID != "" {
+		return nil, errors.New("token issued to an OAuth client")
+	}
+	return tokenClaims, nil
 }
 
-// ValidateToken verifies and returns the claims if the token is valid
-func ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateClientToken verifies an access token issued to an OAuth client
+func ValidateClientToken(ctx context.Context, tokenString string) (*Claims, error) {
+	tokenClaims, err := verifyUserToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if tokenClaims.ClientID == "" {
+		return nil, errors.New("token not issued to an OAuth client")
+	}
+	return tokenClaims, nil
+}
+
+func verifyUserToken(ctx context.Context, tokenString string) (*Claims, error) {
+	tokenClaims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, tokenClaims, jwks.Keys.Keyfunc)
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
+	// Só tokens de usuário emitidos por este serviço valem aqui
+	if err := tokenClaims.Expect(claims.TypeUser, claims.IssuerUser, claims.AudienceAuth); err != nil {
+		return nil, err
+	}
+
 	// Pegar a data da última alteração de senha do banco de dados
-	lastPasswordChange, err := db.UserLastPasswordChange(claims.UserID)
+	lastPasswordChange, err := db.UserLastPasswordChange(ctx, tokenClaims.UserID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Se o token foi gerado antes da última alteração de senha, ele é inválido
-	if claims.LastPasswordChange < lastPasswordChange.Unix() {
+	if tokenClaims.LastPasswordChange < lastPasswordChange.Unix() {
 		return nil, errors.New("token invalid due to password change")
 	}
 
-	return claims, nil
+	// Tokens de sessões revogadas (logout, reuso de refresh token) deixam de valer
+	active, err := db.SessionActive(ctx, tokenClaims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, errors.New("session revoked")
+	}
+
+	return tokenClaims, nil
+}
+
+// newOpaqueToken returns a random token (refresh, password reset) and the hash stored in the database
+func newOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// setSessionCookies writes the access and refresh token cookies
+func setSessionCookies(c *gin.Context, accessToken, refreshToken string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "token",
+		Value:    accessToken,
+		Expires:  time.Now().Add(accessTokenTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    refreshToken,
+		Expires:  time.Now().Add(refreshTokenTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     refreshCookiePath,
+	})
+}
+
+// clearSessionCookies removes the access and refresh token cookies
+func clearSessionCookies(c *gin.Context) {
+	for _, cookie := range []struct{ name, path string }{
+		{"token", "/"},
+		{refreshCookieName, refreshCookiePath},
+	} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			MaxAge:   -1,
+			Expires:  time.Unix(0, 0),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	// verificationResendCooldown is the least time between two verification emails to the same account
+	verificationResendCooldown = 2 * time.Minute
+)
+
+type verifyEmailInput struct {
+	Token string `json:"token"`
+}
+
+type resendVerificationInput struct {
+	Email string `json:"email"`
+}
+
+// VerifyEmail confirms the address from the signed link sent on registration
+func VerifyEmail(c *gin.Context) {
+	var input verifyEmailInput
+	if err := c.BindJSON(&input); err != nil || input.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	tokenClaims, err := parseVerificationToken(input.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+
+	// O e-mail do token precisa ser o atual: um link antigo não confirma um endereço novo
+	verified, err := db.VerifyEmail(c.Request.Context(), tokenClaims.UserID, tokenClaims.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !verified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Info("Email verified", zap.Int("userID", tokenClaims.UserID))
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification sends a new verification link; the response does not reveal whether the email exists
+func ResendVerification(c *gin.Context) {
+	var input resendVerificationInput
+	if err := c.BindJSON(&input); err != nil || input.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user, err := db.GetUserByEmail(c.Request.Context(), input.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if user != nil && user.Active && user.Status == "pending_verification" {
+		msg, err := verificationEmail(user.ID, user.Name, user.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		queued, err := db.QueueVerificationEmail(c.Request.Context(), user.ID, verificationResendCooldown, msg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+			logger.Ctx(c.Request.Context()).Error("Failed to queue verification email", zap.Int("userID", user.ID), zap.Error(err))
+			return
+		}
+		if queued {
+			logger.Ctx(c.Request.Context()).Info("Verification email resent", zap.Int("userID", user.ID))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account is pending verification, a new link has been sent"})
+}
+
+// verificationEmail builds the email with a signed link that confirms the address
+func verificationEmail(userID int, name, email string) (mailer.Message, error) {
+	tokenClaims, err := claims.New(claims.TypeEmailVerification, claims.IssuerUser, []string{claims.AudienceAuth}, userID, email, "", emailVerificationTTL)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	token, err := jwks.Keys.Sign(tokenClaims)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+
+	link := os.Getenv("EMAIL_VERIFICATION_URL") + "?token=" + url.QueryEscape(token)
+	return mailer.Message{
+		To:      email,
+		Subject: "Confirm your Mynance email",
+		Body: fmt.Sprintf("Hi %s,\n\nWelcome to Mynance! Confirm your email address within %d hours using the link below:\n\n%s\n",
+			name, int(emailVerificationTTL.Hours()), link),
+	}, nil
+}
+
+func parseVerificationToken(tokenString string) (*Claims, error) {
+	tokenClaims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, tokenClaims, jwks.Keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := tokenClaims.Expect(claims.TypeEmailVerification, claims.IssuerUser, claims.AudienceAuth); err != nil {
+		return nil, err
+	}
+	return tokenClaims, nil
+}
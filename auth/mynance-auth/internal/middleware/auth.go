@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Auth validates the access token cookie with validate and stores its claims in the context
+func Auth(validate func(ctx context.Context, token string) (*claims.Claims, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenCookie, err := c.Cookie("token")
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - No token provided"})
+			c.Abort()
+			return
+		}
+
+		tokenClaims, err := validate(c.Request.Context(), tokenCookie)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - " + err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("claims", tokenClaims)
+		c.Set("userId", tokenClaims.UserID)
+		c.Set("userEmail", tokenClaims.Email)
+		c.Request = c.Request.WithContext(logger.WithFields(c.Request.Context(), zap.Int("userID", tokenClaims.UserID)))
+
+		c.Next()
+	}
+}
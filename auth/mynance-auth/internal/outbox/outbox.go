@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jvlerner/my-finance-api/internal/db"
+	"github.com/jvlerner/my-finance-api/pkg/mailer"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	batchSize    = 20
+	maxAttempts  = 8
+)
+
+var (
+	stop     = make(chan struct{})
+	done     = make(chan struct{})
+	stopOnce sync.Once
+)
+
+// Start delivers queued emails in the background, retrying failures with exponential backoff
+func Start(m mailer.Mailer) {
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				deliver(m)
+			}
+		}
+	}()
+}
+
+// Stop ends the delivery loop, waiting for the batch being sent
+func Stop() {
+	stopOnce.Do(func() {
+		close(stop)
+	})
+	<-done
+}
+
+func deliver(m mailer.Mailer) {
+	ctx := context.Background()
+	emails, err := db.ClaimPendingEmails(ctx, batchSize, maxAttempts)
+	if err != nil {
+		logger.Log.Error("Failed to load pending emails", zap.Error(err))
+		return
+	}
+
+	for _, email := range emails {
+		err := m.Send(mailer.Message{To: email.Recipient, Subject: email.Subject, Body: email.Body})
+		if err == nil {
+			if err := db.MarkEmailSent(ctx, email.ID); err != nil {
+				logger.Log.Error("Failed to mark email as sent", zap.Int("emailID", email.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		// 30s, 1min, 2min... até desistir em maxAttempts
+		retryIn := 30 * time.Second << (email.Attempts - 1)
+		logger.Log.Warn("Failed to send email", zap.Int("emailID", email.ID), zap.Int("attempt", email.Attempts), zap.Error(err))
+		if err := db.MarkEmailFailed(ctx, email.ID, err, retryIn); err != nil {
+			logger.Log.Error("Failed to reschedule email", zap.Int("emailID", email.ID), zap.Error(err))
+		}
+	}
+}
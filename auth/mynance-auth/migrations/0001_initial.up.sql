@@ -1,4 +1,7 @@
-CREATE TABLE users (
+-- Esquema do init.sql original de dbs/auth/db-auth. IF NOT EXISTS: bancos já criados por ele registram esta
+-- versão sem mudanças e recebem o resto pelas migrações seguintes
+
+CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     email VARCHAR(100) UNIQUE NOT NULL,
@@ -10,17 +13,16 @@ CREATE TABLE users (
 );
 
 -- Já cobre autenticação e recuperação de dados
-CREATE UNIQUE INDEX idx_users_email ON users(email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email);
 
 -- Acesso frequente por ID
-CREATE INDEX idx_users_id ON users(id);
+CREATE INDEX IF NOT EXISTS idx_users_id ON users(id);
 
 -- Se você quiser listar usuários por status (ativos/inativos)
-CREATE INDEX idx_users_active ON users(active);
+CREATE INDEX IF NOT EXISTS idx_users_active ON users(active);
 
 -- Se você listar usuários por tipo de conta
-CREATE INDEX idx_users_role ON users(role);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
 
 -- Se gerar relatórios ou ordenar por criação
-CREATE INDEX idx_users_created_at ON users(created_at);
-
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
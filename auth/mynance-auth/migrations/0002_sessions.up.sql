@@ -0,0 +1,19 @@
+-- Sessões de login: cada linha é um refresh token; linhas da mesma família pertencem ao mesmo login
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    user_id INT NOT NULL REFERENCES users(id),
+    refresh_token_hash CHAR(64) UNIQUE NOT NULL,
+    ip VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    rotated_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Validação de tokens e revogação da família inteira
+CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions(family_id);
+
+-- Listagem e revogação das sessões de um usuário
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
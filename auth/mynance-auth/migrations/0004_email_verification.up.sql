@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMP;
+
+-- Contas de antes da verificação por e-mail entram como verificadas; sem isso nenhuma delas faria login.
+-- Se a coluna já existe (banco criado depois da verificação), os status atuais são mantidos.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'verified'
+    CHECK (status IN ('pending_verification', 'verified'));
+ALTER TABLE users ALTER COLUMN status SET DEFAULT 'pending_verification';
+
+UPDATE users SET email_verified_at = created_at WHERE status = 'verified' AND email_verified_at IS NULL;
@@ -0,0 +1,20 @@
+-- Auditoria de tentativas de login (inclusive para e-mails que não existem)
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(100) NOT NULL,
+    user_id INT REFERENCES users(id),
+    ip VARCHAR(45),
+    success BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at);
+
+-- Bloqueio temporário por e-mail; a chave é o e-mail informado para não revelar quais contas existem
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    email VARCHAR(100) PRIMARY KEY,
+    failed_count INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
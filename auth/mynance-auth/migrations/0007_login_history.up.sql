@@ -0,0 +1,5 @@
+ALTER TABLE login_attempts ADD COLUMN IF NOT EXISTS user_agent TEXT;
+-- Sessão criada pelo login, exibida no histórico do usuário
+ALTER TABLE login_attempts ADD COLUMN IF NOT EXISTS session_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts(user_id, created_at);
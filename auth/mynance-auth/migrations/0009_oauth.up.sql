@@ -0,0 +1,29 @@
+-- Preenchidos nas sessões abertas por um cliente OAuth (client_id do db-auth-admin) com os escopos concedidos
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS client_id VARCHAR(64);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS scope TEXT;
+
+-- Consentimentos dados pelos usuários a clientes OAuth (client_id do db-auth-admin)
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id INT NOT NULL REFERENCES users(id),
+    client_id VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- Códigos de autorização (authorization code + PKCE): só o hash é guardado e cada código vale uma única vez
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id),
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    nonce TEXT,
+    code_challenge VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    -- Sessão aberta na troca do código; é revogada se o código for reapresentado
+    session_id UUID
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS federation_requests;
+DROP TABLE IF EXISTS identities;
+-- Falha enquanto existirem contas sem senha, criadas por login externo
+ALTER TABLE users ALTER COLUMN password SET NOT NULL;
@@ -0,0 +1,32 @@
+-- Nulo nas contas criadas por login externo até o usuário definir uma senha
+ALTER TABLE users ALTER COLUMN password DROP NOT NULL;
+
+-- Identidades de provedores externos (OIDC) vinculadas às contas; uma por provedor em cada conta
+CREATE TABLE IF NOT EXISTS identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    -- E-mail informado pelo provedor no vínculo, só para exibição
+    email VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities(user_id);
+
+-- Logins em andamento num provedor externo: o state só é guardado como hash e vale uma única vez
+CREATE TABLE IF NOT EXISTS federation_requests (
+    state_hash CHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    return_to TEXT NOT NULL,
+    -- Preenchido quando um usuário logado está vinculando uma identidade à conta
+    user_id INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
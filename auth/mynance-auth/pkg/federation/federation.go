@@ -0,0 +1,318 @@
+package federation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// clockSkew tolerates small clock differences with the identity provider
+const clockSkew = time.Minute
+
+var (
+	providers  = map[string]*Provider{}
+	order      []string
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+
+	providerIDPattern = regexp.MustCompile(`^[a-z0-9-]{1,50}$`)
+)
+
+// Provider is an external OpenID Connect issuer users can sign in with
+type Provider struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Issuer string `json:"-"`
+
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *jwks.RemoteKeySet
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is the verified result of a sign-in with a provider
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Init reads the providers listed in FEDERATION_PROVIDERS, each configured by FEDERATION_<ID>_* variables.
+// Discovery happens on first use, so a provider that is down does not keep the service from starting.
+func Init() {
+	for _, id := range strings.Split(os.Getenv("FEDERATION_PROVIDERS"), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		if !providerIDPattern.MatchString(id) {
+			logger.Log.Fatal("Invalid identity provider id", zap.String("provider", id))
+		}
+
+		prefix := "FEDERATION_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		provider := &Provider{
+			ID:           id,
+			Name:         os.Getenv(prefix + "NAME"),
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			clientID:     os.Getenv(prefix + "CLIENT_ID"),
+			clientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.clientID == "" {
+			logger.Log.Fatal(prefix+"ISSUER and "+prefix+"CLIENT_ID are required", zap.String("provider", id))
+		}
+		if provider.Name == "" {
+			provider.Name = id
+		}
+		if len(provider.scopes) == 0 {
+			provider.scopes = []string{"openid", "email", "profile"}
+		} else if !slices.Contains(provider.scopes, "openid") {
+			provider.scopes = append([]string{"openid"}, provider.scopes...)
+		}
+
+		if _, ok := providers[id]; !ok {
+			order = append(order, id)
+		}
+		providers[id] = provider
+	}
+
+	logger.Log.Info("External identity providers configured", zap.Strings("providers", order))
+}
+
+// Get returns a configured provider
+func Get(id string) (*Provider, bool) {
+	provider, ok := providers[id]
+	return provider, ok
+}
+
+// Providers lists the configured providers in the order of FEDERATION_PROVIDERS
+func Providers() []*Provider {
+	list := make([]*Provider, 0, len(order))
+	for _, id := range order {
+		list = append(list, providers[id])
+	}
+	return list
+}
+
+// AuthCodeURL returns where to send the browser to sign in with the provider (authorization code with PKCE S256)
+func (p *Provider) AuthCodeURL(redirectURI, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the verified ID token
+func (p *Provider) Exchange(code, codeVerifier, redirectURI, nonce string) (*Identity, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.clientID},
+	}
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+
+	resp, err := httpClient.PostForm(meta.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("token endpoint returned status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, errors.New("token endpoint returned no id_token")
+	}
+
+	return p.verifyIDToken(body.IDToken, nonce)
+}
+
+// verifyIDToken checks signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) verifyIDToken(idToken, nonce string) (*Identity, error) {
+	tokenClaims := &idTokenClaims{}
+	token, err := jwt.ParseWithClaims(idToken, tokenClaims, func(token *jwt.Token) (interface{}, error) {
+		// RS256 é o único algoritmo que o OIDC exige de todo provedor
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return p.keys.Keyfunc(token)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid id_token: %v", err)
+	}
+
+	if tokenClaims.Issuer != p.metadata.Issuer {
+		return nil, fmt.Errorf("unexpected issuer '%s'", tokenClaims.Issuer)
+	}
+	if !slices.Contains(tokenClaims.Audience, p.clientID) {
+		return nil, errors.New("id_token was not issued to this client")
+	}
+	// Com mais de um público o azp precisa ser este cliente (OIDC Core 3.1.3.7)
+	if len(tokenClaims.Audience) > 1 && tokenClaims.AuthorizedParty != p.clientID {
+		return nil, errors.New("id_token authorized party is not this client")
+	}
+	if tokenClaims.Nonce == "" || tokenClaims.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+	if tokenClaims.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+
+	return &Identity{
+		Subject:       tokenClaims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(tokenClaims.Email)),
+		EmailVerified: bool(tokenClaims.EmailVerified),
+		Name:          strings.TrimSpace(tokenClaims.Name),
+	}, nil
+}
+
+// discover loads the provider metadata once; a failure is retried on the next sign-in
+func (p *Provider) discover() (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	resp, err := httpClient.Get(p.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s discovery", resp.StatusCode, p.ID)
+	}
+
+	var meta metadata
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, err
+	}
+	// O issuer anunciado precisa ser o configurado, senão tokens de outro emissor seriam aceitos
+	if strings.TrimRight(meta.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery of %s announced issuer '%s'", p.ID, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery of %s is missing endpoints", p.ID)
+	}
+
+	p.keys = jwks.NewRemoteKeySet(meta.JWKSURI)
+	p.metadata = &meta
+	return p.metadata, nil
+}
+
+type idTokenClaims struct {
+	Issuer          string       `json:"iss"`
+	Subject         string       `json:"sub"`
+	Audience        audience     `json:"aud"`
+	AuthorizedParty string       `json:"azp"`
+	ExpiresAt       int64        `json:"exp"`
+	IssuedAt        int64        `json:"iat"`
+	Nonce           string       `json:"nonce"`
+	Email           string       `json:"email"`
+	EmailVerified   flexibleBool `json:"email_verified"`
+	Name            string       `json:"name"`
+}
+
+// Valid implements jwt.Claims
+func (c *idTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("id_token is expired")
+	}
+	if c.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("id_token was issued in the future")
+	}
+	return nil
+}
+
+// audience accepts "aud" as a string or as an array
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// flexibleBool accepts booleans sent as strings, which some providers do for email_verified
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = flexibleBool(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = flexibleBool(strings.EqualFold(text, "true"))
+	return nil
+}
@@ -0,0 +1,170 @@
+package federation
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jvlerner/my-finance-api/pkg/federation/federationtest"
+)
+
+const (
+	testClientID    = "mynance-test"
+	testRedirectURI = "https://auth.mynance.test/auth/federation/mock/callback"
+	testVerifier    = "verifier-with-enough-entropy-for-the-test-0123456789"
+	testNonce       = "nonce-123"
+)
+
+var testUser = federationtest.User{Subject: "subject-1", Email: " Ana@Example.com ", EmailVerified: true, Name: "Ana"}
+
+func newTestProvider(issuer *federationtest.Issuer) *Provider {
+	return &Provider{
+		ID:       "mock",
+		Name:     "Mock",
+		Issuer:   issuer.URL,
+		clientID: testClientID,
+		scopes:   []string{"openid", "email", "profile"},
+	}
+}
+
+func challengeOf(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signIn runs the browser side of the flow and exchanges the code with the given verifier
+func signIn(t *testing.T, issuer *federationtest.Issuer, provider *Provider, verifier string) (*Identity, error) {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(testRedirectURI, "state-1", testNonce, challengeOf(testVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := issuer.Authorize(t, authURL, testUser)
+	return provider.Exchange(code, verifier, testRedirectURI, testNonce)
+}
+
+func TestAuthCodeURLUsesDiscoveredEndpoint(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	provider := newTestProvider(issuer)
+
+	authURL, err := provider.AuthCodeURL(testRedirectURI, "state-1", testNonce, challengeOf(testVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, issuer.URL+"/authorize?") {
+		t.Fatalf("authorization URL %s is not the discovered endpoint", authURL)
+	}
+
+	parsed, _ := url.Parse(authURL)
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURI,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        challengeOf(testVerifier),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := parsed.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestDiscoveryRejectsAnotherIssuer(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	issuer.AnnouncedIssuer = "https://evil.example.com"
+	provider := newTestProvider(issuer)
+
+	if _, err := provider.AuthCodeURL(testRedirectURI, "state-1", testNonce, challengeOf(testVerifier)); err == nil {
+		t.Fatal("discovery accepted metadata announcing another issuer")
+	}
+
+	// A falha não fica em cache: corrigido o provedor, a próxima tentativa funciona
+	issuer.AnnouncedIssuer = ""
+	if _, err := provider.AuthCodeURL(testRedirectURI, "state-1", testNonce, challengeOf(testVerifier)); err != nil {
+		t.Fatalf("discovery after the issuer was fixed: %v", err)
+	}
+}
+
+func TestDiscoveryFailsWhenIssuerIsDown(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	provider := newTestProvider(issuer)
+	issuer.Close()
+
+	if _, err := provider.AuthCodeURL(testRedirectURI, "state-1", testNonce, challengeOf(testVerifier)); err == nil {
+		t.Fatal("AuthCodeURL succeeded with the issuer down")
+	}
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	provider := newTestProvider(issuer)
+
+	identity, err := signIn(t, issuer, provider, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ana@example.com" || !identity.EmailVerified || identity.Name != "Ana" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	provider := newTestProvider(issuer)
+
+	if _, err := signIn(t, issuer, provider, "another-verifier-that-does-not-match-the-challenge"); err == nil {
+		t.Fatal("exchange succeeded with a code_verifier that does not match the challenge")
+	}
+}
+
+func TestExchangeRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(claims jwt.MapClaims)
+	}{
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		{"wrong audience", func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{"several audiences without azp", func(claims jwt.MapClaims) { claims["aud"] = []string{testClientID, "another-client"} }},
+		{"azp of another client", func(claims jwt.MapClaims) {
+			claims["aud"] = []string{testClientID, "another-client"}
+			claims["azp"] = "another-client"
+		}},
+		{"wrong nonce", func(claims jwt.MapClaims) { claims["nonce"] = "replayed-nonce" }},
+		{"no nonce", func(claims jwt.MapClaims) { delete(claims, "nonce") }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-2 * clockSkew).Unix() }},
+		{"no expiry", func(claims jwt.MapClaims) { delete(claims, "exp") }},
+		{"issued in the future", func(claims jwt.MapClaims) { claims["iat"] = time.Now().Add(2 * clockSkew).Unix() }},
+		{"no subject", func(claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := federationtest.NewIssuer(t, testClientID)
+			issuer.EditClaims = tt.edit
+			provider := newTestProvider(issuer)
+
+			if identity, err := signIn(t, issuer, provider, testVerifier); err == nil {
+				t.Fatalf("accepted id_token, identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestExchangeAcceptsExpiryWithinClockSkew(t *testing.T) {
+	issuer := federationtest.NewIssuer(t, testClientID)
+	issuer.EditClaims = func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-clockSkew / 2).Unix() }
+	provider := newTestProvider(issuer)
+
+	if _, err := signIn(t, issuer, provider, testVerifier); err != nil {
+		t.Fatalf("rejected id_token expired within the clock skew: %v", err)
+	}
+}
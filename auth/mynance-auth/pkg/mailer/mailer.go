@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer delivers emails; implementations must be safe for concurrent use
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Mail is the mailer selected by Init
+var Mail Mailer
+
+// Init selects the mailer from MAIL_DRIVER: smtp, file or log. There is no default, so a deploy that
+// forgot the variable fails to start instead of silently dropping every email.
+func Init() {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		Mail = &SMTPMailer{
+			Addr:     net.JoinHostPort(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT")),
+			Host:     os.Getenv("SMTP_HOST"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	case "file":
+		Mail = &FileMailer{Path: os.Getenv("MAIL_FILE")}
+	case "log":
+		Mail = &LogMailer{}
+	case "":
+		logger.Log.Fatal("MAIL_DRIVER is required: smtp, file or log")
+	default:
+		logger.Log.Fatal("Unknown MAIL_DRIVER", zap.String("driver", driver))
+	}
+}
+
+// SMTPMailer sends through an SMTP relay; without a username it skips AUTH, as MailHog expects
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, m.format(msg))
+}
+
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// FileMailer appends each message as a JSON line, useful in development and tests
+type FileMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(msg)
+}
+
+// LogMailer only records that a message would be sent. The body is never logged: it carries reset,
+// verification and sign-in links that would let anyone reading the logs take over the account.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(msg Message) error {
+	logger.Log.Info("Email not sent, MAIL_DRIVER is log", zap.String("to", msg.To), zap.String("subject", msg.Subject), zap.Int("bodyBytes", len(msg.Body)))
+	return nil
+}
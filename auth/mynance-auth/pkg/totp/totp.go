@@ -0,0 +1,155 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// RFC 6238 defaults, the only parameters supported by every authenticator app
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Skew is how many periods before and after now are still accepted
+	Skew = 1
+)
+
+var (
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+	aead     cipher.AEAD
+)
+
+// Init loads TOTP_ENCRYPTION_KEY (32 bytes, base64), used to encrypt secrets at rest
+func Init() {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("TOTP_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		logger.Log.Fatal("TOTP_ENCRYPTION_KEY must be 32 bytes encoded in base64")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		logger.Log.Fatal("Failed to create the TOTP cipher", zap.Error(err))
+	}
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		logger.Log.Fatal("Failed to create the TOTP cipher", zap.Error(err))
+	}
+}
+
+// GenerateSecret returns a new random 160-bit secret encoded in base32
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps read from the QR code
+func ProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Code returns the code for the given time step
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Truncamento dinâmico (RFC 4226, seção 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Step returns the time step of t
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Validate checks code around t and returns the matching step; steps up to lastStep are refused to stop replays
+func Validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Encrypt seals a secret for storage
+func Encrypt(secret string) (string, error) {
+	if aead == nil {
+		return "", errors.New("totp encryption key not loaded")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a secret sealed by Encrypt
+func Decrypt(sealed string) (string, error) {
+	if aead == nil {
+		return "", errors.New("totp encryption key not loaded")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("sealed secret too short")
+	}
+
+	secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,156 @@
+package totp
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	// O apêndice B dá 8 dígitos; com 6 são os 6 últimos
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Fatalf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(current), 0, current, true},
+		{"one step behind", codeAt(current - 1), 0, current - 1, true},
+		{"one step ahead", codeAt(current + 1), 0, current + 1, true},
+		{"two steps behind", codeAt(current - 2), 0, 0, false},
+		{"two steps ahead", codeAt(current + 2), 0, 0, false},
+		{"replay of the last step", codeAt(current), current, 0, false},
+		{"older step after a newer one", codeAt(current - 1), current, 0, false},
+		{"newer step after an older one", codeAt(current + 1), current, current + 1, true},
+		{"wrong code", "000000", 0, 0, false},
+		{"too short", codeAt(current)[:5], 0, 0, false},
+		{"too long", codeAt(current) + "0", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("Validate = %d, %v; want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := Validate("not base32!", "123456", now, 0); ok {
+		t.Fatal("Validate accepted a malformed secret")
+	}
+}
+
+func loadKey(t *testing.T, fill byte) {
+	t.Helper()
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = fill
+	}
+	t.Setenv("TOTP_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key))
+	Init()
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	loadKey(t, 1)
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := Decrypt(sealed); err != nil || opened != secret {
+		t.Fatalf("Decrypt = %q, %v; want %q", opened, err, secret)
+	}
+
+	// Nonce aleatório: o mesmo segredo nunca é selado igual
+	if again, _ := Encrypt(secret); again == sealed {
+		t.Fatal("the same nonce was used twice")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper := func(index int) string {
+		changed := append([]byte(nil), data...)
+		changed[index] ^= 0x01
+		return base64.StdEncoding.EncodeToString(changed)
+	}
+
+	tests := []struct {
+		name   string
+		sealed string
+	}{
+		{"tampered nonce", tamper(0)},
+		{"tampered ciphertext", tamper(aead.NonceSize())},
+		{"tampered tag", tamper(len(data) - 1)},
+		{"truncated", base64.StdEncoding.EncodeToString(data[:aead.NonceSize()-1])},
+		{"not base64", "not base64!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := Decrypt(tt.sealed); err == nil {
+				t.Fatalf("Decrypt = %q, want an error", opened)
+			}
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		loadKey(t, 2)
+		if opened, err := Decrypt(sealed); err == nil {
+			t.Fatalf("Decrypt = %q with another key, want an error", opened)
+		}
+	})
+}
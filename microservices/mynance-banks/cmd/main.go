@@ -1,66 +1,18 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
-
 	"github.com/jvlerner/my-finance-api/internal/handlers"
-	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/auth"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
-
-	logger.Log.Info("[INFO] Starting MyFinance API...")
-
-	postgres.InitDB()
-	defer postgres.CloseDB()
-
-	client := auth.NewAuthClient()
-	if err := client.Login(); err != nil {
-		logger.Log.Error("[ERROR] Failed to login to auth service", zap.Error(err))
-		return
-	}
-
-	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
+	srv := server.New(server.Config{
+		Name:          "MyFinance API",
+		Database:      true,
+		ScopeResource: "banks",
+	})
 
-	// Grupo de rotas protegidas
-	authRoutes := r.Group("/")
-	authRoutes.Use(middleware.Auth())
+	srv.Protected.GET("/banks", handlers.GetBanks)
 
-	authRoutes.GET("/banks", handlers.GetBanks)
-	// Iniciar o servidor
-	r.Run(":8080")
+	srv.Run()
 }
@@ -1,63 +1,27 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-
 	"github.com/jvlerner/my-finance-api/internal/handlers"
-	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
-
-	logger.Log.Info("[INFO] Starting MyFinance Categories...")
-
-	postgres.InitDB()
-	defer postgres.CloseDB()
-
-	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
-
-	authRoutes := r.Group("/")
-	authRoutes.Use(middleware.Auth())
-	authRoutes.GET("/categories", handlers.GetCategories)
-	authRoutes.GET("/categories/inactive", handlers.GetInactivateCategories)
-	authRoutes.GET("/categories/all", handlers.GetAllCategories)
-	authRoutes.GET("/categories/id", handlers.GetCategory)
-	authRoutes.POST("/categories", handlers.CreateCategory)
-	authRoutes.PUT("/categories", handlers.UpdateCategory)
-	authRoutes.DELETE("/categories", handlers.DeactivateCategory)
-	authRoutes.POST("/categories/activate", handlers.ActivateCategory)
-
-	r.Run(":8080")
+	srv := server.New(server.Config{
+		Name:          "MyFinance Categories",
+		Database:      true,
+		Migrations:    migrations.FS,
+		ScopeResource: "categories",
+	})
+
+	srv.Protected.GET("/categories", handlers.GetCategories)
+	srv.Protected.GET("/categories/inactive", handlers.GetInactivateCategories)
+	srv.Protected.GET("/categories/all", handlers.GetAllCategories)
+	srv.Protected.GET("/categories/id", handlers.GetCategory)
+	srv.Protected.POST("/categories", handlers.CreateCategory)
+	srv.Protected.PUT("/categories", handlers.UpdateCategory)
+	srv.Protected.DELETE("/categories", handlers.DeactivateCategory)
+	srv.Protected.POST("/categories/activate", handlers.ActivateCategory)
+
+	srv.Run()
 }
@@ -1,15 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // CreateCategory inserts a new category record into the database
-func CreateCategory(userID int, name string, color string) (int, error) {
+func CreateCategory(ctx context.Context, userID int, name string, color string) (int, error) {
 	var categoryID int
-	err := postgres.DB.QueryRow("INSERT INTO categories (name, color, user_id) VALUES ($1, $2, $3) RETURNING id", name, color, userID).Scan(&categoryID)
+	err := postgres.DB.QueryRowContext(ctx, "INSERT INTO categories (name, color, user_id) VALUES ($1, $2, $3) RETURNING id", name, color, userID).Scan(&categoryID)
 	if err != nil {
 		return 0, err
 	}
@@ -17,9 +18,9 @@ func CreateCategory(userID int, name string, color string) (int, error) {
 }
 
 // GetCategory retrieves a category by its ID
-func GetCategory(userID, categoryID int) (*postgres.Category, error) {
-	var category postgres.Category
-	err := postgres.DB.QueryRow("SELECT id, user_id, name, active, created_at FROM categories WHERE id = $1 AND user_id = $2", categoryID, userID).Scan(&category.ID, &category.UserID, &category.Name, &category.Active, &category.CreatedAt)
+func GetCategory(ctx context.Context, userID, categoryID int) (*Category, error) {
+	var category Category
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, user_id, name, active, created_at FROM categories WHERE id = $1 AND user_id = $2", categoryID, userID).Scan(&category.ID, &category.UserID, &category.Name, &category.Active, &category.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -30,16 +31,16 @@ func GetCategory(userID, categoryID int) (*postgres.Category, error) {
 }
 
 // GetCategories retrieves all active categories
-func GetCategories(userID int) ([]postgres.Category, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, name, color, active, created_at FROM categories WHERE active = TRUE AND user_id = $1", userID)
+func GetCategories(ctx context.Context, userID int) ([]Category, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, name, color, active, created_at FROM categories WHERE active = TRUE AND user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []postgres.Category
+	var categories []Category
 	for rows.Next() {
-		var c postgres.Category
+		var c Category
 		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Color, &c.Active, &c.CreatedAt); err != nil {
 			return nil, err
 		}
@@ -49,16 +50,16 @@ func GetCategories(userID int) ([]postgres.Category, error) {
 }
 
 // GetCategories retrieves all active categories
-func GetAllCategories(userID int) ([]postgres.Category, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, name, color, active, created_at FROM categories WHERE user_id = $1", userID)
+func GetAllCategories(ctx context.Context, userID int) ([]Category, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, name, color, active, created_at FROM categories WHERE user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []postgres.Category
+	var categories []Category
 	for rows.Next() {
-		var c postgres.Category
+		var c Category
 		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Color, &c.Active, &c.CreatedAt); err != nil {
 			return nil, err
 		}
@@ -68,16 +69,16 @@ func GetAllCategories(userID int) ([]postgres.Category, error) {
 }
 
 // GetInactiveCategories retrieves all inactive categories
-func GetInactiveCategories(userID int) ([]postgres.Category, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, name, color, active, created_at FROM categories WHERE active = FALSE AND user_id = $1", userID)
+func GetInactiveCategories(ctx context.Context, userID int) ([]Category, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, name, color, active, created_at FROM categories WHERE active = FALSE AND user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []postgres.Category
+	var categories []Category
 	for rows.Next() {
-		var c postgres.Category
+		var c Category
 		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Color, &c.Active, &c.CreatedAt); err != nil {
 			return nil, err
 		}
@@ -87,19 +88,19 @@ func GetInactiveCategories(userID int) ([]postgres.Category, error) {
 }
 
 // UpdateCategory modifies an existing category record
-func UpdateCategory(userID, categoryID int, name, color string) error {
-	_, err := postgres.DB.Exec("UPDATE categories SET name = $1, color = $2 WHERE id = $3 AND user_id= $4", name, color, categoryID, userID)
+func UpdateCategory(ctx context.Context, userID, categoryID int, name, color string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE categories SET name = $1, color = $2 WHERE id = $3 AND user_id= $4", name, color, categoryID, userID)
 	return err
 }
 
 // DeactivateCategory marks a category as inactive
-func DeactivateCategory(userID, categoryID int) error {
-	_, err := postgres.DB.Exec("UPDATE categories SET active = FALSE WHERE id = $1 AND user_id= $2", categoryID, userID)
+func DeactivateCategory(ctx context.Context, userID, categoryID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE categories SET active = FALSE WHERE id = $1 AND user_id= $2", categoryID, userID)
 	return err
 }
 
 // ActivateCategory marks a category as active
-func ActivateCategory(userID, categoryID int) error {
-	_, err := postgres.DB.Exec("UPDATE categories SET active = TRUE WHERE id = $1 AND user_id= $2", categoryID, userID)
+func ActivateCategory(ctx context.Context, userID, categoryID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE categories SET active = TRUE WHERE id = $1 AND user_id= $2", categoryID, userID)
 	return err
 }
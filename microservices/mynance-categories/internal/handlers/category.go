@@ -5,12 +5,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateCategory handles category creation requests
 func CreateCategory(c *gin.Context) {
-	var request postgres.Category
+	var request db.Category
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -18,7 +17,7 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
-	categoryID, err := db.CreateCategory(userID, request.Name, request.Color)
+	categoryID, err := db.CreateCategory(c.Request.Context(), userID, request.Name, request.Color)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
 		return
@@ -31,7 +30,7 @@ func CreateCategory(c *gin.Context) {
 func GetCategories(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	categories, err := db.GetCategories(userID)
+	categories, err := db.GetCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -44,7 +43,7 @@ func GetCategories(c *gin.Context) {
 func GetAllCategories(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	categories, err := db.GetAllCategories(userID)
+	categories, err := db.GetAllCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -57,7 +56,7 @@ func GetAllCategories(c *gin.Context) {
 func GetInactivateCategories(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	categories, err := db.GetInactiveCategories(userID)
+	categories, err := db.GetInactiveCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -68,7 +67,7 @@ func GetInactivateCategories(c *gin.Context) {
 
 // GetCategory retrieves a category by ID
 func GetCategory(c *gin.Context) {
-	var request postgres.Category
+	var request db.Category
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -76,7 +75,7 @@ func GetCategory(c *gin.Context) {
 		return
 	}
 
-	category, err := db.GetCategory(userID, request.ID)
+	category, err := db.GetCategory(c.Request.Context(), userID, request.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve category"})
 		return
@@ -91,7 +90,7 @@ func GetCategory(c *gin.Context) {
 
 // UpdateCategory modifies an existing category
 func UpdateCategory(c *gin.Context) {
-	var request postgres.Category
+	var request db.Category
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -99,7 +98,7 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	err := db.UpdateCategory(userID, request.ID, request.Name, request.Color)
+	err := db.UpdateCategory(c.Request.Context(), userID, request.ID, request.Name, request.Color)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
 		return
@@ -110,7 +109,7 @@ func UpdateCategory(c *gin.Context) {
 
 // DeactivateCategory marks a category as inactive
 func DeactivateCategory(c *gin.Context) {
-	var request postgres.Category
+	var request db.Category
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -118,7 +117,7 @@ func DeactivateCategory(c *gin.Context) {
 		return
 	}
 
-	err := db.DeactivateCategory(request.ID, userID)
+	err := db.DeactivateCategory(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate category"})
 		return
@@ -129,14 +128,14 @@ func DeactivateCategory(c *gin.Context) {
 
 // ActivateCategory marks a category as active
 func ActivateCategory(c *gin.Context) {
-	var request postgres.Category
+	var request db.Category
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
-	err := db.ActivateCategory(request.ID, userID)
+	err := db.ActivateCategory(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate category"})
 		return
@@ -1,4 +1,6 @@
-CREATE TABLE categories (
+-- Esquema criado antes pelo init.sql de dbs/microservices/db-categories; IF NOT EXISTS deixa bancos já criados por ele adotarem as migrações
+
+CREATE TABLE IF NOT EXISTS categories (
     id SERIAL PRIMARY KEY,
     user_id INT,
     name VARCHAR(100) NOT NULL,
@@ -1,64 +1,27 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-
 	"github.com/jvlerner/my-finance-api/internal/handlers"
-	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
-
-	logger.Log.Info("[INFO] Starting MyFinance Credit Cards Expenses...")
-
-	postgres.InitDB()
-	defer postgres.CloseDB()
-
-	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
-
-	authRoutes := r.Group("/")
-	authRoutes.Use(middleware.Auth())
-
-	authRoutes.GET("/credit-cards/expenses", handlers.GetCreditCardExpenses)
-	// authRoutes.GET("/credit-cards/inactive", handlers.GetInactiveCreditCardExpenses)
-	// authRoutes.GET("/credit-cards/all", handlers.GetAllCreditCardExpenses)
-	authRoutes.GET("/credit-cards/expenses/id", handlers.GetCreditCardExpense)
-	authRoutes.POST("/credit-cards/expenses", handlers.CreateCreditCardExpense)
-	authRoutes.PUT("/credit-cards/expenses", handlers.UpdateCreditCardExpense)
-	authRoutes.DELETE("/credit-cards/expenses", handlers.DeleteCreditCardExpense)
-	authRoutes.POST("/credit-cards/expenses/activate", handlers.RecoveryCreditCardExpense)
-
-	r.Run(":8080")
+	srv := server.New(server.Config{
+		Name:          "MyFinance Credit Cards Expenses",
+		Database:      true,
+		Migrations:    migrations.FS,
+		ScopeResource: "creditcards-expenses",
+	})
+
+	srv.Protected.GET("/credit-cards/expenses", handlers.GetCreditCardExpenses)
+	// srv.Protected.GET("/credit-cards/inactive", handlers.GetInactiveCreditCardExpenses)
+	// srv.Protected.GET("/credit-cards/all", handlers.GetAllCreditCardExpenses)
+	srv.Protected.GET("/credit-cards/expenses/id", handlers.GetCreditCardExpense)
+	srv.Protected.POST("/credit-cards/expenses", handlers.CreateCreditCardExpense)
+	srv.Protected.PUT("/credit-cards/expenses", handlers.UpdateCreditCardExpense)
+	srv.Protected.DELETE("/credit-cards/expenses", handlers.DeleteCreditCardExpense)
+	srv.Protected.POST("/credit-cards/expenses/activate", handlers.RecoveryCreditCardExpense)
+
+	srv.Run()
 }
@@ -6,12 +6,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateCreditCardExpense handles credit card expense creation requests
 func CreateCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -25,7 +24,7 @@ func CreateCreditCardExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID)
 	}
 
-	expenseID, err := db.CreateCreditCardExpense(request.CardID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
+	expenseID, err := db.CreateCreditCardExpense(c.Request.Context(), request.CardID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credit card expense"})
 		return
@@ -36,7 +35,7 @@ func CreateCreditCardExpense(c *gin.Context) {
 
 // GetCreditCardExpenses retrieves all active credit card expenses for a specific card
 func GetCreditCardExpenses(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -44,7 +43,7 @@ func GetCreditCardExpenses(c *gin.Context) {
 		return
 	}
 
-	expenses, err := db.GetCreditCardExpensesByCard(request.CardID, userID)
+	expenses, err := db.GetCreditCardExpensesByCard(c.Request.Context(), request.CardID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card expenses"})
 		return
@@ -55,7 +54,7 @@ func GetCreditCardExpenses(c *gin.Context) {
 
 // GetCreditCardExpense retrieves a credit card expense by ID
 func GetCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -63,7 +62,7 @@ func GetCreditCardExpense(c *gin.Context) {
 		return
 	}
 
-	expense, err := db.GetCreditCardExpense(request.ID, userID)
+	expense, err := db.GetCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card expense"})
 		return
@@ -78,7 +77,7 @@ func GetCreditCardExpense(c *gin.Context) {
 
 // UpdateCreditCardExpense modifies an existing credit card expense
 func UpdateCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -92,7 +91,7 @@ func UpdateCreditCardExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID)
 	}
 
-	err := db.UpdateCreditCardExpense(request.ID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
+	err := db.UpdateCreditCardExpense(c.Request.Context(), request.ID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit card expense"})
 		return
@@ -103,7 +102,7 @@ func UpdateCreditCardExpense(c *gin.Context) {
 
 // DeleteCreditCardExpense marks a credit card expense as deleted
 func DeleteCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -111,7 +110,7 @@ func DeleteCreditCardExpense(c *gin.Context) {
 		return
 	}
 
-	err := db.DeleteCreditCardExpense(request.ID, userID)
+	err := db.DeleteCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete credit card expense"})
 		return
@@ -122,7 +121,7 @@ func DeleteCreditCardExpense(c *gin.Context) {
 
 // RecoveryCreditCardExpense marks a credit card expense as not deleted
 func RecoveryCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
+	var request db.CreditCardExpense
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -130,7 +129,7 @@ func RecoveryCreditCardExpense(c *gin.Context) {
 		return
 	}
 
-	err := db.RecoveryCreditCardExpense(request.ID, userID)
+	err := db.RecoveryCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recover credit card expense"})
 		return
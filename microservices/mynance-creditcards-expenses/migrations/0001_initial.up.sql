@@ -1,4 +1,6 @@
-CREATE TABLE credit_card_expenses (
+-- Esquema criado antes pelo init.sql de dbs/microservices/db-creditcards-expenses; IF NOT EXISTS deixa bancos já criados por ele adotarem as migrações
+
+CREATE TABLE IF NOT EXISTS credit_card_expenses (
     id SERIAL PRIMARY KEY,
     user_id INT,
     card_id INT,
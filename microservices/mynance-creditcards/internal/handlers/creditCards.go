@@ -4,14 +4,13 @@ import (
 	"net/http"
 
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CreateCreditCard handles credit card creation requests
 func CreateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
+	var request db.CreditCard
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -26,7 +25,7 @@ func CreateCreditCard(c *gin.Context) {
 		return
 	}
 
-	cardID, err := db.CreateCreditCard(userID, request.Name, request.Bank, request.LimitAmount, request.DueDay)
+	cardID, err := db.CreateCreditCard(c.Request.Context(), userID, request.Name, request.Bank, request.LimitAmount, request.DueDay)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credit card"})
 		return
@@ -39,7 +38,7 @@ func CreateCreditCard(c *gin.Context) {
 func GetCreditCards(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	cards, err := db.GetCreditCardsByUser(userID)
+	cards, err := db.GetCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit cards"})
 		return
@@ -52,7 +51,7 @@ func GetCreditCards(c *gin.Context) {
 func GetAllCreditCards(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	cards, err := db.GetAllCreditCardsByUser(userID)
+	cards, err := db.GetAllCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit cards"})
 		return
@@ -64,7 +63,7 @@ func GetAllCreditCards(c *gin.Context) {
 func GetInactiveCreditCards(c *gin.Context) {
 	userID := c.MustGet("userId").(int)
 
-	cards, err := db.GetInactiveCreditCardsByUser(userID)
+	cards, err := db.GetInactiveCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inactive credit cards"})
 		return
@@ -75,7 +74,7 @@ func GetInactiveCreditCards(c *gin.Context) {
 
 // GetCreditCard retrieves a credit card by ID
 func GetCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
+	var request db.CreditCard
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -83,7 +82,7 @@ func GetCreditCard(c *gin.Context) {
 		return
 	}
 
-	card, err := db.GetCreditCard(request.ID, userID)
+	card, err := db.GetCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card"})
 		return
@@ -98,7 +97,7 @@ func GetCreditCard(c *gin.Context) {
 
 // UpdateCreditCard modifies an existing credit card
 func UpdateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
+	var request db.CreditCard
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -106,7 +105,7 @@ func UpdateCreditCard(c *gin.Context) {
 		return
 	}
 
-	err := db.UpdateCreditCard(request.ID, userID, request.DueDay, request.Name, request.Bank, request.LimitAmount)
+	err := db.UpdateCreditCard(c.Request.Context(), request.ID, userID, request.DueDay, request.Name, request.Bank, request.LimitAmount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit card"})
 		return
@@ -117,7 +116,7 @@ func UpdateCreditCard(c *gin.Context) {
 
 // DeactivateCreditCard marks a credit card as inactive
 func DeactivateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
+	var request db.CreditCard
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -125,7 +124,7 @@ func DeactivateCreditCard(c *gin.Context) {
 		return
 	}
 
-	err := db.DeactivateCreditCard(request.ID, userID)
+	err := db.DeactivateCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate credit card"})
 		return
@@ -136,7 +135,7 @@ func DeactivateCreditCard(c *gin.Context) {
 
 // ActivateCreditCard marks a credit card as active
 func ActivateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
+	var request db.CreditCard
 	userID := c.MustGet("userId").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -144,7 +143,7 @@ func ActivateCreditCard(c *gin.Context) {
 		return
 	}
 
-	err := db.ActivateCreditCard(request.ID, userID)
+	err := db.ActivateCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate credit card"})
 		return
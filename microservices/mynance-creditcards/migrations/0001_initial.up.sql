@@ -1,4 +1,6 @@
-CREATE TABLE credit_cards (
+-- Esquema criado antes pelo init.sql de dbs/microservices/db-creditcards; IF NOT EXISTS deixa bancos já criados por ele adotarem as migrações
+
+CREATE TABLE IF NOT EXISTS credit_cards (
     id SERIAL PRIMARY KEY,
     user_id INT,
     name VARCHAR(100) NOT NULL,
@@ -9,4 +11,4 @@ CREATE TABLE credit_cards (
 );
 
 -- Índices para performance
-CREATE INDEX idx_card_user ON credit_cards(user_id);
+CREATE INDEX IF NOT EXISTS idx_card_user ON credit_cards(user_id);
@@ -1,60 +1,38 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-
 	"github.com/jvlerner/my-finance-api/internal/handlers"
-	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/middleware"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-	allowedOrigins := config.GetCORS()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
-
-	logger.Log.Info("[INFO] Starting MyFinance API...")
-
-	postgres.InitDB()
-	defer postgres.CloseDB()
-
-	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
-
-	authRoutes := r.Group("/")
-	authRoutes.Use(middleware.Auth())
-	authRoutes.GET("/user/me", handlers.GetUserProfile)
-	authRoutes.POST("/user/password", handlers.UpdateUserPassword)
-	authRoutes.POST("/user/name", handlers.UpdateUserName)
-	authRoutes.DELETE("/user", handlers.DeactivateUser)
-	authRoutes.POST("/user/activate", handlers.ActivateUser)
-
-	r.Run(":8080")
+	srv := server.New(server.Config{
+		Name:          "MyFinance Customer",
+		Database:      true,
+		ScopeResource: "customer",
+	})
+
+	// Algoritmo e custo do hash das senhas
+	hasher.Init()
+
+	// Regras de senha e lista de senhas vazadas
+	passwordpolicy.Init()
+
+	routes(srv)
+
+	srv.Run()
+}
+
+// routes registers the user routes on the group protected by the user token
+func routes(srv *server.Server) {
+	srv.Protected.GET("/user/me", handlers.GetUserProfile)
+	srv.Protected.POST("/user/name", handlers.UpdateUserName)
+
+	// Senha e ciclo de vida da conta só com a sessão do próprio usuário, nunca com tokens de scripts ou clientes OAuth
+	srv.Protected.POST("/user/password", middleware.SessionOnly(), handlers.UpdateUserPassword)
+	srv.Protected.DELETE("/user", middleware.SessionOnly(), handlers.DeactivateUser)
+	srv.Protected.POST("/user/activate", middleware.SessionOnly(), handlers.ActivateUser)
 }
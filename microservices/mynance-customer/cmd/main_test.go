@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/auth/authtest"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/jvlerner/mynance-platform/server"
+)
+
+const currentPassword = "Current-Passw0rd!"
+
+var (
+	fakeAuth *authtest.Server
+	srv      *server.Server
+)
+
+// TestMain wires the service as main does, without the database, against a fake mynance-auth and mynance-auth-admin
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	fakeAuth = authtest.Start("mynance-customer")
+
+	srv = server.New(server.Config{Name: "MyFinance Customer", ScopeResource: "customer"})
+	// Custo mínimo: o teste não mede o hash
+	hasher.Default = hasher.NewArgon2id(hasher.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	hasher.Register(hasher.Default)
+	routes(srv)
+
+	code := m.Run()
+	fakeAuth.Close()
+	os.Exit(code)
+}
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := postgres.DB
+	postgres.DB = db
+	t.Cleanup(func() {
+		postgres.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+func session(t *testing.T) string {
+	t.Helper()
+
+	token, err := fakeAuth.Sign(claims.TypeUser, []string{"mynance-customer"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serve(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func expectUser(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+
+	hashed, err := hasher.Hash(currentPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(authtest.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password", "active", "created_at"}).
+			AddRow(authtest.UserID, "Ana", authtest.UserEmail, hashed, true, time.Now()))
+}
+
+func TestGetUserProfileReadsTheAuthenticatedUser(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(authtest.UserEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(authtest.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "active", "created_at"}).
+			AddRow(authtest.UserID, "Ana", authtest.UserEmail, true, time.Now()))
+
+	w := serve(http.MethodGet, "/user/me", session(t), "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), authtest.UserEmail) {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		status  int
+	}{
+		{"wrong current password", "Wrong-Passw0rd!", http.StatusUnauthorized},
+		{"current password", currentPassword, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			expectUser(t, mock)
+			if tt.status == http.StatusOK {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE users SET password").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs(authtest.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE personal_access_tokens SET revoked_at").WithArgs(authtest.UserID).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			}
+
+			body := `{"currentPassword":"` + tt.current + `","password":"Brand-New-Passw0rd!"}`
+			if w := serve(http.MethodPost, "/user/password", session(t), body); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdateUserPasswordRequiresASession(t *testing.T) {
+	mockDB(t)
+	token := fakeAuth.PersonalAccessToken("customer-write", "customer:write")
+
+	body := `{"currentPassword":"` + currentPassword + `","password":"Brand-New-Passw0rd!"}`
+	if w := serve(http.MethodPost, "/user/password", token, body); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+}
@@ -1,19 +1,19 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // UserExists checks if a user exists by email
-func UserExists(email string) (bool, error) {
+func UserExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	err := postgres.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+	err := postgres.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
@@ -21,9 +21,9 @@ func UserExists(email string) (bool, error) {
 }
 
 // UserLastPasswordChange retrieves the last password change timestamp for a given user
-func UserLastPasswordChange(userID int) (time.Time, error) {
+func UserLastPasswordChange(ctx context.Context, userID int) (time.Time, error) {
 	var lastPasswordChange time.Time
-	err := postgres.DB.QueryRow("SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
+	err := postgres.DB.QueryRowContext(ctx, "SELECT last_password_change FROM users WHERE id = $1", userID).Scan(&lastPasswordChange)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Retorna um timestamp zero se o usuário não for encontrado
@@ -35,14 +35,14 @@ func UserLastPasswordChange(userID int) (time.Time, error) {
 }
 
 // CreateUser inserts a new user into the database
-func CreateUser(name, email, password string) (int, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func CreateUser(ctx context.Context, name, email, password string) (int, error) {
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return 0, err
 	}
 
 	var userID int
-	err = postgres.DB.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id", name, email, string(hashedPassword)).Scan(&userID)
+	err = postgres.DB.QueryRowContext(ctx, "INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id", name, email, hashedPassword).Scan(&userID)
 	if err != nil {
 		return 0, err
 	}
@@ -51,9 +51,9 @@ func CreateUser(name, email, password string) (int, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func GetUserByEmail(email string) (*postgres.User, error) {
-	var user postgres.User
-	err := postgres.DB.QueryRow("SELECT id, name, email, password, active, created_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt)
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, name, email, password, active, created_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -64,9 +64,9 @@ func GetUserByEmail(email string) (*postgres.User, error) {
 }
 
 // GetProfileByID retrieves user profile by ID
-func GetProfileByID(userID int) (*postgres.Profile, error) {
-	var user postgres.Profile
-	err := postgres.DB.QueryRow("SELECT id, name, email, active, created_at FROM users WHERE id = $1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.Active, &user.CreatedAt)
+func GetProfileByID(ctx context.Context, userID int) (*Profile, error) {
+	var user Profile
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, name, email, active, created_at FROM users WHERE id = $1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.Active, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -77,29 +77,59 @@ func GetProfileByID(userID int) (*postgres.Profile, error) {
 }
 
 // UpdateUser updates user details
-func UpdateUser(userID int, name string) error {
-	_, err := postgres.DB.Exec("UPDATE users SET name = $1 WHERE id = $2", name, userID)
+func UpdateUser(ctx context.Context, userID int, name string) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", name, userID)
 	return err
 }
 
-// UpdateUserPassword updates user password
-func UpdateUserPassword(userID int, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// GetUserByID retrieves a user by ID, including the password hash, which is empty for accounts that only sign in with an external provider
+func GetUserByID(ctx context.Context, userID int) (*User, error) {
+	var user User
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, name, email, COALESCE(password, ''), active, created_at FROM users WHERE id = $1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Active, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserPassword updates user password and signs the user out everywhere.
+// Sessions and personal access tokens are revoked in the same transaction as the password change.
+func UpdateUserPassword(ctx context.Context, userID int, password string) error {
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
-	_, err = postgres.DB.Exec("UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", string(hashedPassword), userID)
-	return err
+
+	tx, err := postgres.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// last_password_change invalida todos os JWTs emitidos antes da troca
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $1, last_password_change = NOW() WHERE id = $2", hashedPassword, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // DeleteUser marks a user as inactive instead of permanent deletion
-func DeleteUser(userID int) error {
-	_, err := postgres.DB.Exec("UPDATE users SET active = FALSE WHERE id = $1", userID)
+func DeleteUser(ctx context.Context, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET active = FALSE WHERE id = $1", userID)
 	return err
 }
 
 // RecoverUser reactivates a user account
-func RecoverUser(userID int) error {
-	_, err := postgres.DB.Exec("UPDATE users SET active = TRUE WHERE id = $1", userID)
+func RecoverUser(ctx context.Context, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE users SET active = TRUE WHERE id = $1", userID)
 	return err
 }
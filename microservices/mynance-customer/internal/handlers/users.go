@@ -5,16 +5,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
+	"github.com/jvlerner/mynance-platform/hasher"
+	"github.com/jvlerner/mynance-platform/logger"
 	"go.uber.org/zap"
 )
 
 // GetUserProfile retrieves the profile of the logged-in user
 func GetUserProfile(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
-	userEmail := c.MustGet("user_email").(string)
+	userID := c.GetInt("userId")
+	userEmail := c.GetString("userEmail")
 
-	exists, err := db.UserExists(userEmail)
+	exists, err := db.UserExists(c.Request.Context(), userEmail)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -24,19 +25,19 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := db.GetProfileByID(userID)
+	profile, err := db.GetProfileByID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	logger.Log.Info("User profile retrieved", zap.Int("userID", userID))
+	logger.Ctx(c.Request.Context()).Info("User profile retrieved", zap.Int("userID", userID))
 	c.JSON(http.StatusOK, gin.H{"user": profile})
 }
 
 // UpdateUserName updates the name of the logged-in user
 func UpdateUserName(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
 	var request struct {
 		Name string `json:"name" binding:"required"`
@@ -47,61 +48,89 @@ func UpdateUserName(c *gin.Context) {
 		return
 	}
 
-	if err := db.UpdateUser(userID, request.Name); err != nil {
+	if err := db.UpdateUser(c.Request.Context(), userID, request.Name); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update name"})
 		return
 	}
 
-	logger.Log.Info("User name updated", zap.Int("userID", userID))
+	logger.Ctx(c.Request.Context()).Info("User name updated", zap.Int("userID", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Name updated successfully"})
 }
 
-// UpdateUserPassword updates the password of the logged-in user
+// UpdateUserPassword updates the password of the logged-in user after checking the current one.
+// Every session and personal access token of the user is revoked, so the user signs in again with the new password.
 func UpdateUserPassword(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
 	var request struct {
-		Password string `json:"password" binding:"required"`
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		Password        string `json:"password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Field password not provided"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fields currentPassword and password not provided"})
 		return
 	}
 
-	if !isValidPassword(request.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long, include an uppercase letter, a lowercase letter, a number, and a special character."})
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Contas só com login externo não têm senha para trocar
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account has no password"})
+		return
+	}
+
+	ok, _, err := hasher.Verify(request.CurrentPassword, user.Password)
+	if err != nil {
+		logger.Ctx(c.Request.Context()).Error("Failed to verify password", zap.Int("userID", userID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if !checkPassword(c, request.Password, user.Email, user.Name) {
 		return
 	}
 
-	if err := db.UpdateUserPassword(userID, request.Password); err != nil {
+	if err := db.UpdateUserPassword(c.Request.Context(), userID, request.Password); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
-	logger.Log.Info("User password updated", zap.Int("userID", userID))
+	logger.Ctx(c.Request.Context()).Info("User password updated, sessions revoked", zap.Int("userID", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
 
 // DeactivateUser marks the user account as inactive
 func DeactivateUser(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
-	if err := db.DeleteUser(userID); err != nil {
+	userID := c.GetInt("userId")
+	if err := db.DeleteUser(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate account"})
 		return
 	}
 
-	logger.Log.Info("User account deactivated", zap.Int("userID", userID))
+	logger.Ctx(c.Request.Context()).Info("User account deactivated", zap.Int("userID", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated successfully"})
 }
 
 // ActivateUser reactivates the user account
 func ActivateUser(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
-	if err := db.RecoverUser(userID); err != nil {
+	userID := c.GetInt("userId")
+	if err := db.RecoverUser(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate account"})
 		return
 	}
 
-	logger.Log.Info("User account activated", zap.Int("userID", userID))
+	logger.Ctx(c.Request.Context()).Info("User account activated", zap.Int("userID", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Account activated successfully"})
 }
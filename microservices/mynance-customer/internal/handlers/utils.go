@@ -1,13 +1,18 @@
 package handlers
 
-import "regexp"
+import (
+	"net/http"
 
-func isValidPassword(password string) bool {
-	var (
-		upperCase = regexp.MustCompile(`[A-Z]`)
-		lowerCase = regexp.MustCompile(`[a-z]`)
-		digit     = regexp.MustCompile(`\d`)
-		special   = regexp.MustCompile(`[!@#$%^&*(),.?":{}|<>]`)
-	)
-	return len(password) >= 8 && upperCase.MatchString(password) && lowerCase.MatchString(password) && digit.MatchString(password) && special.MatchString(password)
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/passwordpolicy"
+)
+
+// checkPassword answers 400 with every policy rule the password breaks; personal is the user's email and name
+func checkPassword(c *gin.Context, password string, personal ...string) bool {
+	violations := passwordpolicy.Check(password, personal...)
+	if len(violations) == 0 {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the policy", "violations": violations})
+	return false
 }
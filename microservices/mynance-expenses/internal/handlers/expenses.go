@@ -6,12 +6,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateExpense handles expense creation requests
 func CreateExpense(c *gin.Context) {
-	var request postgres.Expense
+	var request db.Expense
 	userID := c.MustGet("user_id").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -25,7 +24,7 @@ func CreateExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID) // Converte int para int64
 	}
 
-	expenseID, err := db.CreateExpense(userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), categoryID)
+	expenseID, err := db.CreateExpense(c.Request.Context(), userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense"})
 		return
@@ -38,7 +37,7 @@ func CreateExpense(c *gin.Context) {
 func GetExpenses(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 
-	expenses, err := db.GetExpensesByUser(userID)
+	expenses, err := db.GetExpensesByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expenses"})
 		return
@@ -49,7 +48,7 @@ func GetExpenses(c *gin.Context) {
 
 // GetExpense retrieves an expense by ID
 func GetExpense(c *gin.Context) {
-	var request postgres.Expense
+	var request db.Expense
 	userID := c.MustGet("user_id").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -57,7 +56,7 @@ func GetExpense(c *gin.Context) {
 		return
 	}
 
-	expense, err := db.GetExpense(request.ID, userID)
+	expense, err := db.GetExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expense"})
 		return
@@ -72,7 +71,7 @@ func GetExpense(c *gin.Context) {
 
 // UpdateExpense modifies an existing expense
 func UpdateExpense(c *gin.Context) {
-	var request postgres.Expense
+	var request db.Expense
 	userID := c.MustGet("user_id").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -86,7 +85,7 @@ func UpdateExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID) // Converte int para int64
 	}
 
-	err := db.UpdateExpense(request.ID, userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), request.Paid, categoryID)
+	err := db.UpdateExpense(c.Request.Context(), request.ID, userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), request.Paid, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
 		return
@@ -97,7 +96,7 @@ func UpdateExpense(c *gin.Context) {
 
 // DeleteExpense marks an expense as deleted
 func DeleteExpense(c *gin.Context) {
-	var request postgres.Expense
+	var request db.Expense
 	userID := c.MustGet("user_id").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -105,7 +104,7 @@ func DeleteExpense(c *gin.Context) {
 		return
 	}
 
-	err := db.DeleteExpense(request.ID, userID)
+	err := db.DeleteExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense"})
 		return
@@ -116,7 +115,7 @@ func DeleteExpense(c *gin.Context) {
 
 // RecoveryExpense marks an expense as not deleted
 func RecoveryExpense(c *gin.Context) {
-	var request postgres.Expense
+	var request db.Expense
 	userID := c.MustGet("user_id").(int)
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -124,7 +123,7 @@ func RecoveryExpense(c *gin.Context) {
 		return
 	}
 
-	err := db.RecoveryExpense(request.ID, userID)
+	err := db.RecoveryExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recover expense"})
 		return
@@ -1,4 +1,6 @@
-CREATE TABLE expenses (
+-- Esquema criado antes pelo init.sql de dbs/microservices/db-expenses; IF NOT EXISTS deixa bancos já criados por ele adotarem as migrações
+
+CREATE TABLE IF NOT EXISTS expenses (
     id SERIAL PRIMARY KEY,
     user_id INT,
     description VARCHAR(255) NOT NULL,
@@ -10,7 +12,7 @@ CREATE TABLE expenses (
     deleted BOOLEAN DEFAULT FALSE
 );
 
-CREATE TABLE payments (
+CREATE TABLE IF NOT EXISTS payments (
     id SERIAL PRIMARY KEY,
     user_id INT,
     expense_id INT,
@@ -19,5 +21,5 @@ CREATE TABLE payments (
     deleted BOOLEAN DEFAULT FALSE
 );
 
-CREATE INDEX idx_expense_user ON expenses(user_id);
-CREATE INDEX idx_expense_due_date ON expenses(user_id,due_date);
+CREATE INDEX IF NOT EXISTS idx_expense_user ON expenses(user_id);
+CREATE INDEX IF NOT EXISTS idx_expense_due_date ON expenses(user_id,due_date);
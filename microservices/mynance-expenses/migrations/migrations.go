@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS holds the numbered up and down SQL files of the db-expenses schema, applied by mynance-platform/migrate
+//
+//go:embed *.sql
+var FS embed.FS
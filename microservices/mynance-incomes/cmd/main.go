@@ -1,112 +1,70 @@
 package main
 
 import (
-	"time"
-
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-
 	"github.com/jvlerner/my-finance-api/internal/handlers"
-	"github.com/jvlerner/my-finance-api/internal/middleware"
-	"github.com/jvlerner/my-finance-api/pkg/config"
-	"github.com/jvlerner/my-finance-api/pkg/logger"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
-	"github.com/jvlerner/my-finance-api/pkg/prometheus"
+	"github.com/jvlerner/my-finance-api/migrations"
+	"github.com/jvlerner/mynance-platform/server"
 )
 
 func main() {
-	config.LoadEnv()
-
-	logger.InitLogger()
-	defer logger.CloseLogger()
-
-	logger.Log.Info("[INFO] Starting MyFinance API...")
-
-	postgres.InitDB()
-	defer postgres.CloseDB()
-
-	// Initialize the database metrics
-	prometheus.SetDBForMonitoring(postgres.DB)
-	defer prometheus.CloseDBForMonitoring()
-
-	// Initialize the metrics
-	prometheus.Init()
-	defer prometheus.Close()
-
-	r := gin.Default()
-	middleware.StartRateLimiter()
+	srv := server.New(server.Config{
+		Name:          "MyFinance API",
+		Database:      true,
+		Migrations:    migrations.FS,
+		ScopeResource: "incomes",
+	})
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.Use(middleware.Prometheus())
-	r.Use(middleware.RateLimit())
+	routes(srv)
 
-	// Rotas de autenticação
-	r.POST("/auth/register", handlers.Register)
-	r.POST("/auth/login", handlers.Login)
-	r.POST("/auth/logout", handlers.Logout)
-
-	// Grupo de rotas protegidas
-	authRoutes := r.Group("/")
-	authRoutes.Use(middleware.Auth())
-	authRoutes.GET("/user/me", handlers.GetUserProfile)
-	authRoutes.POST("/user/password", handlers.UpdateUserPassword)
-	authRoutes.POST("/user/name", handlers.UpdateUserName)
-	authRoutes.DELETE("/user", handlers.DeactivateUser)
-	authRoutes.POST("/user/activate", handlers.ActivateUser)
-
-	authRoutes.GET("/expenses", handlers.GetExpenses)
-	// authRoutes.GET("/expenses/inactive", handlers.GetExpenses)
-	// authRoutes.GET("/expenses/all", handlers.GetExpenses)
-	authRoutes.GET("/expenses/id", handlers.GetExpense)
-	authRoutes.POST("/expenses", handlers.CreateExpense)
-	authRoutes.PUT("/expenses", handlers.UpdateExpense)
-	authRoutes.DELETE("/expenses", handlers.DeleteExpense)
-	authRoutes.POST("/expenses/activate", handlers.RecoveryExpense)
-
-	authRoutes.GET("/incomes", handlers.GetIncomes)
-	// authRoutes.GET("/incomes/inactive", handlers.GetInactiveIncomes)
-	// authRoutes.GET("/incomes/all", handlers.GetAllIncomes)
-	authRoutes.GET("/incomes/id", handlers.GetIncome)
-	authRoutes.POST("/incomes", handlers.CreateIncome)
-	authRoutes.PUT("/incomes", handlers.UpdateIncome)
-	authRoutes.DELETE("/incomes", handlers.DeleteIncome)
-	authRoutes.POST("/incomes/activate", handlers.RecoveryIncome)
-
-	authRoutes.GET("/credit-cards", handlers.GetCreditCards)
-	authRoutes.GET("/credit-cards/inactive", handlers.GetInactiveCreditCards)
-	authRoutes.GET("/credit-cards/all", handlers.GetAllCreditCards)
-	authRoutes.GET("/credit-cards/id", handlers.GetCreditCards)
-	authRoutes.POST("/credit-cards", handlers.CreateCreditCard)
-	authRoutes.PUT("/credit-cards", handlers.UpdateCreditCard)
-	authRoutes.DELETE("/credit-cards", handlers.DeactivateCreditCard)
-	authRoutes.POST("/credit-cards/activate", handlers.ActivateCreditCard)
-
-	authRoutes.GET("/credit-cards/expenses", handlers.GetCreditCardExpenses)
-	// authRoutes.GET("/credit-cards/inactive", handlers.GetInactiveCreditCardExpenses)
-	// authRoutes.GET("/credit-cards/all", handlers.GetAllCreditCardExpenses)
-	authRoutes.GET("/credit-cards/expenses/id", handlers.GetCreditCardExpense)
-	authRoutes.POST("/credit-cards/expenses", handlers.CreateCreditCardExpense)
-	authRoutes.PUT("/credit-cards/expenses", handlers.UpdateCreditCardExpense)
-	authRoutes.DELETE("/credit-cards/expenses", handlers.DeleteCreditCardExpense)
-	authRoutes.POST("/credit-cards/expenses/activate", handlers.RecoveryCreditCardExpense)
-
-	authRoutes.GET("/categories", handlers.GetCategories)
-	authRoutes.GET("/categories/inactive", handlers.GetInactivateCategories)
-	authRoutes.GET("/categories/all", handlers.GetAllCategories)
-	authRoutes.GET("/categories/id", handlers.GetCategory)
-	authRoutes.POST("/categories", handlers.CreateCategory)
-	authRoutes.PUT("/categories", handlers.UpdateCategory)
-	authRoutes.DELETE("/categories", handlers.DeactivateCategory)
-	authRoutes.POST("/categories/activate", handlers.ActivateCategory)
+	srv.Run()
+}
 
-	authRoutes.GET("/banks", handlers.GetBanks)
-	// Iniciar o servidor
-	r.Run(":8080")
+// routes registers the routes on the group protected by the user token; sign-in and the account are served by mynance-auth and mynance-customer
+func routes(srv *server.Server) {
+	srv.Protected.GET("/expenses", handlers.GetExpenses)
+	// srv.Protected.GET("/expenses/inactive", handlers.GetExpenses)
+	// srv.Protected.GET("/expenses/all", handlers.GetExpenses)
+	srv.Protected.GET("/expenses/id", handlers.GetExpense)
+	srv.Protected.POST("/expenses", handlers.CreateExpense)
+	srv.Protected.PUT("/expenses", handlers.UpdateExpense)
+	srv.Protected.DELETE("/expenses", handlers.DeleteExpense)
+	srv.Protected.POST("/expenses/activate", handlers.RecoveryExpense)
+
+	srv.Protected.GET("/incomes", handlers.GetIncomes)
+	// srv.Protected.GET("/incomes/inactive", handlers.GetInactiveIncomes)
+	// srv.Protected.GET("/incomes/all", handlers.GetAllIncomes)
+	srv.Protected.GET("/incomes/id", handlers.GetIncome)
+	srv.Protected.POST("/incomes", handlers.CreateIncome)
+	srv.Protected.PUT("/incomes", handlers.UpdateIncome)
+	srv.Protected.DELETE("/incomes", handlers.DeleteIncome)
+	srv.Protected.POST("/incomes/activate", handlers.RecoveryIncome)
+
+	srv.Protected.GET("/credit-cards", handlers.GetCreditCards)
+	srv.Protected.GET("/credit-cards/inactive", handlers.GetInactiveCreditCards)
+	srv.Protected.GET("/credit-cards/all", handlers.GetAllCreditCards)
+	srv.Protected.GET("/credit-cards/id", handlers.GetCreditCards)
+	srv.Protected.POST("/credit-cards", handlers.CreateCreditCard)
+	srv.Protected.PUT("/credit-cards", handlers.UpdateCreditCard)
+	srv.Protected.DELETE("/credit-cards", handlers.DeactivateCreditCard)
+	srv.Protected.POST("/credit-cards/activate", handlers.ActivateCreditCard)
+
+	srv.Protected.GET("/credit-cards/expenses", handlers.GetCreditCardExpenses)
+	// srv.Protected.GET("/credit-cards/inactive", handlers.GetInactiveCreditCardExpenses)
+	// srv.Protected.GET("/credit-cards/all", handlers.GetAllCreditCardExpenses)
+	srv.Protected.GET("/credit-cards/expenses/id", handlers.GetCreditCardExpense)
+	srv.Protected.POST("/credit-cards/expenses", handlers.CreateCreditCardExpense)
+	srv.Protected.PUT("/credit-cards/expenses", handlers.UpdateCreditCardExpense)
+	srv.Protected.DELETE("/credit-cards/expenses", handlers.DeleteCreditCardExpense)
+	srv.Protected.POST("/credit-cards/expenses/activate", handlers.RecoveryCreditCardExpense)
+
+	srv.Protected.GET("/categories", handlers.GetCategories)
+	srv.Protected.GET("/categories/inactive", handlers.GetInactivateCategories)
+	srv.Protected.GET("/categories/all", handlers.GetAllCategories)
+	srv.Protected.GET("/categories/id", handlers.GetCategory)
+	srv.Protected.POST("/categories", handlers.CreateCategory)
+	srv.Protected.PUT("/categories", handlers.UpdateCategory)
+	srv.Protected.DELETE("/categories", handlers.DeactivateCategory)
+	srv.Protected.POST("/categories/activate", handlers.ActivateCategory)
+
+	srv.Protected.GET("/banks", handlers.GetBanks)
 }
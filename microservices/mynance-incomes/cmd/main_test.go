@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/auth/authtest"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/jvlerner/mynance-platform/server"
+)
+
+var (
+	fakeAuth *authtest.Server
+	srv      *server.Server
+)
+
+// TestMain wires the service as main does, without the database, against a fake mynance-auth and mynance-auth-admin
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	fakeAuth = authtest.Start("mynance-incomes")
+
+	srv = server.New(server.Config{Name: "MyFinance API", ScopeResource: "incomes"})
+	routes(srv)
+
+	code := m.Run()
+	fakeAuth.Close()
+	os.Exit(code)
+}
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := postgres.DB
+	postgres.DB = db
+	t.Cleanup(func() {
+		postgres.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+func sign(t *testing.T, audience string) string {
+	t.Helper()
+
+	token, err := fakeAuth.Sign(claims.TypeUser, []string{audience}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serve(method, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/incomes", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestIncomesOfTheAuthenticatedUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T) string
+	}{
+		{"session", func(t *testing.T) string { return sign(t, "mynance-incomes") }},
+		{"personal access token", func(t *testing.T) string { return fakeAuth.PersonalAccessToken("incomes-read", "incomes:read") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery("FROM incomes WHERE user_id").WithArgs(authtest.UserID).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "description", "amount", "received_at", "is_recurring"}).
+					AddRow(1, authtest.UserID, "Salary", 5000.0, time.Now(), true))
+
+			w := serve(http.MethodGet, tt.token(t), "{}")
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Salary") {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestIncomesRefusesOtherTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		token  func(t *testing.T) string
+		status int
+	}{
+		{"HS256 token of the old login", http.MethodGet, func(t *testing.T) string {
+			tokenClaims, err := claims.New(claims.TypeUser, "mynance-incomes", []string{"mynance-incomes"}, authtest.UserID, authtest.UserEmail, "", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims).SignedString([]byte("mysecret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}, http.StatusUnauthorized},
+		{"session of another service", http.MethodGet, func(t *testing.T) string { return sign(t, "mynance-banks") }, http.StatusUnauthorized},
+		{"read scope writes", http.MethodDelete, func(t *testing.T) string { return fakeAuth.PersonalAccessToken("incomes-read-only", "incomes:read") }, http.StatusForbidden},
+		{"scope of another service", http.MethodGet, func(t *testing.T) string { return fakeAuth.PersonalAccessToken("expenses-write", "expenses:write") }, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB(t)
+			if w := serve(tt.method, tt.token(t), `{"id":1}`); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
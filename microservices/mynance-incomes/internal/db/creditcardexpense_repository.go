@@ -1,15 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // CreateCreditCardExpense inserts a new credit card expense record into the database
-func CreateCreditCardExpense(cardID, userID int, description string, amount float64, purchaseDate string, installmentCount int, categoryID sql.NullInt64) (int, error) {
+func CreateCreditCardExpense(ctx context.Context, cardID, userID int, description string, amount float64, purchaseDate string, installmentCount int, categoryID sql.NullInt64) (int, error) {
 	var expenseID int
-	err := postgres.DB.QueryRow("INSERT INTO credit_card_expenses (card_id, user_id, description, amount, purchase_date, installment_count, category_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", cardID, userID, description, amount, purchaseDate, installmentCount, categoryID).Scan(&expenseID)
+	err := postgres.DB.QueryRowContext(ctx, "INSERT INTO credit_card_expenses (card_id, user_id, description, amount, purchase_date, installment_count, category_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", cardID, userID, description, amount, purchaseDate, installmentCount, categoryID).Scan(&expenseID)
 	if err != nil {
 		return 0, err
 	}
@@ -17,9 +18,9 @@ func CreateCreditCardExpense(cardID, userID int, description string, amount floa
 }
 
 // GetCreditCardExpense retrieves a credit card expense by its ID
-func GetCreditCardExpense(expenseID, userID int) (*postgres.CreditCardExpense, error) {
-	var expense postgres.CreditCardExpense
-	err := postgres.DB.QueryRow("SELECT id, user_id, card_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE id = $1 AND user_id = $2", expenseID, userID).Scan(&expense.ID, &expense.UserID, &expense.CardID, &expense.Description, &expense.Amount, &expense.PurchaseDate, &expense.InstallmentCount, &expense.CategoryID)
+func GetCreditCardExpense(ctx context.Context, expenseID, userID int) (*CreditCardExpense, error) {
+	var expense CreditCardExpense
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, user_id, card_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE id = $1 AND user_id = $2", expenseID, userID).Scan(&expense.ID, &expense.UserID, &expense.CardID, &expense.Description, &expense.Amount, &expense.PurchaseDate, &expense.InstallmentCount, &expense.CategoryID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -30,16 +31,16 @@ func GetCreditCardExpense(expenseID, userID int) (*postgres.CreditCardExpense, e
 }
 
 // GetCreditCardExpensesByCard retrieves all expenses for a specific credit card
-func GetCreditCardExpensesByCard(cardID, userID int) ([]postgres.CreditCardExpense, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE card_id = $1 AND user_id = $2 AND deleted = FALSE", cardID, userID)
+func GetCreditCardExpensesByCard(ctx context.Context, cardID, userID int) ([]CreditCardExpense, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE card_id = $1 AND user_id = $2 AND deleted = FALSE", cardID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var expenses []postgres.CreditCardExpense
+	var expenses []CreditCardExpense
 	for rows.Next() {
-		var e postgres.CreditCardExpense
+		var e CreditCardExpense
 		if err := rows.Scan(&e.ID, &e.UserID, &e.Description, &e.Amount, &e.PurchaseDate, &e.InstallmentCount, &e.CategoryID); err != nil {
 			return nil, err
 		}
@@ -49,16 +50,16 @@ func GetCreditCardExpensesByCard(cardID, userID int) ([]postgres.CreditCardExpen
 }
 
 // GetDeletedCreditCardExpensesByCard retrieves all deleted expenses for a specific credit card
-func GetDeletedCreditCardExpensesByCard(cardID, userID int) ([]postgres.CreditCardExpense, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE card_id = $1 AND user_id = $2 AND deleted = TRUE", cardID, userID)
+func GetDeletedCreditCardExpensesByCard(ctx context.Context, cardID, userID int) ([]CreditCardExpense, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, purchase_date, installment_count, category_id FROM credit_card_expenses WHERE card_id = $1 AND user_id = $2 AND deleted = TRUE", cardID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var expenses []postgres.CreditCardExpense
+	var expenses []CreditCardExpense
 	for rows.Next() {
-		var e postgres.CreditCardExpense
+		var e CreditCardExpense
 		if err := rows.Scan(&e.ID, &e.UserID, &e.Description, &e.Amount, &e.PurchaseDate, &e.InstallmentCount, &e.CategoryID); err != nil {
 			return nil, err
 		}
@@ -68,19 +69,19 @@ func GetDeletedCreditCardExpensesByCard(cardID, userID int) ([]postgres.CreditCa
 }
 
 // UpdateCreditCardExpense modifies an existing credit card expense record
-func UpdateCreditCardExpense(expenseID, userID int, description string, amount float64, purchaseDate string, installmentCount int, categoryID sql.NullInt64) error {
-	_, err := postgres.DB.Exec("UPDATE credit_card_expenses SET description = $1, amount = $2, purchase_date = $3, installment_count = $4, category_id = $5 WHERE id = $6 AND user_id = $7", description, amount, purchaseDate, installmentCount, categoryID, expenseID, userID)
+func UpdateCreditCardExpense(ctx context.Context, expenseID, userID int, description string, amount float64, purchaseDate string, installmentCount int, categoryID sql.NullInt64) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE credit_card_expenses SET description = $1, amount = $2, purchase_date = $3, installment_count = $4, category_id = $5 WHERE id = $6 AND user_id = $7", description, amount, purchaseDate, installmentCount, categoryID, expenseID, userID)
 	return err
 }
 
 // DeleteCreditCardExpense marks a credit card expense as deleted
-func DeleteCreditCardExpense(expenseID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE credit_card_expenses SET deleted = TRUE WHERE id = $1 AND user_id = $2", expenseID, userID)
+func DeleteCreditCardExpense(ctx context.Context, expenseID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE credit_card_expenses SET deleted = TRUE WHERE id = $1 AND user_id = $2", expenseID, userID)
 	return err
 }
 
 // RecoveryCreditCardExpense marks a credit card expense as not deleted
-func RecoveryCreditCardExpense(expenseID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE credit_card_expenses SET deleted = FALSE WHERE id = $1 AND user_id = $2", expenseID, userID)
+func RecoveryCreditCardExpense(ctx context.Context, expenseID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE credit_card_expenses SET deleted = FALSE WHERE id = $1 AND user_id = $2", expenseID, userID)
 	return err
 }
@@ -1,22 +1,23 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // GetExpensesByUser retrieves all expenses for a specific user
-func GetExpensesByUser(userID int) ([]postgres.Expense, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE user_id = $1  AND deleted = FALSE", userID)
+func GetExpensesByUser(ctx context.Context, userID int) ([]Expense, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE user_id = $1  AND deleted = FALSE", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var expenses []postgres.Expense
+	var expenses []Expense
 	for rows.Next() {
-		var e postgres.Expense
+		var e Expense
 		if err := rows.Scan(&e.ID, &e.UserID, &e.Description, &e.Amount, &e.DueDate, &e.Paid, &e.CategoryID); err != nil {
 			return nil, err
 		}
@@ -26,16 +27,16 @@ func GetExpensesByUser(userID int) ([]postgres.Expense, error) {
 }
 
 // GetExpensesByUser retrieves all expenses for a specific user
-func GetDeletedExpensesByUser(userID int) ([]postgres.Expense, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE user_id = $1  AND deleted = TRUE", userID)
+func GetDeletedExpensesByUser(ctx context.Context, userID int) ([]Expense, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE user_id = $1  AND deleted = TRUE", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var expenses []postgres.Expense
+	var expenses []Expense
 	for rows.Next() {
-		var e postgres.Expense
+		var e Expense
 		if err := rows.Scan(&e.ID, &e.UserID, &e.Description, &e.Amount, &e.DueDate, &e.Paid, &e.CategoryID); err != nil {
 			return nil, err
 		}
@@ -45,9 +46,9 @@ func GetDeletedExpensesByUser(userID int) ([]postgres.Expense, error) {
 }
 
 // GetExpense retrieves an expense by its ID
-func GetExpense(expenseID, userID int) (*postgres.Expense, error) {
-	var expense postgres.Expense
-	err := postgres.DB.QueryRow("SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE id = $1 AND user_id = $2", expenseID, userID).Scan(&expense.ID, &expense.UserID, &expense.Description, &expense.Amount, &expense.DueDate, &expense.Paid, &expense.CategoryID)
+func GetExpense(ctx context.Context, expenseID, userID int) (*Expense, error) {
+	var expense Expense
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, user_id, description, amount, due_date, paid, category_id FROM expenses WHERE id = $1 AND user_id = $2", expenseID, userID).Scan(&expense.ID, &expense.UserID, &expense.Description, &expense.Amount, &expense.DueDate, &expense.Paid, &expense.CategoryID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -58,9 +59,9 @@ func GetExpense(expenseID, userID int) (*postgres.Expense, error) {
 }
 
 // CreateExpense inserts a new expense record into the database
-func CreateExpense(userID int, description string, amount float64, dueDate string, categoryID sql.NullInt64) (int, error) {
+func CreateExpense(ctx context.Context, userID int, description string, amount float64, dueDate string, categoryID sql.NullInt64) (int, error) {
 	var expenseID int
-	err := postgres.DB.QueryRow("INSERT INTO expenses (user_id, description, amount, due_date, category_id) VALUES ($1, $2, $3, $4, $5) RETURNING id", userID, description, amount, dueDate, categoryID).Scan(&expenseID)
+	err := postgres.DB.QueryRowContext(ctx, "INSERT INTO expenses (user_id, description, amount, due_date, category_id) VALUES ($1, $2, $3, $4, $5) RETURNING id", userID, description, amount, dueDate, categoryID).Scan(&expenseID)
 	if err != nil {
 		return 0, err
 	}
@@ -68,19 +69,19 @@ func CreateExpense(userID int, description string, amount float64, dueDate strin
 }
 
 // UpdateExpense modifies an existing expense record
-func UpdateExpense(expenseID, userID int, description string, amount float64, dueDate string, paid bool, categoryID sql.NullInt64) error {
-	_, err := postgres.DB.Exec("UPDATE expenses SET description = $1, amount = $2, due_date = $3, paid = $4, category_id = $5 WHERE id = $6 AND user_id = $7", description, amount, dueDate, paid, categoryID, expenseID, userID)
+func UpdateExpense(ctx context.Context, expenseID, userID int, description string, amount float64, dueDate string, paid bool, categoryID sql.NullInt64) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE expenses SET description = $1, amount = $2, due_date = $3, paid = $4, category_id = $5 WHERE id = $6 AND user_id = $7", description, amount, dueDate, paid, categoryID, expenseID, userID)
 	return err
 }
 
 // DeleteExpense marks an expense as deleted
-func DeleteExpense(expenseID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE expenses SET deleted = TRUE WHERE id = $1 AND user_id = $2", expenseID, userID)
+func DeleteExpense(ctx context.Context, expenseID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE expenses SET deleted = TRUE WHERE id = $1 AND user_id = $2", expenseID, userID)
 	return err
 }
 
 // RecoveryExpense marks an expense as not deleted
-func RecoveryExpense(expenseID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE expenses SET deleted = FALSE WHERE id = $1 AND user_id = $2", expenseID)
+func RecoveryExpense(ctx context.Context, expenseID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE expenses SET deleted = FALSE WHERE id = $1 AND user_id = $2", expenseID)
 	return err
 }
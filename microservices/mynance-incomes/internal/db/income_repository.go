@@ -1,15 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
+	"github.com/jvlerner/mynance-platform/postgres"
 )
 
 // CreateIncome inserts a new income record into the database
-func CreateIncome(userID int, description string, amount float64, receivedAt string, isRecurring bool) (int, error) {
+func CreateIncome(ctx context.Context, userID int, description string, amount float64, receivedAt string, isRecurring bool) (int, error) {
 	var incomeID int
-	err := postgres.DB.QueryRow("INSERT INTO incomes (user_id, description, amount, received_at, is_recurring) VALUES ($1, $2, $3, $4, $5) RETURNING id", userID, description, amount, receivedAt, isRecurring).Scan(&incomeID)
+	err := postgres.DB.QueryRowContext(ctx, "INSERT INTO incomes (user_id, description, amount, received_at, is_recurring) VALUES ($1, $2, $3, $4, $5) RETURNING id", userID, description, amount, receivedAt, isRecurring).Scan(&incomeID)
 	if err != nil {
 		return 0, err
 	}
@@ -17,9 +18,9 @@ func CreateIncome(userID int, description string, amount float64, receivedAt str
 }
 
 // GetIncome retrieves an income by its ID
-func GetIncome(incomeID, userID int) (*postgres.Income, error) {
-	var income postgres.Income
-	err := postgres.DB.QueryRow("SELECT id, user_id, description, amount, received_at, is_recurring, deleted FROM incomes WHERE id = $1 AND user_id = $2", incomeID, userID).Scan(&income.ID, &income.UserID, &income.Description, &income.Amount, &income.ReceivedAt, &income.IsRecurring, &income.Deleted)
+func GetIncome(ctx context.Context, incomeID, userID int) (*Income, error) {
+	var income Income
+	err := postgres.DB.QueryRowContext(ctx, "SELECT id, user_id, description, amount, received_at, is_recurring, deleted FROM incomes WHERE id = $1 AND user_id = $2", incomeID, userID).Scan(&income.ID, &income.UserID, &income.Description, &income.Amount, &income.ReceivedAt, &income.IsRecurring, &income.Deleted)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -30,16 +31,16 @@ func GetIncome(incomeID, userID int) (*postgres.Income, error) {
 }
 
 // GetIncomesByUser retrieves all incomes for a specific user
-func GetIncomesByUser(userID int) ([]postgres.Income, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, received_at, is_recurring FROM incomes WHERE user_id = $1 AND deleted = FALSE", userID)
+func GetIncomesByUser(ctx context.Context, userID int) ([]Income, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, received_at, is_recurring FROM incomes WHERE user_id = $1 AND deleted = FALSE", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var incomes []postgres.Income
+	var incomes []Income
 	for rows.Next() {
-		var i postgres.Income
+		var i Income
 		if err := rows.Scan(&i.ID, &i.UserID, &i.Description, &i.Amount, &i.ReceivedAt, &i.IsRecurring); err != nil {
 			return nil, err
 		}
@@ -49,16 +50,16 @@ func GetIncomesByUser(userID int) ([]postgres.Income, error) {
 }
 
 // GetDeletedIncomesByUser retrieves all deleted incomes for a specific user
-func GetDeletedIncomesByUser(userID int) ([]postgres.Income, error) {
-	rows, err := postgres.DB.Query("SELECT id, user_id, description, amount, received_at, is_recurring FROM incomes WHERE user_id = $1 AND deleted = TRUE", userID)
+func GetDeletedIncomesByUser(ctx context.Context, userID int) ([]Income, error) {
+	rows, err := postgres.DB.QueryContext(ctx, "SELECT id, user_id, description, amount, received_at, is_recurring FROM incomes WHERE user_id = $1 AND deleted = TRUE", userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var incomes []postgres.Income
+	var incomes []Income
 	for rows.Next() {
-		var i postgres.Income
+		var i Income
 		if err := rows.Scan(&i.ID, &i.UserID, &i.Description, &i.Amount, &i.ReceivedAt, &i.IsRecurring); err != nil {
 			return nil, err
 		}
@@ -68,19 +69,19 @@ func GetDeletedIncomesByUser(userID int) ([]postgres.Income, error) {
 }
 
 // UpdateIncome modifies an existing income record
-func UpdateIncome(incomeID, userID int, description string, amount float64, receivedAt string, isRecurring bool) error {
-	_, err := postgres.DB.Exec("UPDATE incomes SET description = $1, amount = $2, received_at = $3, is_recurring = $4 WHERE id = $5 AND user_id = $6", description, amount, receivedAt, isRecurring, incomeID, userID)
+func UpdateIncome(ctx context.Context, incomeID, userID int, description string, amount float64, receivedAt string, isRecurring bool) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE incomes SET description = $1, amount = $2, received_at = $3, is_recurring = $4 WHERE id = $5 AND user_id = $6", description, amount, receivedAt, isRecurring, incomeID, userID)
 	return err
 }
 
 // DeleteIncome marks an income as deleted
-func DeleteIncome(incomeID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE incomes SET deleted = TRUE WHERE id = $1 AND user_id = $2", incomeID, userID)
+func DeleteIncome(ctx context.Context, incomeID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE incomes SET deleted = TRUE WHERE id = $1 AND user_id = $2", incomeID, userID)
 	return err
 }
 
 // RecoveryIncome marks an income as not deleted
-func RecoveryIncome(incomeID, userID int) error {
-	_, err := postgres.DB.Exec("UPDATE incomes SET deleted = FALSE WHERE id = $1 AND user_id = $2", incomeID, userID)
+func RecoveryIncome(ctx context.Context, incomeID, userID int) error {
+	_, err := postgres.DB.ExecContext(ctx, "UPDATE incomes SET deleted = FALSE WHERE id = $1 AND user_id = $2", incomeID, userID)
 	return err
 }
@@ -2,24 +2,6 @@ package db
 
 import "time"
 
-type User struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"createdAt"`
-	Active    bool      `json:"active"`
-}
-
-type Profile struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
-	Active    bool      `json:"active"`
-}
-
 type Category struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"userId"`
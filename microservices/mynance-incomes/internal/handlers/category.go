@@ -5,20 +5,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateCategory handles category creation requests
 func CreateCategory(c *gin.Context) {
-	var request postgres.Category
-	userID := c.MustGet("user_id").(int)
+	var request db.Category
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	categoryID, err := db.CreateCategory(userID, request.Name, request.Color)
+	categoryID, err := db.CreateCategory(c.Request.Context(), userID, request.Name, request.Color)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
 		return
@@ -29,9 +28,9 @@ func CreateCategory(c *gin.Context) {
 
 // GetCategories handles retrieving all active categories
 func GetCategories(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	categories, err := db.GetCategories(userID)
+	categories, err := db.GetCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -42,9 +41,9 @@ func GetCategories(c *gin.Context) {
 
 // GetCategories handles retrieving all active categories
 func GetAllCategories(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	categories, err := db.GetAllCategories(userID)
+	categories, err := db.GetAllCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -55,9 +54,9 @@ func GetAllCategories(c *gin.Context) {
 
 // GetCategories handles retrieving all active categories
 func GetInactivateCategories(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	categories, err := db.GetInactiveCategories(userID)
+	categories, err := db.GetInactiveCategories(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
 		return
@@ -68,15 +67,15 @@ func GetInactivateCategories(c *gin.Context) {
 
 // GetCategory retrieves a category by ID
 func GetCategory(c *gin.Context) {
-	var request postgres.Category
-	userID := c.MustGet("user_id").(int)
+	var request db.Category
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	category, err := db.GetCategory(userID, request.ID)
+	category, err := db.GetCategory(c.Request.Context(), userID, request.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve category"})
 		return
@@ -91,15 +90,15 @@ func GetCategory(c *gin.Context) {
 
 // UpdateCategory modifies an existing category
 func UpdateCategory(c *gin.Context) {
-	var request postgres.Category
-	userID := c.MustGet("user_id").(int)
+	var request db.Category
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.UpdateCategory(userID, request.ID, request.Name, request.Color)
+	err := db.UpdateCategory(c.Request.Context(), userID, request.ID, request.Name, request.Color)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
 		return
@@ -110,15 +109,15 @@ func UpdateCategory(c *gin.Context) {
 
 // DeactivateCategory marks a category as inactive
 func DeactivateCategory(c *gin.Context) {
-	var request postgres.Category
-	userID := c.MustGet("user_id").(int)
+	var request db.Category
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.DeactivateCategory(request.ID, userID)
+	err := db.DeactivateCategory(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate category"})
 		return
@@ -129,14 +128,14 @@ func DeactivateCategory(c *gin.Context) {
 
 // ActivateCategory marks a category as active
 func ActivateCategory(c *gin.Context) {
-	var request postgres.Category
-	userID := c.MustGet("user_id").(int)
+	var request db.Category
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
-	err := db.ActivateCategory(request.ID, userID)
+	err := db.ActivateCategory(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate category"})
 		return
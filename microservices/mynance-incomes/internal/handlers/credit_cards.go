@@ -4,15 +4,14 @@ import (
 	"net/http"
 
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CreateCreditCard handles credit card creation requests
 func CreateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCard
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
@@ -26,7 +25,7 @@ func CreateCreditCard(c *gin.Context) {
 		return
 	}
 
-	cardID, err := db.CreateCreditCard(userID, request.Name, request.Bank, request.LimitAmount, request.DueDay)
+	cardID, err := db.CreateCreditCard(c.Request.Context(), userID, request.Name, request.Bank, request.LimitAmount, request.DueDay)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credit card"})
 		return
@@ -37,9 +36,9 @@ func CreateCreditCard(c *gin.Context) {
 
 // GetCreditCards handles retrieving all active credit cards
 func GetCreditCards(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	cards, err := db.GetCreditCardsByUser(userID)
+	cards, err := db.GetCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit cards"})
 		return
@@ -50,9 +49,9 @@ func GetCreditCards(c *gin.Context) {
 
 // GetCreditCards handles retrieving all active credit cards
 func GetAllCreditCards(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	cards, err := db.GetAllCreditCardsByUser(userID)
+	cards, err := db.GetAllCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit cards"})
 		return
@@ -62,9 +61,9 @@ func GetAllCreditCards(c *gin.Context) {
 }
 
 func GetInactiveCreditCards(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	cards, err := db.GetInactiveCreditCardsByUser(userID)
+	cards, err := db.GetInactiveCreditCardsByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inactive credit cards"})
 		return
@@ -75,15 +74,15 @@ func GetInactiveCreditCards(c *gin.Context) {
 
 // GetCreditCard retrieves a credit card by ID
 func GetCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCard
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	card, err := db.GetCreditCard(request.ID, userID)
+	card, err := db.GetCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card"})
 		return
@@ -98,15 +97,15 @@ func GetCreditCard(c *gin.Context) {
 
 // UpdateCreditCard modifies an existing credit card
 func UpdateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCard
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.UpdateCreditCard(request.ID, userID, request.DueDay, request.Name, request.Bank, request.LimitAmount)
+	err := db.UpdateCreditCard(c.Request.Context(), request.ID, userID, request.DueDay, request.Name, request.Bank, request.LimitAmount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit card"})
 		return
@@ -117,15 +116,15 @@ func UpdateCreditCard(c *gin.Context) {
 
 // DeactivateCreditCard marks a credit card as inactive
 func DeactivateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCard
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.DeactivateCreditCard(request.ID, userID)
+	err := db.DeactivateCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate credit card"})
 		return
@@ -136,15 +135,15 @@ func DeactivateCreditCard(c *gin.Context) {
 
 // ActivateCreditCard marks a credit card as active
 func ActivateCreditCard(c *gin.Context) {
-	var request postgres.CreditCard
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCard
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.ActivateCreditCard(request.ID, userID)
+	err := db.ActivateCreditCard(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate credit card"})
 		return
@@ -6,13 +6,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateCreditCardExpense handles credit card expense creation requests
 func CreateCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
@@ -25,7 +24,7 @@ func CreateCreditCardExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID)
 	}
 
-	expenseID, err := db.CreateCreditCardExpense(request.CardID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
+	expenseID, err := db.CreateCreditCardExpense(c.Request.Context(), request.CardID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credit card expense"})
 		return
@@ -36,15 +35,15 @@ func CreateCreditCardExpense(c *gin.Context) {
 
 // GetCreditCardExpenses retrieves all active credit card expenses for a specific card
 func GetCreditCardExpenses(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	expenses, err := db.GetCreditCardExpensesByCard(request.CardID, userID)
+	expenses, err := db.GetCreditCardExpensesByCard(c.Request.Context(), request.CardID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card expenses"})
 		return
@@ -55,15 +54,15 @@ func GetCreditCardExpenses(c *gin.Context) {
 
 // GetCreditCardExpense retrieves a credit card expense by ID
 func GetCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	expense, err := db.GetCreditCardExpense(request.ID, userID)
+	expense, err := db.GetCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve credit card expense"})
 		return
@@ -78,8 +77,8 @@ func GetCreditCardExpense(c *gin.Context) {
 
 // UpdateCreditCardExpense modifies an existing credit card expense
 func UpdateCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
@@ -92,7 +91,7 @@ func UpdateCreditCardExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID)
 	}
 
-	err := db.UpdateCreditCardExpense(request.ID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
+	err := db.UpdateCreditCardExpense(c.Request.Context(), request.ID, userID, request.Description, request.Amount, request.PurchaseDate.Format("2006-01-02"), request.InstallmentCount, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credit card expense"})
 		return
@@ -103,15 +102,15 @@ func UpdateCreditCardExpense(c *gin.Context) {
 
 // DeleteCreditCardExpense marks a credit card expense as deleted
 func DeleteCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.DeleteCreditCardExpense(request.ID, userID)
+	err := db.DeleteCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete credit card expense"})
 		return
@@ -122,15 +121,15 @@ func DeleteCreditCardExpense(c *gin.Context) {
 
 // RecoveryCreditCardExpense marks a credit card expense as not deleted
 func RecoveryCreditCardExpense(c *gin.Context) {
-	var request postgres.CreditCardExpense
-	userID := c.MustGet("user_id").(int)
+	var request db.CreditCardExpense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.RecoveryCreditCardExpense(request.ID, userID)
+	err := db.RecoveryCreditCardExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recover credit card expense"})
 		return
@@ -6,13 +6,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateExpense handles expense creation requests
 func CreateExpense(c *gin.Context) {
-	var request postgres.Expense
-	userID := c.MustGet("user_id").(int)
+	var request db.Expense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
@@ -25,7 +24,7 @@ func CreateExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID) // Converte int para int64
 	}
 
-	expenseID, err := db.CreateExpense(userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), categoryID)
+	expenseID, err := db.CreateExpense(c.Request.Context(), userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense"})
 		return
@@ -36,9 +35,9 @@ func CreateExpense(c *gin.Context) {
 
 // GetExpenses handles retrieving all active expenses for a user
 func GetExpenses(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+	userID := c.GetInt("userId")
 
-	expenses, err := db.GetExpensesByUser(userID)
+	expenses, err := db.GetExpensesByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expenses"})
 		return
@@ -49,15 +48,15 @@ func GetExpenses(c *gin.Context) {
 
 // GetExpense retrieves an expense by ID
 func GetExpense(c *gin.Context) {
-	var request postgres.Expense
-	userID := c.MustGet("user_id").(int)
+	var request db.Expense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	expense, err := db.GetExpense(request.ID, userID)
+	expense, err := db.GetExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expense"})
 		return
@@ -72,8 +71,8 @@ func GetExpense(c *gin.Context) {
 
 // UpdateExpense modifies an existing expense
 func UpdateExpense(c *gin.Context) {
-	var request postgres.Expense
-	userID := c.MustGet("user_id").(int)
+	var request db.Expense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
@@ -86,7 +85,7 @@ func UpdateExpense(c *gin.Context) {
 		categoryID.Int64 = int64(*request.CategoryID) // Converte int para int64
 	}
 
-	err := db.UpdateExpense(request.ID, userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), request.Paid, categoryID)
+	err := db.UpdateExpense(c.Request.Context(), request.ID, userID, request.Description, request.Amount, request.DueDate.Format("2006-01-02"), request.Paid, categoryID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
 		return
@@ -97,15 +96,15 @@ func UpdateExpense(c *gin.Context) {
 
 // DeleteExpense marks an expense as deleted
 func DeleteExpense(c *gin.Context) {
-	var request postgres.Expense
-	userID := c.MustGet("user_id").(int)
+	var request db.Expense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.DeleteExpense(request.ID, userID)
+	err := db.DeleteExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense"})
 		return
@@ -116,15 +115,15 @@ func DeleteExpense(c *gin.Context) {
 
 // RecoveryExpense marks an expense as not deleted
 func RecoveryExpense(c *gin.Context) {
-	var request postgres.Expense
-	userID := c.MustGet("user_id").(int)
+	var request db.Expense
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.RecoveryExpense(request.ID, userID)
+	err := db.RecoveryExpense(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recover expense"})
 		return
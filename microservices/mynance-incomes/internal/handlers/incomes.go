@@ -5,20 +5,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jvlerner/my-finance-api/internal/db"
-	"github.com/jvlerner/my-finance-api/pkg/postgres"
 )
 
 // CreateIncome handles income creation requests
 func CreateIncome(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	incomeID, err := db.CreateIncome(userID, request.Description, request.Amount, request.ReceivedAt.Format("2006-01-02"), request.IsRecurring)
+	incomeID, err := db.CreateIncome(c.Request.Context(), userID, request.Description, request.Amount, request.ReceivedAt.Format("2006-01-02"), request.IsRecurring)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create income"})
 		return
@@ -29,15 +28,15 @@ func CreateIncome(c *gin.Context) {
 
 // GetIncomes handles retrieving all active incomes for a user
 func GetIncomes(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	incomes, err := db.GetIncomesByUser(userID)
+	incomes, err := db.GetIncomesByUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve incomes"})
 		return
@@ -48,15 +47,15 @@ func GetIncomes(c *gin.Context) {
 
 // GetIncome retrieves an income by ID
 func GetIncome(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	income, err := db.GetIncome(request.ID, userID)
+	income, err := db.GetIncome(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve income"})
 		return
@@ -71,15 +70,15 @@ func GetIncome(c *gin.Context) {
 
 // UpdateIncome modifies an existing income
 func UpdateIncome(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.UpdateIncome(request.ID, userID, request.Description, request.Amount, request.ReceivedAt.Format("2006-01-02"), request.IsRecurring)
+	err := db.UpdateIncome(c.Request.Context(), request.ID, userID, request.Description, request.Amount, request.ReceivedAt.Format("2006-01-02"), request.IsRecurring)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update income"})
 		return
@@ -90,15 +89,15 @@ func UpdateIncome(c *gin.Context) {
 
 // DeleteIncome marks an income as deleted
 func DeleteIncome(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.DeleteIncome(request.ID, userID)
+	err := db.DeleteIncome(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete income"})
 		return
@@ -109,15 +108,15 @@ func DeleteIncome(c *gin.Context) {
 
 // DeleteIncome marks an income as not deleted
 func RecoveryIncome(c *gin.Context) {
-	var request postgres.Income
-	userID := c.MustGet("user_id").(int)
+	var request db.Income
+	userID := c.GetInt("userId")
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request, unexpected fields"})
 		return
 	}
 
-	err := db.RecoveryIncome(request.ID, userID)
+	err := db.RecoveryIncome(c.Request.Context(), request.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recover income"})
 		return
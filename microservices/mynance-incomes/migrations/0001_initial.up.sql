@@ -0,0 +1,14 @@
+-- Esquema criado antes pelo init.sql de dbs/microservices/db-incomes; IF NOT EXISTS deixa bancos já criados por ele adotarem as migrações
+
+CREATE TABLE IF NOT EXISTS incomes (
+    id SERIAL PRIMARY KEY,
+    user_id INT,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    received_at DATE NOT NULL,
+    is_recurring BOOLEAN DEFAULT FALSE,
+    deleted BOOLEAN DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_income_user ON incomes(user_id);
+CREATE INDEX IF NOT EXISTS idx_income_received_at ON incomes(user_id,received_at);
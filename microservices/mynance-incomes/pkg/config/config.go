@@ -1,23 +0,0 @@
-package config
-
-import (
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
-)
-
-func LoadEnv() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("[INFO] Using system environment variables")
-		return
-	}
-	log.Println("[INFO] Using .env variables")
-}
-
-func GetEnv(key string, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return fallback
-}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// expiryLeeway avoids handing out a token that expires while the request is in flight
+	expiryLeeway = 10 * time.Second
+	// minRefreshMargin is the least time before expiry at which the background refresh runs
+	minRefreshMargin = 2 * time.Minute
+	// minRefreshWait keeps the loop from spinning when the token is already expired or the clock is skewed
+	minRefreshWait = 5 * time.Second
+	loginTimeout   = 10 * time.Second
+)
+
+// TokenManager keeps the service token used to call mynance-auth-admin fresh
+type TokenManager struct {
+	ServiceEmail    string
+	ServicePassword string
+	AuthURL         string
+
+	client     *http.Client
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	minWait    time.Duration
+
+	mu        sync.RWMutex
+	token     string
+	issuedAt  time.Time
+	expiresAt time.Time
+
+	group    singleflight.Group
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTokenManager creates a manager for the given service account
+func NewTokenManager(email, password, authURL string) *TokenManager {
+	return &TokenManager{
+		ServiceEmail:    email,
+		ServicePassword: password,
+		AuthURL:         authURL,
+		client:          &http.Client{Timeout: loginTimeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		minBackoff:      time.Second,
+		maxBackoff:      time.Minute,
+		minWait:         minRefreshWait,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// Start logs in and keeps refreshing the token in the background until Stop is called
+func (m *TokenManager) Start() {
+	prometheus.SetServiceTokenForMonitoring(m.Age)
+	go m.run()
+}
+
+// Stop ends the background refresh
+func (m *TokenManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+// Token returns a valid service token, logging in first if there is none
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.RLock()
+	token, expiresAt := m.token, m.expiresAt
+	m.mu.RUnlock()
+
+	if token != "" && time.Until(expiresAt) > expiryLeeway {
+		return token, nil
+	}
+	return m.refresh(ctx)
+}
+
+// Invalidate drops the current token, e.g. after mynance-auth-admin rejected it
+func (m *TokenManager) Invalidate() {
+	m.mu.Lock()
+	m.token = ""
+	m.expiresAt = time.Time{}
+	m.mu.Unlock()
+}
+
+// Age returns how long ago the current token was issued, in seconds
+func (m *TokenManager) Age() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.token == "" {
+		return 0
+	}
+	return time.Since(m.issuedAt).Seconds()
+}
+
+// refresh logs in once no matter how many callers need a new token at the same time
+func (m *TokenManager) refresh(ctx context.Context) (string, error) {
+	result := m.group.DoChan("login", func() (interface{}, error) {
+		return m.login()
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (m *TokenManager) login() (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"email":    m.ServiceEmail,
+		"password": m.ServicePassword,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := m.client.Post(m.AuthURL+"/auth/service/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		prometheus.ServiceTokenRefreshes.WithLabelValues("error").Inc()
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		prometheus.ServiceTokenRefreshes.WithLabelValues("error").Inc()
+		return "", fmt.Errorf("service login returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		prometheus.ServiceTokenRefreshes.WithLabelValues("error").Inc()
+		return "", err
+	}
+	if result.Token == "" || result.ExpiresAt == 0 {
+		prometheus.ServiceTokenRefreshes.WithLabelValues("error").Inc()
+		return "", errors.New("service login returned an empty token")
+	}
+
+	m.mu.Lock()
+	m.token = result.Token
+	m.issuedAt = time.Now()
+	m.expiresAt = time.Unix(result.ExpiresAt, 0)
+	m.mu.Unlock()
+
+	prometheus.ServiceTokenRefreshes.WithLabelValues("success").Inc()
+	return result.Token, nil
+}
+
+// run refreshes the token ahead of its expiry and retries with backoff while the auth service is down
+func (m *TokenManager) run() {
+	defer close(m.done)
+
+	backoff := m.minBackoff
+	wait := time.Duration(0)
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-m.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := m.refresh(context.Background()); err != nil {
+			logger.Log.Error("Failed to refresh service token", zap.Duration("retryIn", backoff), zap.Error(err))
+			wait = withJitter(backoff)
+			backoff = min(backoff*2, m.maxBackoff)
+			continue
+		}
+
+		backoff = m.minBackoff
+		wait = m.untilRefresh()
+	}
+}
+
+// untilRefresh returns how long to wait before renewing the current token
+func (m *TokenManager) untilRefresh() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Renova com 20% da validade restante, nunca em cima da hora
+	lifetime := m.expiresAt.Sub(m.issuedAt)
+	margin := min(max(lifetime/5, minRefreshMargin), lifetime/2)
+	return max(time.Until(m.expiresAt.Add(-margin)), m.minWait)
+}
+
+func withJitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
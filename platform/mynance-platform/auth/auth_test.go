@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// fakeAuthAdmin answers /auth/service/login like mynance-auth-admin, counting the logins
+type fakeAuthAdmin struct {
+	*httptest.Server
+
+	logins atomic.Int32
+	// down makes the login answer 503, as when the service is unavailable
+	down atomic.Bool
+	// ttl is the validity of the tokens issued
+	ttl atomic.Int64
+	// release, when set, holds every login until it is closed
+	release chan struct{}
+
+	mu      sync.Mutex
+	loginAt []time.Time
+}
+
+func newFakeAuthAdmin(t *testing.T, ttl time.Duration) *fakeAuthAdmin {
+	t.Helper()
+
+	f := &fakeAuthAdmin{}
+	f.ttl.Store(int64(ttl))
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/auth/service/login" {
+			http.NotFound(w, r)
+			return
+		}
+
+		n := f.logins.Add(1)
+		f.mu.Lock()
+		f.loginAt = append(f.loginAt, time.Now())
+		f.mu.Unlock()
+
+		if f.release != nil {
+			<-f.release
+		}
+		if f.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var input struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Email != "svc@mynance" || input.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":     fmt.Sprintf("token-%d", n),
+			"expiresAt": time.Now().Add(time.Duration(f.ttl.Load())).Unix(),
+		})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeAuthAdmin) times() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.loginAt...)
+}
+
+func newTestManager(f *fakeAuthAdmin) *TokenManager {
+	m := NewTokenManager("svc@mynance", "secret", f.URL)
+	m.client = f.Client()
+	m.minBackoff = 20 * time.Millisecond
+	m.maxBackoff = 80 * time.Millisecond
+	m.minWait = 50 * time.Millisecond
+	return m
+}
+
+// startLoop runs the background refresh without Start, which registers the Prometheus gauge once per process
+func startLoop(t *testing.T, m *TokenManager) {
+	t.Helper()
+	go m.run()
+	t.Cleanup(m.Stop)
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTokenConcurrentCallsShareOneLogin(t *testing.T) {
+	f := newFakeAuthAdmin(t, time.Hour)
+	f.release = make(chan struct{})
+	m := newTestManager(f)
+
+	const callers = 50
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = m.Token(context.Background())
+		}()
+	}
+
+	// Segura o login até todos estarem esperando por ele
+	waitFor(t, 2*time.Second, func() bool { return f.logins.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+	close(f.release)
+	wg.Wait()
+
+	if got := f.logins.Load(); got != 1 {
+		t.Fatalf("logins = %d, want 1", got)
+	}
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if tokens[i] != "token-1" {
+			t.Fatalf("caller %d got %q, want token-1", i, tokens[i])
+		}
+	}
+
+	// Token ainda válido: nenhum login novo
+	if _, err := m.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.logins.Load(); got != 1 {
+		t.Fatalf("logins after cached call = %d, want 1", got)
+	}
+}
+
+func TestTokenRefreshedInBackgroundBeforeExpiry(t *testing.T) {
+	f := newFakeAuthAdmin(t, 3*time.Second)
+	m := newTestManager(f)
+	startLoop(t, m)
+
+	waitFor(t, 2*time.Second, func() bool { return f.logins.Load() >= 1 })
+	m.mu.RLock()
+	firstExpiry := m.expiresAt
+	m.mu.RUnlock()
+
+	waitFor(t, 4*time.Second, func() bool { return f.logins.Load() >= 2 })
+	times := f.times()
+	if !times[1].Before(firstExpiry) {
+		t.Fatalf("second login at %s, after the first token expired at %s", times[1], firstExpiry)
+	}
+
+	token, err := m.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "token-1" {
+		t.Fatal("Token still returns the first token after the refresh")
+	}
+}
+
+func TestTokenBacksOffWhileAuthAdminIsDown(t *testing.T) {
+	f := newFakeAuthAdmin(t, time.Hour)
+	f.down.Store(true)
+	m := newTestManager(f)
+	startLoop(t, m)
+
+	time.Sleep(600 * time.Millisecond)
+	attempts := f.logins.Load()
+	// Sem backoff o laço faria milhares de tentativas; com 20ms..80ms e jitter são no máximo umas 20
+	if attempts < 3 || attempts > 25 {
+		t.Fatalf("attempts while down = %d, want between 3 and 25", attempts)
+	}
+
+	times := f.times()
+	if first, last := times[1].Sub(times[0]), times[len(times)-1].Sub(times[len(times)-2]); last <= first {
+		t.Fatalf("retry interval did not grow: first %s, last %s", first, last)
+	}
+
+	if _, err := m.Token(context.Background()); err == nil {
+		t.Fatal("Token succeeded while auth-admin is down")
+	}
+
+	// Volta do serviço: o laço consegue o token na próxima tentativa
+	f.down.Store(false)
+	waitFor(t, 2*time.Second, func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.token != ""
+	})
+	if _, err := m.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenManagerStop(t *testing.T) {
+	f := newFakeAuthAdmin(t, time.Hour)
+	f.down.Store(true)
+	m := newTestManager(f)
+	go m.run()
+
+	waitFor(t, 2*time.Second, func() bool { return f.logins.Load() >= 1 })
+
+	stopped := make(chan struct{})
+	go func() {
+		m.Stop()
+		// Chamar de novo não pode travar nem entrar em pânico
+		m.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	attempts := f.logins.Load()
+	time.Sleep(300 * time.Millisecond)
+	if got := f.logins.Load(); got != attempts {
+		t.Fatalf("logins went from %d to %d after Stop", attempts, got)
+	}
+}
+
+func TestUntilRefreshWaitsAtLeastMinWait(t *testing.T) {
+	m := NewTokenManager("svc@mynance", "secret", "http://auth-admin")
+
+	// Token já vencido: sem o mínimo o laço renovaria sem parar
+	m.issuedAt = time.Now().Add(-2 * time.Hour)
+	m.expiresAt = time.Now().Add(-time.Hour)
+	if got := m.untilRefresh(); got != minRefreshWait {
+		t.Fatalf("untilRefresh with an expired token = %s, want %s", got, minRefreshWait)
+	}
+
+	m.issuedAt = time.Now()
+	m.expiresAt = m.issuedAt.Add(time.Hour)
+	if got := m.untilRefresh(); got < 40*time.Minute || got > 50*time.Minute {
+		t.Fatalf("untilRefresh with a fresh 1h token = %s, want about 48m", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Package authtest fakes mynance-auth and mynance-auth-admin for the tests of services that authenticate users
+package authtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+)
+
+// User of every token the fake issues
+const (
+	UserID    = 7
+	UserEmail = "ana@example.com"
+)
+
+const keyID = "user-key"
+
+// Server plays mynance-auth (JWKS) and mynance-auth-admin (service login and validate-token).
+// validate-token only accepts the tokens issued by Sign and PersonalAccessToken.
+type Server struct {
+	*httptest.Server
+
+	key     *rsa.PrivateKey
+	answers sync.Map
+}
+
+// Start starts the fake and points AUTH_URL, JWKS_URL and AUTH_AUDIENCE at it, for auth.Init or server.New to read
+func Start(audience string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks.JSONWebKeySet{Keys: []jwks.JSONWebKey{{
+			Kty: "RSA",
+			Kid: keyID,
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/auth/service/login", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"token": "service-token", "expiresAt": time.Now().Add(time.Hour).Unix()})
+	})
+	mux.HandleFunc("/auth/service/validate-token", func(w http.ResponseWriter, r *http.Request) {
+		answer, ok := s.answers.Load(r.Header.Get("X-User-Token"))
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"valid": false, "error": "unknown token"})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(answer)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+	s.Server = httptest.NewServer(mux)
+
+	os.Setenv("AUTH_URL", s.URL)
+	os.Setenv("JWKS_URL", s.URL+"/jwks")
+	os.Setenv("AUTH_AUDIENCE", audience)
+	return s
+}
+
+// Introspection builds the answer of validate-token for a valid token of the user; fields are added to it
+func Introspection(tokenType string, fields map[string]any) map[string]any {
+	answer := map[string]any{"valid": true, "userId": UserID, "email": UserEmail, "role": "user", "tokenType": tokenType}
+	for key, value := range fields {
+		answer[key] = value
+	}
+	return answer
+}
+
+// Sign signs a token for the audience like mynance-auth and registers it as valid, with fields in its introspection
+func (s *Server) Sign(tokenType string, audience []string, fields map[string]any) (string, error) {
+	issuer := claims.IssuerUser
+	if tokenType == claims.TypeImpersonation {
+		issuer = claims.IssuerAdmin
+	}
+	tokenClaims, err := claims.New(tokenType, issuer, audience, UserID, UserEmail, "user", time.Minute)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+	s.answers.Store(signed, Introspection(tokenType, fields))
+	return signed, nil
+}
+
+// PersonalAccessToken registers an opaque token with the given scopes
+func (s *Server) PersonalAccessToken(name string, scopes ...string) string {
+	token := claims.PersonalAccessTokenPrefix + name
+	s.answers.Store(token, Introspection(claims.TypePersonalAccessToken, map[string]any{"scopes": scopes}))
+	return token
+}
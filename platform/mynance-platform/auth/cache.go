@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	key       string
+	claims    *Claims
+	expiresAt time.Time
+}
+
+// ClaimsCache is a bounded LRU cache of validated claims with a per-entry TTL
+type ClaimsCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewClaimsCache creates a cache that keeps at most size entries
+func NewClaimsCache(size int) *ClaimsCache {
+	return &ClaimsCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the cached claims if they have not expired
+func (c *ClaimsCache) Get(key string) (*Claims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.claims, true
+}
+
+// Set stores claims for ttl, evicting the least recently used entry when full
+func (c *ClaimsCache) Set(key string, claims *Claims, ttl time.Duration) {
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = &cacheEntry{key: key, claims: claims, expiresAt: time.Now().Add(ttl)}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, claims: claims, expiresAt: time.Now().Add(ttl)})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Delete removes a key from the cache
+func (c *ClaimsCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+type Claims struct {
+	UserID    int    `json:"userId"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"expiresAt"`
+	// TokenType is claims.TypeUser for sessions and claims.TypePersonalAccessToken for scripts
+	TokenType string `json:"tokenType"`
+	// Scopes limit what a personal access token or an OAuth client can do; sessions have none and full access
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is set on tokens issued to OAuth clients
+	ClientID string `json:"clientId,omitempty"`
+	// Actor is the admin behind an impersonation token, which is read-only unless AllowWrite
+	Actor      *claims.Actor `json:"actor,omitempty"`
+	AllowWrite bool          `json:"allowWrite,omitempty"`
+}
+
+var (
+	Tokens *TokenManager
+
+	audience string
+	userKeys *jwks.RemoteKeySet
+	cache    *ClaimsCache
+	breaker  *Breaker
+	// O transporte propaga o traceparent: a validação aparece no trace da requisição
+	httpClient = &http.Client{Timeout: 5 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	cacheTTL   time.Duration
+
+	failOpen    bool
+	failOpenMax time.Duration
+	// outageStart é quando o mynance-auth-admin parou de responder; zero enquanto ele responde
+	outageMu    sync.Mutex
+	outageStart time.Time
+)
+
+// Init configures local token verification and starts the service token refresh; call it after the environment is loaded
+func Init() {
+	Tokens = NewTokenManager(os.Getenv("SERVICE_EMAIL"), os.Getenv("SERVICE_PASSWORD"), os.Getenv("AUTH_URL"))
+	Tokens.Start()
+
+	// Tokens de usuário listam no "aud" os serviços que podem aceitá-los
+	audience = os.Getenv("AUTH_AUDIENCE")
+	if audience == "" {
+		logger.Log.Fatal("AUTH_AUDIENCE is not set")
+	}
+
+	userKeys = jwks.NewRemoteKeySet(os.Getenv("JWKS_URL"))
+	if err := userKeys.Refresh(); err != nil {
+		// O mynance-auth pode subir depois; as chaves são buscadas de novo na primeira validação
+		logger.Log.Warn("Failed to fetch user signing keys", zap.Error(err))
+	}
+
+	cache = NewClaimsCache(intFromEnv("AUTH_CACHE_SIZE", 10000))
+	cacheTTL = durationFromEnv("AUTH_CACHE_TTL", 30*time.Second)
+	breaker = NewBreaker(intFromEnv("AUTH_BREAKER_THRESHOLD", 5), durationFromEnv("AUTH_BREAKER_COOLDOWN", 30*time.Second))
+	// Sem o mynance-auth-admin não há como saber de logout ou troca de senha: só com opt-in explícito
+	failOpen = os.Getenv("AUTH_FAIL_OPEN") == "true"
+	failOpenMax = durationFromEnv("AUTH_FAIL_OPEN_MAX", 5*time.Minute)
+}
+
+// Close stops the service token refresh
+func Close() {
+	if Tokens != nil {
+		Tokens.Stop()
+	}
+}
+
+// FailOpen reports whether requests are let through on a valid signature while mynance-auth-admin is down,
+// which AUTH_FAIL_OPEN=true allows for at most AUTH_FAIL_OPEN_MAX of each outage
+func FailOpen() bool {
+	return failOpen
+}
+
+// CheckAuthAdmin reports whether mynance-auth-admin answers its liveness probe
+func CheckAuthAdmin(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, Tokens.AuthURL+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from mynance-auth-admin", resp.StatusCode)
+	}
+	return nil
+}
+
+// ValidateUserToken verifies the token signature locally and checks revocation with mynance-auth-admin on cache miss.
+// Personal access tokens are opaque and only validated by mynance-auth-admin, like impersonation tokens, which are
+// never cached so that mynance-auth-admin records every request (method and path) made with them. Their aud is checked
+// here, since only the receiving service knows its own audience.
+func ValidateUserToken(ctx context.Context, userToken, method, path string) (*Claims, error) {
+	start := time.Now()
+	key := cacheKey(userToken)
+
+	if cached, ok := cache.Get(key); ok {
+		prometheus.AuthCacheRequests.WithLabelValues("hit").Inc()
+		observeValidation(start, "cache", "valid")
+		return cached, nil
+	}
+	prometheus.AuthCacheRequests.WithLabelValues("miss").Inc()
+
+	// Assinatura e expiração são verificadas sem sair do serviço
+	var localClaims *Claims
+	if !claims.IsPersonalAccessToken(userToken) {
+		if impersonation := impersonationClaims(userToken); impersonation != nil {
+			// A assinatura é do mynance-auth-admin e ele a confere, mas não sabe qual serviço recebeu o token
+			if !impersonation.Audience.Contains(audience) {
+				observeValidation(start, "local", "invalid")
+				return nil, fmt.Errorf("token is not meant for %s", audience)
+			}
+		} else {
+			var err error
+			localClaims, err = verifyLocally(userToken)
+			if err != nil {
+				observeValidation(start, "local", "invalid")
+				return nil, err
+			}
+		}
+	}
+
+	// Revogação (logout, troca de senha) só o mynance-auth-admin sabe responder
+	remoteClaims, hint, err := introspect(ctx, userToken, method, path)
+	if err != nil {
+		var invalid *invalidTokenError
+		if errors.As(err, &invalid) {
+			authAdminAnswered()
+			observeValidation(start, "remote", "invalid")
+			return nil, err
+		}
+
+		// Token de acesso pessoal ou de personificação não tem como ser aceito sem o mynance-auth-admin
+		if failOpen && localClaims != nil && withinFailOpenWindow() {
+			// Sem cache: a revogação volta a ser checada assim que o serviço responder
+			observeValidation(start, "local", "degraded")
+			return localClaims, nil
+		}
+		observeValidation(start, "remote", "error")
+		return nil, err
+	}
+
+	authAdminAnswered()
+
+	ttl := cacheTTL
+	// O auth-admin informa por quanto tempo a resposta pode ser reaproveitada
+	if hint >= 0 && hint < ttl {
+		ttl = hint
+	}
+	// expiresAt 0: token de acesso pessoal sem expiração
+	if remoteClaims.ExpiresAt > 0 {
+		if untilExpiry := time.Until(time.Unix(remoteClaims.ExpiresAt, 0)); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	if ttl > 0 {
+		cache.Set(key, remoteClaims, ttl)
+	}
+
+	observeValidation(start, "remote", "valid")
+	return remoteClaims, nil
+}
+
+// withinFailOpenWindow reports whether the current mynance-auth-admin outage started less than failOpenMax ago;
+// revoked tokens accepted meanwhile stop working once it is over, even if mynance-auth-admin is still down
+func withinFailOpenWindow() bool {
+	outageMu.Lock()
+	defer outageMu.Unlock()
+	if outageStart.IsZero() {
+		outageStart = time.Now()
+	}
+	return time.Since(outageStart) <= failOpenMax
+}
+
+// authAdminAnswered ends the current outage
+func authAdminAnswered() {
+	outageMu.Lock()
+	outageStart = time.Time{}
+	outageMu.Unlock()
+}
+
+func verifyLocally(userToken string) (*Claims, error) {
+	tokenClaims := &claims.Claims{}
+	token, err := jwt.ParseWithClaims(userToken, tokenClaims, userKeys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	// Tokens de admin, de serviço ou emitidos para outro serviço são recusados
+	if err := tokenClaims.Expect(claims.TypeUser, claims.IssuerUser, audience); err != nil {
+		return nil, err
+	}
+
+	localClaims := &Claims{
+		UserID:    tokenClaims.UserID,
+		Email:     tokenClaims.Email,
+		Role:      tokenClaims.Role,
+		ExpiresAt: tokenClaims.ExpiresAt,
+		TokenType: claims.TypeUser,
+	}
+	if tokenClaims.ClientID != "" {
+		localClaims.ClientID = tokenClaims.ClientID
+		localClaims.Scopes = claims.ResourceScopes(tokenClaims.Scope)
+	}
+	return localClaims, nil
+}
+
+// impersonationClaims peeks at the claims of an impersonation token, or returns nil for any other token.
+// These tokens are signed by mynance-auth-admin, whose keys are not known here: nothing in them is verified yet.
+func impersonationClaims(userToken string) *claims.Claims {
+	tokenClaims := &claims.Claims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(userToken, tokenClaims); err != nil {
+		return nil
+	}
+	if tokenClaims.Type != claims.TypeImpersonation {
+		return nil
+	}
+	return tokenClaims
+}
+
+type invalidTokenError struct {
+	reason string
+}
+
+func (e *invalidTokenError) Error() string {
+	return "token is not valid: " + e.reason
+}
+
+// introspect asks mynance-auth-admin whether the token is still valid, through the circuit breaker.
+// It also returns the Cache-Control hint of the answer, or -1 when there is none.
+// The method and path of the request are sent along for the audit of impersonated requests.
+func introspect(ctx context.Context, userToken, method, path string) (*Claims, time.Duration, error) {
+	var tokenClaims *Claims
+	var invalid error
+	hint := time.Duration(-1)
+
+	err := breaker.Do(func() error {
+		// Mantém o trace, mas um cliente que desistiu da requisição não conta como falha do breaker
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), httpClient.Timeout)
+		defer cancel()
+
+		serviceToken, err := Tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, Tokens.AuthURL+"/auth/service/validate-token", nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+serviceToken)
+		req.Header.Set("X-User-Token", userToken)
+		req.Header.Set("X-Request-Method", method)
+		req.Header.Set("X-Request-Path", path)
+		if requestID := logger.RequestID(ctx); requestID != "" {
+			req.Header.Set(logger.RequestIDHeader, requestID)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("auth service returned status %d", resp.StatusCode)
+		}
+
+		var result struct {
+			Valid      *bool         `json:"valid"`
+			Error      string        `json:"error"`
+			UserID     int           `json:"userId"`
+			Email      string        `json:"email"`
+			Role       string        `json:"role"`
+			ExpiresAt  int64         `json:"expiresAt"`
+			TokenType  string        `json:"tokenType"`
+			Scopes     []string      `json:"scopes"`
+			ClientID   string        `json:"clientId"`
+			Actor      *claims.Actor `json:"actor"`
+			AllowWrite bool          `json:"allowWrite"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return err
+		}
+
+		// Sem o campo "valid" quem foi recusado é o token do serviço, não o do usuário
+		if result.Valid == nil {
+			Tokens.Invalidate()
+			return fmt.Errorf("service token rejected: %s", result.Error)
+		}
+
+		// Resposta definitiva do serviço: não conta como falha do breaker
+		if resp.StatusCode != http.StatusOK || !*result.Valid {
+			invalid = &invalidTokenError{reason: result.Error}
+			return nil
+		}
+
+		hint = cacheHint(resp.Header.Get("Cache-Control"))
+		tokenClaims = &Claims{
+			UserID:     result.UserID,
+			Email:      result.Email,
+			Role:       result.Role,
+			ExpiresAt:  result.ExpiresAt,
+			TokenType:  result.TokenType,
+			Scopes:     result.Scopes,
+			ClientID:   result.ClientID,
+			Actor:      result.Actor,
+			AllowWrite: result.AllowWrite,
+		}
+		// Serviços antigos do auth-admin não informam o tipo
+		if tokenClaims.TokenType == "" {
+			tokenClaims.TokenType = claims.TypeUser
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, -1, err
+	}
+	if invalid != nil {
+		return nil, -1, invalid
+	}
+	return tokenClaims, hint, nil
+}
+
+// cacheHint reads max-age from a Cache-Control header; no-store means 0 and a missing hint -1
+func cacheHint(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "no-store" || directive == "no-cache" {
+			return 0
+		}
+		if value, found := strings.CutPrefix(directive, "max-age="); found {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return -1
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return -1
+}
+
+func observeValidation(start time.Time, source, result string) {
+	prometheus.AuthValidationDuration.WithLabelValues(source, result).Observe(time.Since(start).Seconds())
+}
+
+func cacheKey(userToken string) string {
+	sum := sha256.Sum256([]byte(userToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func intFromEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
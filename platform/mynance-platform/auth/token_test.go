@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/jwks"
+)
+
+const testAudience = "banks"
+
+// fakeValidator plays mynance-auth (JWKS) and mynance-auth-admin (service login and validate-token)
+type fakeValidator struct {
+	*httptest.Server
+
+	key         *rsa.PrivateKey
+	validations atomic.Int32
+	// revoked makes validate-token refuse the user token, as after a logout
+	revoked atomic.Bool
+	// down makes validate-token answer 503
+	down atomic.Bool
+}
+
+// setupValidation points the package at a fake validator, as Init does with the environment
+func setupValidation(t *testing.T) *fakeValidator {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeValidator{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks.JSONWebKeySet{Keys: []jwks.JSONWebKey{{
+			Kty: "RSA",
+			Kid: "user-key",
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/auth/service/login", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"token": "service-token", "expiresAt": time.Now().Add(time.Hour).Unix()})
+	})
+	mux.HandleFunc("/auth/service/validate-token", func(w http.ResponseWriter, r *http.Request) {
+		f.validations.Add(1)
+		switch {
+		case f.down.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Header.Get("Authorization") != "Bearer service-token":
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid service token"})
+		case f.revoked.Load():
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"valid": false, "error": "session revoked"})
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			json.NewEncoder(w).Encode(map[string]any{"valid": true, "userId": 7, "email": "ana@example.com", "role": "user", "tokenType": claims.TypeUser})
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	Tokens = NewTokenManager("svc@mynance", "secret", f.URL)
+	audience = testAudience
+	userKeys = jwks.NewRemoteKeySet(f.URL + "/jwks")
+	cache = NewClaimsCache(100)
+	cacheTTL = 30 * time.Second
+	breaker = NewBreaker(5, time.Minute)
+	failOpen = false
+	failOpenMax = time.Minute
+	authAdminAnswered()
+	return f
+}
+
+// userToken signs a user token like mynance-auth, for the given audience
+func (f *fakeValidator) userToken(t *testing.T, aud string) string {
+	t.Helper()
+	return signUserToken(t, f.key, aud)
+}
+
+func signUserToken(t *testing.T, key *rsa.PrivateKey, aud string) string {
+	t.Helper()
+	return signToken(t, key, claims.TypeUser, claims.IssuerUser, aud)
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, tokenType, issuer, aud string) string {
+	t.Helper()
+
+	tokenClaims, err := claims.New(tokenType, issuer, []string{aud}, 7, "ana@example.com", "user", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = "user-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestValidateUserTokenCachesTheAnswer(t *testing.T) {
+	f := setupValidation(t)
+	token := f.userToken(t, testAudience)
+
+	for range 3 {
+		got, err := ValidateUserToken(context.Background(), token, http.MethodGet, "/banks")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != 7 || got.TokenType != claims.TypeUser {
+			t.Fatalf("unexpected claims %+v", got)
+		}
+	}
+	if n := f.validations.Load(); n != 1 {
+		t.Fatalf("validate-token called %d times, want 1", n)
+	}
+}
+
+func TestValidateUserTokenRejectsRevokedToken(t *testing.T) {
+	f := setupValidation(t)
+	f.revoked.Store(true)
+
+	_, err := ValidateUserToken(context.Background(), f.userToken(t, testAudience), http.MethodGet, "/banks")
+	var invalid *invalidTokenError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("err = %v, want an invalid token error", err)
+	}
+}
+
+func TestValidateUserTokenRejectsLocally(t *testing.T) {
+	f := setupValidation(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"another audience", f.userToken(t, "incomes")},
+		{"garbage", "not-a-jwt"},
+		{"another signing key", signUserToken(t, otherKey, testAudience)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateUserToken(context.Background(), tt.token, http.MethodGet, "/banks"); err == nil {
+				t.Fatal("token accepted")
+			}
+		})
+	}
+	// Recusados sem consultar o mynance-auth-admin
+	if n := f.validations.Load(); n != 0 {
+		t.Fatalf("validate-token called %d times, want 0", n)
+	}
+}
+
+func TestValidateUserTokenChecksTheAudienceOfImpersonationTokens(t *testing.T) {
+	f := setupValidation(t)
+	// Assinado pelo mynance-auth-admin, cuja chave este serviço não conhece
+	adminKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateUserToken(context.Background(), signToken(t, adminKey, claims.TypeImpersonation, claims.IssuerAdmin, "customer"), http.MethodGet, "/banks"); err == nil {
+		t.Fatal("impersonation token of another service accepted")
+	}
+	if n := f.validations.Load(); n != 0 {
+		t.Fatalf("validate-token called %d times for a token of another service, want 0", n)
+	}
+
+	if _, err := ValidateUserToken(context.Background(), signToken(t, adminKey, claims.TypeImpersonation, claims.IssuerAdmin, testAudience), http.MethodGet, "/banks"); err != nil {
+		t.Fatalf("impersonation token of this service rejected: %v", err)
+	}
+	if n := f.validations.Load(); n != 1 {
+		t.Fatalf("validate-token called %d times, want 1", n)
+	}
+}
+
+func TestValidateUserTokenWhileAuthAdminIsDown(t *testing.T) {
+	f := setupValidation(t)
+	f.down.Store(true)
+	token := f.userToken(t, testAudience)
+
+	if _, err := ValidateUserToken(context.Background(), token, http.MethodGet, "/banks"); err == nil {
+		t.Fatal("token accepted without mynance-auth-admin and AUTH_FAIL_OPEN off")
+	}
+
+	failOpen = true
+	got, err := ValidateUserToken(context.Background(), token, http.MethodGet, "/banks")
+	if err != nil || got.UserID != 7 {
+		t.Fatalf("fail open: claims %+v, err %v", got, err)
+	}
+
+	// Passado AUTH_FAIL_OPEN_MAX da queda, a assinatura sozinha deixa de bastar
+	outageMu.Lock()
+	outageStart = time.Now().Add(-2 * failOpenMax)
+	outageMu.Unlock()
+	if _, err := ValidateUserToken(context.Background(), token, http.MethodGet, "/banks"); err == nil {
+		t.Fatal("token accepted after the fail open window")
+	}
+}
+
+func TestBreakerOpensAfterThresholdAndRecovers(t *testing.T) {
+	b := NewBreaker(2, 50*time.Millisecond)
+	failure := errors.New("down")
+	calls := 0
+	fail := func() error { calls++; return failure }
+
+	b.Do(fail)
+	b.Do(fail)
+	if err := b.Do(fail); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Fatalf("err = %v after %d calls, want the circuit open after 2", err, calls)
+	}
+
+	// Depois do cooldown passa uma chamada de teste; se falhar, abre de novo
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Do(fail); !errors.Is(err, failure) {
+		t.Fatalf("trial call err = %v", err)
+	}
+	if err := b.Do(fail); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v after a failed trial, want the circuit open", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatalf("err = %v after a successful trial, want the circuit closed", err)
+	}
+}
+
+func TestClaimsCache(t *testing.T) {
+	c := NewClaimsCache(2)
+	c.Set("a", &Claims{UserID: 1}, time.Minute)
+	c.Set("b", &Claims{UserID: 2}, time.Minute)
+	c.Get("a")
+	c.Set("c", &Claims{UserID: 3}, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	if got, ok := c.Get("a"); !ok || got.UserID != 1 {
+		t.Fatal("recently used entry was evicted")
+	}
+
+	c.Set("short", &Claims{UserID: 4}, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("expired entry returned")
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("deleted entry returned")
+	}
+}
+
+func TestCacheHint(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", -1},
+		{"max-age=30", 30 * time.Second},
+		{"private, max-age=10", 10 * time.Second},
+		{"no-store", 0},
+		{"no-cache, max-age=30", 0},
+		{"max-age=-5", -1},
+		{"max-age=abc", -1},
+	}
+
+	for _, tt := range tests {
+		if got := cacheHint(tt.header); got != tt.want {
+			t.Errorf("cacheHint(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package claims
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is bumped whenever the payload layout changes; tokens with another version are rejected
+const Version = 1
+
+// Token types carried in the typ claim
+const (
+	TypeUser    = "user"
+	TypeAdmin   = "admin"
+	TypeService = "service"
+
+	// TypeEmailVerification marks the signed links sent to confirm an email address
+	TypeEmailVerification = "email_verification"
+	// TypeMFAChallenge proves the password step of a login that still needs the second factor
+	TypeMFAChallenge = "mfa_challenge"
+	// TypeImpersonation is minted by mynance-auth-admin for support staff to see the app as a user; the admin is in "act"
+	TypeImpersonation = "impersonation"
+)
+
+// Issuers and audiences of the Mynance services
+const (
+	IssuerUser  = "mynance-auth"
+	IssuerAdmin = "mynance-auth-admin"
+
+	AudienceAuth      = "mynance-auth"
+	AudienceAuthAdmin = "mynance-auth-admin"
+)
+
+// DefaultUserAudience lists every service that accepts end-user tokens
+var DefaultUserAudience = []string{
+	AudienceAuth,
+	AudienceAuthAdmin,
+	"mynance-banks",
+	"mynance-categories",
+	"mynance-creditcards",
+	"mynance-creditcards-expenses",
+	"mynance-customer",
+	"mynance-expenses",
+	"mynance-incomes",
+}
+
+// Audience accepts both the single string and the array form of the aud claim
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether the audience includes the given service
+func (a Audience) Contains(service string) bool {
+	for _, aud := range a {
+		if aud == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims is the payload shared by every token issued by mynance-auth and mynance-auth-admin
+type Claims struct {
+	Version   int      `json:"ver"`
+	Type      string   `json:"typ"`
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ID        string   `json:"jti"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+
+	UserID             int    `json:"userId"`
+	Email              string `json:"email"`
+	Role               string `json:"role"`
+	SessionID          string `json:"sid,omitempty"`
+	LastPasswordChange int64  `json:"lastPasswordChange"`
+
+	// Actor and AllowWrite are only set on impersonation tokens, which are read-only unless AllowWrite
+	Actor      *Actor `json:"act,omitempty"`
+	AllowWrite bool   `json:"allowWrite,omitempty"`
+
+	// ClientID and Scope are only set on tokens issued to OAuth clients, which reach just the resource scopes in Scope
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// Actor is the RFC 8693 "act" claim: the admin really behind an impersonation token
+type Actor struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// New fills the registered claims for a token of the given type
+func New(tokenType, issuer string, audience []string, userID int, email, role string, ttl time.Duration) (*Claims, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Claims{
+		Version:   Version,
+		Type:      tokenType,
+		Issuer:    issuer,
+		Subject:   strconv.Itoa(userID),
+		Audience:  audience,
+		ID:        id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+	}, nil
+}
+
+// Valid implements jwt.Claims
+func (c *Claims) Valid() error {
+	now := time.Now().Unix()
+
+	if c.Version != Version {
+		return fmt.Errorf("unsupported claims version %d", c.Version)
+	}
+	if c.ExpiresAt == 0 || now > c.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	// Pequena tolerância para relógios levemente adiantados entre os serviços
+	if c.IssuedAt > now+60 {
+		return errors.New("token used before issued")
+	}
+	if c.Subject != strconv.Itoa(c.UserID) {
+		return errors.New("subject does not match user")
+	}
+	return nil
+}
+
+// Expect checks that the token has the given type, issuer and was issued for the audience
+func (c *Claims) Expect(tokenType, issuer, audience string) error {
+	if c.Type != tokenType {
+		return fmt.Errorf("expected a %s token, got '%s'", tokenType, c.Type)
+	}
+	if c.Issuer != issuer {
+		return fmt.Errorf("unexpected issuer '%s'", c.Issuer)
+	}
+	if !c.Audience.Contains(audience) {
+		return fmt.Errorf("token is not meant for %s", audience)
+	}
+	return nil
+}
+
+// UserAudience returns the audience of end-user tokens, overridable with JWT_USER_AUDIENCE
+func UserAudience() []string {
+	value := os.Getenv("JWT_USER_AUDIENCE")
+	if value == "" {
+		return DefaultUserAudience
+	}
+	return strings.Split(value, ",")
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
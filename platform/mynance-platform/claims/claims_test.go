@@ -0,0 +1,192 @@
+package claims
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAudienceAcceptsStringAndArray(t *testing.T) {
+	tests := []struct {
+		json string
+		want Audience
+	}{
+		{`"mynance-banks"`, Audience{"mynance-banks"}},
+		{`["mynance-banks","mynance-auth"]`, Audience{"mynance-banks", "mynance-auth"}},
+	}
+
+	for _, tt := range tests {
+		var got Audience
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Fatalf("%s: %v", tt.json, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.json, got, tt.want)
+		}
+	}
+
+	var got Audience
+	if err := json.Unmarshal([]byte(`42`), &got); err == nil {
+		t.Error("numeric aud accepted")
+	}
+}
+
+func TestClaimsSurviveTheJSONRoundTrip(t *testing.T) {
+	issued, err := New(TypeUser, IssuerUser, []string{"mynance-banks"}, 7, "ana@example.com", "user", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued.ClientID = "budget-app"
+	issued.Scope = "openid banks:read"
+
+	data, err := json.Marshal(issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed Claims
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := parsed.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Expect(TypeUser, IssuerUser, "mynance-banks"); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID == "" || parsed.ID != issued.ID || parsed.Subject != "7" || parsed.ClientID != "budget-app" || parsed.Scope != issued.Scope {
+		t.Fatalf("parsed %+v, issued %+v", parsed, issued)
+	}
+}
+
+func TestValid(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name string
+		edit func(c *Claims)
+		ok   bool
+	}{
+		{"fresh", func(c *Claims) {}, true},
+		{"another version", func(c *Claims) { c.Version = Version + 1 }, false},
+		{"expired", func(c *Claims) { c.ExpiresAt = now - 1 }, false},
+		{"no expiry", func(c *Claims) { c.ExpiresAt = 0 }, false},
+		{"issued slightly ahead", func(c *Claims) { c.IssuedAt = now + 30 }, true},
+		{"issued in the future", func(c *Claims) { c.IssuedAt = now + 120 }, false},
+		{"subject of another user", func(c *Claims) { c.Subject = "8" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(TypeUser, IssuerUser, []string{"mynance-banks"}, 7, "ana@example.com", "user", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(c)
+			if err := c.Valid(); (err == nil) != tt.ok {
+				t.Fatalf("Valid() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExpect(t *testing.T) {
+	c, err := New(TypeUser, IssuerUser, []string{"mynance-banks", "mynance-auth"}, 7, "ana@example.com", "user", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                        string
+		tokenType, issuer, audience string
+		ok                          bool
+	}{
+		{"expected", TypeUser, IssuerUser, "mynance-auth", true},
+		{"admin token expected", TypeAdmin, IssuerUser, "mynance-auth", false},
+		{"issued by the admin service", TypeUser, IssuerAdmin, "mynance-auth", false},
+		{"meant for another service", TypeUser, IssuerUser, "mynance-incomes", false},
+	}
+
+	for _, tt := range tests {
+		if err := c.Expect(tt.tokenType, tt.issuer, tt.audience); (err == nil) != tt.ok {
+			t.Errorf("%s: Expect() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestUserAudience(t *testing.T) {
+	t.Setenv("JWT_USER_AUDIENCE", "")
+	if got := UserAudience(); !slices.Equal(got, DefaultUserAudience) {
+		t.Fatalf("UserAudience() = %v, want the default", got)
+	}
+
+	t.Setenv("JWT_USER_AUDIENCE", "mynance-auth,mynance-banks")
+	if got := UserAudience(); !slices.Equal(got, []string{"mynance-auth", "mynance-banks"}) {
+		t.Fatalf("UserAudience() = %v, want the configured services", got)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		resource string
+		action   string
+		want     bool
+	}{
+		{[]string{"banks:read"}, "banks", ScopeRead, true},
+		{[]string{"banks:read"}, "banks", ScopeWrite, false},
+		// write também concede read
+		{[]string{"banks:write"}, "banks", ScopeRead, true},
+		{[]string{"banks:write"}, "banks", ScopeWrite, true},
+		{[]string{"customer:write"}, "banks", ScopeRead, false},
+		{nil, "banks", ScopeRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasScope(tt.scopes, tt.resource, tt.action); got != tt.want {
+			t.Errorf("HasScope(%v, %s, %s) = %v, want %v", tt.scopes, tt.resource, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestScopeValidation(t *testing.T) {
+	tests := []struct {
+		scope       string
+		resource    bool
+		clientScope bool
+	}{
+		{"banks:read", true, true},
+		{"creditcards-expenses:write", true, true},
+		{"incomes:read", true, true},
+		{"banks:delete", false, false},
+		{"unknown:read", false, false},
+		{"banks", false, false},
+		{ScopeOpenID, false, true},
+		{ScopeOfflineAccess, false, true},
+	}
+
+	for _, tt := range tests {
+		if got := ValidScope(tt.scope); got != tt.resource {
+			t.Errorf("ValidScope(%q) = %v, want %v", tt.scope, got, tt.resource)
+		}
+		if got := ValidClientScope(tt.scope); got != tt.clientScope {
+			t.Errorf("ValidClientScope(%q) = %v, want %v", tt.scope, got, tt.clientScope)
+		}
+	}
+}
+
+func TestResourceScopes(t *testing.T) {
+	got := ResourceScopes("openid  banks:read email expenses:write unknown:read offline_access")
+	if want := []string{"banks:read", "expenses:write"}; !slices.Equal(got, want) {
+		t.Fatalf("ResourceScopes() = %v, want %v", got, want)
+	}
+}
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	if !IsPersonalAccessToken(PersonalAccessTokenPrefix + "abc") {
+		t.Error("personal access token not recognized")
+	}
+	if IsPersonalAccessToken("eyJhbGciOiJSUzI1NiJ9.e30.sig") {
+		t.Error("JWT taken for a personal access token")
+	}
+}
@@ -0,0 +1,85 @@
+package claims
+
+import (
+	"slices"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so services can tell them apart from JWTs
+const PersonalAccessTokenPrefix = "mnp_"
+
+// TypePersonalAccessToken is the tokenType returned by introspection for personal access tokens
+const TypePersonalAccessToken = "personal_access_token"
+
+// Scope actions; write also grants read
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// ScopeResources are the APIs a personal access token can be scoped to, one per service
+var ScopeResources = []string{
+	"banks",
+	"categories",
+	"creditcards",
+	"creditcards-expenses",
+	"customer",
+	"expenses",
+	"incomes",
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// ValidScope reports whether scope is resource:read or resource:write for a known resource
+func ValidScope(scope string) bool {
+	resource, action, found := strings.Cut(scope, ":")
+	if !found || (action != ScopeRead && action != ScopeWrite) {
+		return false
+	}
+	for _, known := range ScopeResources {
+		if resource == known {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scopes grant action on resource
+func HasScope(scopes []string, resource, action string) bool {
+	for _, scope := range scopes {
+		if scope == resource+":"+action || (action == ScopeRead && scope == resource+":"+ScopeWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenID Connect scopes an OAuth client can be granted besides the resource scopes
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// OpenIDScopes lists the OpenID Connect scopes supported by mynance-auth
+var OpenIDScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// ValidClientScope reports whether an OAuth client may be granted scope
+func ValidClientScope(scope string) bool {
+	return slices.Contains(OpenIDScopes, scope) || ValidScope(scope)
+}
+
+// ResourceScopes returns the resource scopes of a space separated scope claim, dropping the OpenID Connect ones
+func ResourceScopes(scope string) []string {
+	var resources []string
+	for _, value := range strings.Fields(scope) {
+		if ValidScope(value) {
+			resources = append(resources, value)
+		}
+	}
+	return resources
+}
@@ -1,19 +1,19 @@
 package config
 
 import (
-	"log"
 	"os"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/jvlerner/mynance-platform/logger"
 )
 
 func LoadEnv() {
 	if err := godotenv.Load(); err != nil {
-		log.Println("[INFO] Using system environment variables")
+		logger.Log.Info("Using system environment variables")
 		return
 	}
-	log.Println("[INFO] Using .env variables")
+	logger.Log.Info("Using .env variables")
 }
 
 // GetCORS returns a slice of allowed CORS origins
@@ -0,0 +1,244 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher is one password hashing algorithm
+type Hasher interface {
+	// ID is the algorithm identifier written in the hash, e.g. "argon2id"
+	ID() string
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether a hash of this algorithm was made with other parameters than the current ones
+	NeedsRehash(encoded string) bool
+}
+
+// ErrUnknownAlgorithm is returned for hashes of an algorithm with no registered Hasher
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+var (
+	// Default hashes new passwords
+	Default Hasher = NewArgon2id(DefaultArgon2Params)
+
+	hashers = map[string]Hasher{}
+)
+
+func init() {
+	Register(Default)
+	Register(NewBcrypt(bcrypt.DefaultCost))
+}
+
+// Register makes a Hasher available to Verify
+func Register(h Hasher) {
+	hashers[h.ID()] = h
+}
+
+// Init selects the default algorithm from PASSWORD_HASHER (argon2id or bcrypt)
+// and reads the optional ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and ARGON2_PARALLELISM overrides
+func Init() {
+	params := DefaultArgon2Params
+	for env, target := range map[string]*uint32{
+		"ARGON2_MEMORY_KIB": &params.Memory,
+		"ARGON2_ITERATIONS": &params.Iterations,
+	} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 32)
+			if err != nil || parsed == 0 {
+				logger.Log.Fatal("Invalid password hasher variable", zap.String("name", env), zap.String("value", value))
+			}
+			*target = uint32(parsed)
+		}
+	}
+	if value := os.Getenv("ARGON2_PARALLELISM"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 8)
+		if err != nil || parsed == 0 {
+			logger.Log.Fatal("Invalid password hasher variable", zap.String("name", "ARGON2_PARALLELISM"), zap.String("value", value))
+		}
+		params.Parallelism = uint8(parsed)
+	}
+
+	switch algorithm := os.Getenv("PASSWORD_HASHER"); algorithm {
+	case "", "argon2id":
+		Default = NewArgon2id(params)
+	case "bcrypt":
+		Default = NewBcrypt(bcrypt.DefaultCost)
+	default:
+		logger.Log.Fatal("Unknown PASSWORD_HASHER", zap.String("value", algorithm))
+	}
+	Register(Default)
+}
+
+// Hash hashes a password with the default algorithm
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Verify checks a password against a hash of any registered algorithm.
+// When it matches, rehash tells whether the hash should be replaced by one from Default.
+func Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	h, ok := hashers[algorithm(encoded)]
+	if !ok {
+		return false, false, ErrUnknownAlgorithm
+	}
+
+	ok, err = h.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	return true, h.ID() != Default.ID() || Default.NeedsRehash(encoded), nil
+}
+
+// algorithm returns the identifier of a hash in PHC format ($id$...); bcrypt's $2a$, $2b$ and $2y$ map to "bcrypt"
+func algorithm(encoded string) string {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+	switch parts[1] {
+	case "2a", "2b", "2y":
+		return "bcrypt"
+	}
+	return parts[1]
+}
+
+// Argon2Params are the argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the second recommended option of RFC 9106 (64 MiB, 3 passes, 4 lanes)
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type argon2idHasher struct {
+	params Argon2Params
+}
+
+// NewArgon2id returns a Hasher producing $argon2id$v=19$m=...,t=...,p=...$salt$hash strings
+func NewArgon2id(params Argon2Params) Hasher {
+	return &argon2idHasher{params: params}
+}
+
+func (h *argon2idHasher) ID() string {
+	return "argon2id"
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		params.KeyLength != h.params.KeyLength ||
+		uint32(len(salt)) != h.params.SaltLength
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errors.New("invalid argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt returns a Hasher for the legacy bcrypt hashes ($2a$, $2b$, $2y$)
+func NewBcrypt(cost int) Hasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) ID() string {
+	return "bcrypt"
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
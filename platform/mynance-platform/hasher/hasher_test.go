@@ -0,0 +1,197 @@
+package hasher
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keeps argon2id cheap: the tests check the format, not the cost
+var testParams = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestMain(m *testing.M) {
+	Default = NewArgon2id(testParams)
+	Register(Default)
+	os.Exit(m.Run())
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := NewArgon2id(testParams)
+
+	encoded, err := h.Hash("Corr3ct-Horse!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("hash = %q, want the PHC format with the parameters", encoded)
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != testParams || len(salt) != 16 || len(key) != 32 {
+		t.Fatalf("decoded %+v with %d bytes of salt and %d of key", params, len(salt), len(key))
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"same password", "Corr3ct-Horse!", true},
+		{"other password", "corr3ct-horse!", false},
+		{"empty password", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := h.Verify(tt.password, encoded)
+			if err != nil || ok != tt.want {
+				t.Fatalf("Verify = %v, %v; want %v", ok, err, tt.want)
+			}
+		})
+	}
+
+	// Dois hashes da mesma senha usam sais diferentes
+	again, err := h.Hash("Corr3ct-Horse!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encoded {
+		t.Fatal("the same salt was used twice")
+	}
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	valid, err := NewArgon2id(testParams).Hash("Corr3ct-Horse!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+	replace := func(index int, value string) string {
+		changed := append([]string(nil), parts...)
+		changed[index] = value
+		return strings.Join(changed, "$")
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"missing hash", strings.Join(parts[:5], "$")},
+		{"extra field", valid + "$extra"},
+		{"other algorithm", replace(1, "argon2i")},
+		{"other version", replace(2, "v=16")},
+		{"no version", replace(2, "19")},
+		{"missing parameter", replace(3, "m=64,t=1")},
+		{"parameter not a number", replace(3, "m=abc,t=1,p=1")},
+		{"salt not base64", replace(4, "not base64!")},
+		{"hash not base64", replace(5, "not base64!")},
+		{"empty hash", replace(5, "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2id(tt.encoded); err == nil {
+				t.Fatalf("decodeArgon2id(%q) accepted", tt.encoded)
+			}
+			if ok, err := Default.Verify("Corr3ct-Horse!", tt.encoded); ok || err == nil {
+				t.Fatalf("Verify = %v, %v; want an error", ok, err)
+			}
+			if !Default.NeedsRehash(tt.encoded) {
+				t.Fatal("a malformed hash does not need a rehash")
+			}
+		})
+	}
+}
+
+func TestVerifyLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Corr3ct-Horse!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		encoded    string
+		wantOK     bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{"right password", "Corr3ct-Horse!", string(legacy), true, true, nil},
+		{"wrong password", "Wrong-Horse!", string(legacy), false, false, nil},
+		{"$2y$ prefix", "Corr3ct-Horse!", "$2y$" + strings.TrimPrefix(string(legacy), "$2a$"), true, true, nil},
+		{"unknown algorithm", "Corr3ct-Horse!", "$scrypt$ln=15,r=8,p=1$c2FsdA$aGFzaA", false, false, ErrUnknownAlgorithm},
+		{"plain text", "Corr3ct-Horse!", "Corr3ct-Horse!", false, false, ErrUnknownAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := Verify(tt.password, tt.encoded)
+			if ok != tt.wantOK || rehash != tt.wantRehash || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, %v, %v; want %v, %v, %v", ok, rehash, err, tt.wantOK, tt.wantRehash, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current, err := Hash("Corr3ct-Horse!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashWith := func(change func(*Argon2Params)) string {
+		params := testParams
+		change(&params)
+		encoded, err := NewArgon2id(params).Hash("Corr3ct-Horse!")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	bcryptWith := func(cost int) string {
+		encoded, err := bcrypt.GenerateFromPassword([]byte("Corr3ct-Horse!"), cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(encoded)
+	}
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{"current parameters", Default, current, false},
+		{"other memory", Default, hashWith(func(p *Argon2Params) { p.Memory = 128 }), true},
+		{"other time", Default, hashWith(func(p *Argon2Params) { p.Iterations = 2 }), true},
+		{"other threads", Default, hashWith(func(p *Argon2Params) { p.Parallelism = 2 }), true},
+		{"other key length", Default, hashWith(func(p *Argon2Params) { p.KeyLength = 16 }), true},
+		{"other salt length", Default, hashWith(func(p *Argon2Params) { p.SaltLength = 8 }), true},
+		{"bcrypt of the current cost", NewBcrypt(bcrypt.MinCost), bcryptWith(bcrypt.MinCost), false},
+		{"bcrypt of another cost", NewBcrypt(bcrypt.MinCost + 1), bcryptWith(bcrypt.MinCost), true},
+		{"bcrypt malformed", NewBcrypt(bcrypt.MinCost), "$2a$xx$invalid", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+				t.Fatalf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Senha certa com outros parâmetros: Verify pede a troca pelo hash atual
+	if ok, rehash, err := Verify("Corr3ct-Horse!", hashWith(func(p *Argon2Params) { p.Memory = 128 })); !ok || !rehash || err != nil {
+		t.Fatalf("Verify = %v, %v, %v; want a match to rehash", ok, rehash, err)
+	}
+	if ok, rehash, err := Verify("Corr3ct-Horse!", current); !ok || rehash || err != nil {
+		t.Fatalf("Verify = %v, %v, %v; want a match without rehash", ok, rehash, err)
+	}
+}
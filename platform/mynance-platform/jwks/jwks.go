@@ -0,0 +1,353 @@
+package jwks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const keySize = 2048
+
+// keyIDFormat names the generated keys after their creation time, so the newest kid is the active key
+const keyIDFormat = "20060102T150405Z"
+
+// Key is an RSA signing key identified by its kid
+type Key struct {
+	ID        string
+	Private   *rsa.PrivateKey
+	CreatedAt time.Time
+	RetiredAt time.Time
+}
+
+// KeySet holds the active signing key and the retired keys still accepted during the overlap window
+type KeySet struct {
+	mu       sync.RWMutex
+	dir      string
+	activeID string
+	keys     map[string]*Key
+	overlap  time.Duration
+	rotation time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// JSONWebKey is the public part of a key as published in /.well-known/jwks.json
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JSONWebKeySet is the document served by the JWKS endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var Keys *KeySet
+
+// Init loads the signing keys from JWT_KEYS_DIR and starts watching it for rotations
+func Init() {
+	Keys = &KeySet{
+		dir:      os.Getenv("JWT_KEYS_DIR"),
+		keys:     make(map[string]*Key),
+		overlap:  durationFromEnv("JWT_KEY_OVERLAP", 24*time.Hour),
+		rotation: durationFromEnv("JWT_KEY_ROTATION_INTERVAL", 0),
+		stop:     make(chan struct{}),
+	}
+
+	if Keys.dir == "" {
+		// Sem diretório as chaves não sobrevivem a um restart: serve apenas para desenvolvimento
+		logger.Log.Warn("JWT_KEYS_DIR not set, using an ephemeral signing key")
+		key, err := generateKey()
+		if err != nil {
+			logger.Log.Fatal("Failed to generate signing key", zap.Error(err))
+		}
+		Keys.keys[key.ID] = key
+		Keys.activeID = key.ID
+		return
+	}
+
+	if err := Keys.Reload(); err != nil {
+		logger.Log.Fatal("Failed to load signing keys", zap.Error(err))
+	}
+	Keys.startReloader(durationFromEnv("JWT_KEYS_RELOAD_INTERVAL", time.Minute))
+}
+
+// Close stops watching the key directory
+func Close() {
+	if Keys != nil {
+		Keys.stopOnce.Do(func() {
+			close(Keys.stop)
+		})
+	}
+}
+
+// Reload reads the key directory; the newest kid becomes the active signing key. A replaced key was retired
+// when the next one was created: it stays valid for verification until the overlap has passed since then,
+// and its file is deleted afterwards.
+func (k *KeySet) Reload() error {
+	loaded, err := readKeys(k.dir)
+	if err != nil {
+		return err
+	}
+
+	if len(loaded) == 0 {
+		key, err := generateKey()
+		if err != nil {
+			return err
+		}
+		if err := writeKey(k.dir, key); err != nil {
+			return err
+		}
+		logger.Log.Info("Generated first signing key", zap.String("kid", key.ID))
+		loaded[key.ID] = key
+	}
+
+	ids := make([]string, 0, len(loaded))
+	for id := range loaded {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	activeID := ids[len(ids)-1]
+
+	// A aposentadoria vem da chave seguinte, não do relógio: reiniciar o serviço não estende o overlap
+	now := time.Now()
+	for i, id := range ids[:len(ids)-1] {
+		key := loaded[id]
+		key.RetiredAt = loaded[ids[i+1]].CreatedAt
+		if now.Sub(key.RetiredAt) < k.overlap {
+			continue
+		}
+
+		// Nenhum token assinado por ela é aceito mais; outra réplica pode já ter apagado o arquivo
+		if err := os.Remove(filepath.Join(k.dir, id+".pem")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logger.Log.Warn("Failed to delete expired signing key", zap.String("kid", id), zap.Error(err))
+		} else if err == nil {
+			logger.Log.Info("Deleted expired signing key", zap.String("kid", id))
+		}
+		delete(loaded, id)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.activeID != "" && k.activeID != activeID {
+		logger.Log.Info("Rotated signing key", zap.String("from", k.activeID), zap.String("to", activeID))
+	}
+	k.keys = loaded
+	k.activeID = activeID
+	return nil
+}
+
+// Rotate generates a new signing key in the key directory and makes it active
+func (k *KeySet) Rotate() error {
+	if k.dir == "" {
+		return errors.New("key rotation requires JWT_KEYS_DIR")
+	}
+	key, err := generateKey()
+	if err != nil {
+		return err
+	}
+	if err := writeKey(k.dir, key); err != nil {
+		return err
+	}
+	return k.Reload()
+}
+
+// startReloader picks up keys added to the directory and rotates on schedule until Close
+func (k *KeySet) startReloader(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-k.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if k.rotationDue() {
+				if err := k.Rotate(); err != nil {
+					logger.Log.Error("Failed to rotate signing key", zap.Error(err))
+				}
+				continue
+			}
+			if err := k.Reload(); err != nil {
+				logger.Log.Error("Failed to reload signing keys", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (k *KeySet) rotationDue() bool {
+	if k.rotation <= 0 {
+		return false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return time.Since(k.keys[k.activeID].CreatedAt) > k.rotation
+}
+
+// Sign signs the claims with the active key and sets its kid in the header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	key := k.keys[k.activeID]
+	k.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc resolves the verification key of a token by its kid
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[kid]
+	if !ok || !k.usable(key) {
+		return nil, fmt.Errorf("unknown signing key '%s'", kid)
+	}
+	return &key.Private.PublicKey, nil
+}
+
+// JWKS returns the public keys that can currently verify tokens
+func (k *KeySet) JWKS() JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range k.keys {
+		if !k.usable(key) {
+			continue
+		}
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: key.ID,
+			N:   base64.RawURLEncoding.EncodeToString(key.Private.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.Private.PublicKey.E)).Bytes()),
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid > set.Keys[j].Kid })
+	return set
+}
+
+func (k *KeySet) usable(key *Key) bool {
+	return key.RetiredAt.IsZero() || time.Since(key.RetiredAt) < k.overlap
+}
+
+// Handler serves the JWKS document
+func Handler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, Keys.JWKS())
+}
+
+func generateKey() (*Key, error) {
+	private, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Key{
+		ID:        now.UTC().Format(keyIDFormat),
+		Private:   private,
+		CreatedAt: now,
+	}, nil
+}
+
+func readKeys(dir string) (map[string]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*Key)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		// A data do kid vale mais que a do arquivo, que muda quando o diretório é copiado ou montado de um secret
+		id := strings.TrimSuffix(entry.Name(), ".pem")
+		createdAt, err := time.Parse(keyIDFormat, id)
+		if err != nil {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			createdAt = info.ModTime()
+		}
+		keys[id] = &Key{ID: id, Private: private, CreatedAt: createdAt}
+	}
+	return keys, nil
+}
+
+func writeKey(dir string, key *Key) error {
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key.Private),
+	})
+
+	file, err := os.OpenFile(filepath.Join(dir, key.ID+".pem"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Log.Error("Invalid duration, using the default", zap.String("name", name), zap.String("value", value), zap.Duration("default", fallback))
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,166 @@
+package jwks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func newTestKeySet(dir string, overlap time.Duration) *KeySet {
+	return &KeySet{dir: dir, keys: make(map[string]*Key), overlap: overlap, stop: make(chan struct{})}
+}
+
+// writeKeyCreatedAt writes a key named after createdAt, as Rotate would have done back then
+func writeKeyCreatedAt(t *testing.T, dir string, createdAt time.Time) string {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &Key{ID: createdAt.UTC().Format(keyIDFormat), Private: private, CreatedAt: createdAt}
+	if err := writeKey(dir, key); err != nil {
+		t.Fatal(err)
+	}
+	return key.ID
+}
+
+func kids(set JSONWebKeySet) []string {
+	var ids []string
+	for _, key := range set.Keys {
+		ids = append(ids, key.Kid)
+	}
+	return ids
+}
+
+func TestReloadRetiresKeysWhenTheNextOneWasCreated(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	expired := writeKeyCreatedAt(t, dir, now.Add(-5*time.Hour))
+	replaced := writeKeyCreatedAt(t, dir, now.Add(-2*time.Hour))
+	active := writeKeyCreatedAt(t, dir, now.Add(-time.Hour))
+
+	keys := newTestKeySet(dir, 90*time.Minute)
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A substituída há 1h continua publicada; a substituída há 2h passou do overlap e o arquivo sumiu
+	if got := kids(keys.JWKS()); len(got) != 2 || got[0] != active || got[1] != replaced {
+		t.Fatalf("JWKS kids = %v, want [%s %s]", got, active, replaced)
+	}
+	if _, err := os.Stat(filepath.Join(dir, expired+".pem")); !os.IsNotExist(err) {
+		t.Fatalf("expired key file still there: %v", err)
+	}
+	if retiredAt := keys.keys[replaced].RetiredAt; !retiredAt.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("replaced key retired at %v, want when %s was created", retiredAt, active)
+	}
+
+	// Um restart não renova o overlap das chaves já substituídas
+	restarted := newTestKeySet(dir, 90*time.Minute)
+	if err := restarted.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if retiredAt := restarted.keys[replaced].RetiredAt; !retiredAt.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("after a restart the replaced key retired at %v, want %v", retiredAt, now.Add(-time.Hour))
+	}
+}
+
+func TestReloadGeneratesTheFirstKey(t *testing.T) {
+	dir := t.TempDir()
+	keys := newTestKeySet(dir, time.Hour)
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if len(files) != 1 || len(keys.JWKS().Keys) != 1 {
+		t.Fatalf("files %v, JWKS %v; want one key", files, keys.JWKS())
+	}
+}
+
+func TestRotateKeepsVerifyingTokensOfTheReplacedKey(t *testing.T) {
+	dir := t.TempDir()
+	previous := writeKeyCreatedAt(t, dir, time.Now().Add(-time.Hour))
+	keys := newTestKeySet(dir, time.Hour)
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := keys.Sign(jwt.StandardClaims{Subject: "7", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys.activeID == previous {
+		t.Fatal("Rotate did not activate a new key")
+	}
+	if _, err := jwt.Parse(signed, keys.Keyfunc); err != nil {
+		t.Fatalf("token of the replaced key rejected within the overlap: %v", err)
+	}
+
+	// Passado o overlap, o token da chave antiga não é mais aceito
+	keys.overlap = 0
+	if err := keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, keys.Keyfunc); err == nil {
+		t.Fatal("token of the replaced key accepted after the overlap")
+	}
+}
+
+func TestRemoteKeySetFetchesRotatedKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyCreatedAt(t, dir, time.Now().Add(-time.Hour))
+	Keys = newTestKeySet(dir, time.Hour)
+	if err := Keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/.well-known/jwks.json", Handler)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	remote := NewRemoteKeySet(server.URL + "/.well-known/jwks.json")
+	if err := remote.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chave criada depois do último fetch: o kid desconhecido faz buscar o JWKS de novo
+	if err := Keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	remote.fetchedAt = time.Time{}
+	signed, err := Keys.Sign(jwt.StandardClaims{Subject: "7", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, remote.Keyfunc); err != nil {
+		t.Fatalf("token of the rotated key rejected: %v", err)
+	}
+
+	hs256 := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "7"})
+	hmac, _ := hs256.SignedString([]byte("secret"))
+	if _, err := jwt.Parse(hmac, remote.Keyfunc); err == nil {
+		t.Fatal("HS256 token accepted")
+	}
+}
@@ -0,0 +1,123 @@
+package jwks
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// minRefreshInterval limits how often an unknown kid can trigger a new fetch
+const minRefreshInterval = 30 * time.Second
+
+// RemoteKeySet verifies tokens signed by another issuer (mynance-auth, an external identity provider) using its published JWKS
+type RemoteKeySet struct {
+	mu        sync.RWMutex
+	url       string
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	client    *http.Client
+}
+
+// NewRemoteKeySet creates a key set backed by a JWKS URL
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:    url,
+		keys:   make(map[string]*rsa.PublicKey),
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Refresh downloads the current JWKS document
+func (r *RemoteKeySet) Refresh() error {
+	r.mu.Lock()
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, r.url)
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		// Provedores costumam publicar chaves de outros tipos junto; só RSA interessa
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("key '%s': %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// Keyfunc resolves the verification key of a token, fetching the JWKS again for unknown kids
+func (r *RemoteKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	stale := time.Since(r.fetchedAt) > minRefreshInterval
+	r.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+
+	// Kid desconhecido: o emissor rotacionou as chaves ou ainda não foram buscadas
+	if stale {
+		if err := r.Refresh(); err != nil {
+			return nil, err
+		}
+		r.mu.RLock()
+		key, ok = r.keys[kid]
+		r.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key '%s'", kid)
+}
+
+func (k JSONWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
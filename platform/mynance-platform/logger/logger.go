@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var Log *zap.Logger
+
+var initOnce sync.Once
+
+// RequestIDHeader carries the correlation id of a request between the services and back to the client
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey struct{}
+
+type requestIDKey struct{}
+
+// InitLogger builds the JSON logger; fields listed in SensitiveFields are redacted and the standard log
+// package is redirected to it, so every line a service writes has the same format. It is the first
+// thing a service calls, before the environment is loaded; calls after the first do nothing.
+func InitLogger() {
+	initOnce.Do(func() {
+		logger, err := zap.NewProduction(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &redactingCore{Core: core}
+		}))
+		if err != nil {
+			panic("[ERROR] [LOGGER] Failed to initialize logger")
+		}
+		Log = logger
+
+		// Bibliotecas de terceiros ainda usam o pacote log: as linhas viram JSON no nível do prefixo
+		log.SetFlags(0)
+		log.SetPrefix("")
+		log.SetOutput(stdLogWriter{})
+	})
+}
+
+// stdLogWriter receives the lines of the standard log package and writes them with Log
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+	logger := Log.WithOptions(zap.AddCallerSkip(3))
+
+	switch {
+	case strings.HasPrefix(message, "[ERROR] "):
+		logger.Error(strings.TrimPrefix(message, "[ERROR] "))
+	case strings.HasPrefix(message, "[WARN] "):
+		logger.Warn(strings.TrimPrefix(message, "[WARN] "))
+	default:
+		logger.Info(strings.TrimPrefix(message, "[INFO] "))
+	}
+	return len(p), nil
+}
+
+// Ctx returns the logger for a request: the one stored by WithLogger, carrying the request id, route and user,
+// or Log. Both get the traceID and spanID of the span in ctx, if any, so that log lines can be found from
+// a trace and the other way around.
+func Ctx(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(contextKey{}).(*zap.Logger)
+	if !ok {
+		logger = Log
+	}
+
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsValid() {
+		return logger
+	}
+	return logger.With(
+		zap.String("traceID", span.TraceID().String()),
+		zap.String("spanID", span.SpanID().String()),
+	)
+}
+
+// WithLogger stores the request-scoped logger returned by Ctx
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// WithFields adds fields to the request-scoped logger, e.g. the user once the token was validated
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	logger, ok := ctx.Value(contextKey{}).(*zap.Logger)
+	if !ok {
+		logger = Log
+	}
+	return WithLogger(ctx, logger.With(fields...))
+}
+
+// WithRequestID stores the correlation id of the request, see RequestID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the correlation id of the request of ctx, to pass on to other services in RequestIDHeader
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func CloseLogger() {
+	if Log != nil {
+		Log.Sync()
+	}
+}
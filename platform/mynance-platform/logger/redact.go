@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the value of a sensitive field
+const Redacted = "[REDACTED]"
+
+// SensitiveFields are the field names, compared without case, "_" or "-", whose values never reach the logs.
+// Query parameters with these names are redacted by RedactQuery.
+var SensitiveFields = map[string]bool{
+	"password":        true,
+	"newpassword":     true,
+	"currentpassword": true,
+	"secret":          true,
+	"clientsecret":    true,
+	"token":           true,
+	"accesstoken":     true,
+	"refreshtoken":    true,
+	"idtoken":         true,
+	"usertoken":       true,
+	"servicetoken":    true,
+	"code":            true,
+	"codeverifier":    true,
+	"recoverycode":    true,
+	"authorization":   true,
+	"cookie":          true,
+	"setcookie":       true,
+}
+
+// IsSensitive reports whether a field or parameter name is in SensitiveFields
+func IsSensitive(name string) bool {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("_", "", "-", "").Replace(name)
+	return SensitiveFields[name]
+}
+
+// RedactQuery returns the raw query of a URL with the values of sensitive parameters replaced
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for name := range values {
+		if IsSensitive(name) {
+			values[name] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// redactingCore blanks the sensitive fields before they are encoded, whoever logs them
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redact(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redact(fields))
+}
+
+func redact(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, field := range fields {
+		if !IsSensitive(field.Key) {
+			continue
+		}
+		// Copia só quando precisa: o slice é do chamador
+		if redacted == nil {
+			redacted = append([]zapcore.Field(nil), fields...)
+		}
+		redacted[i] = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Redacted}
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
@@ -0,0 +1,160 @@
+package loginguard
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Policy controls when repeated login failures lock an email or an IP
+type Policy struct {
+	// MaxFailures is the number of consecutive failures allowed before the first lockout
+	MaxFailures int
+	// BaseLockout doubles for every failure after MaxFailures, up to MaxLockout
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// ResetAfter forgets old failures when no new one happened in this window
+	ResetAfter time.Duration
+	// IPFailureLimit failed logins from one IP within IPFailureWindow block that IP for the rest of the window
+	IPFailureLimit  int
+	IPFailureWindow time.Duration
+}
+
+// Default is the policy of every login of mynance-auth and mynance-auth-admin
+var Default = Policy{
+	MaxFailures:     5,
+	BaseLockout:     time.Minute,
+	MaxLockout:      time.Hour,
+	ResetAfter:      time.Hour,
+	IPFailureLimit:  50,
+	IPFailureWindow: 15 * time.Minute,
+}
+
+// Failures returns the consecutive failures of an email after a new one, given the count stored
+// and the time since the last failure
+func (p Policy) Failures(previous int, sinceLast time.Duration) int {
+	if previous <= 0 || sinceLast > p.ResetAfter {
+		return 1
+	}
+	return previous + 1
+}
+
+// Lockout returns how long an email stays locked after this many consecutive failures; zero means not locked
+func (p Policy) Lockout(failures int) time.Duration {
+	if failures < p.MaxFailures {
+		return 0
+	}
+	// 1x, 2x, 4x... o bloqueio base a cada falha acima do limite
+	lockout := time.Duration(float64(p.BaseLockout) * math.Pow(2, float64(failures-p.MaxFailures)))
+	if lockout > p.MaxLockout || lockout <= 0 {
+		return p.MaxLockout
+	}
+	return lockout
+}
+
+// Attempt is one login, as recorded in the login history
+type Attempt struct {
+	Email     string
+	UserID    *int
+	IP        string
+	UserAgent string
+	// SessionID is the session a successful login created, if the service has sessions
+	SessionID string
+}
+
+// Store keeps the attempts and lockouts in the database of each service
+type Store interface {
+	// LockoutRemaining returns how long logins for the email stay blocked; zero means not blocked
+	LockoutRemaining(ctx context.Context, email string) (time.Duration, error)
+	// RecentIPFailures counts failed logins from an IP in the last window
+	RecentIPFailures(ctx context.Context, ip string, window time.Duration) (int, error)
+	// RecordFailure records a failed attempt and locks the email as policy decides, with Failures and Lockout.
+	// It returns the lockout applied by this failure, if any.
+	RecordFailure(ctx context.Context, attempt Attempt, policy Policy) (time.Duration, error)
+	// RecordSuccess records a successful attempt and clears the failures of the email
+	RecordSuccess(ctx context.Context, attempt Attempt) error
+}
+
+// Guard throttles the password logins of a service
+type Guard struct {
+	Policy Policy
+	Store  Store
+}
+
+// New returns a guard with the default policy
+func New(store Store) *Guard {
+	return &Guard{Policy: Default, Store: store}
+}
+
+// NormalizeEmail is the form emails are locked and recorded under
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Allowed answers 429 while the email or the client IP is blocked
+func (g *Guard) Allowed(c *gin.Context, email string) bool {
+	remaining, err := g.Store.LockoutRemaining(c.Request.Context(), NormalizeEmail(email))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if remaining > 0 {
+		tooManyAttempts(c, remaining)
+		return false
+	}
+
+	failures, err := g.Store.RecentIPFailures(c.Request.Context(), c.ClientIP(), g.Policy.IPFailureWindow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if failures >= g.Policy.IPFailureLimit {
+		tooManyAttempts(c, g.Policy.IPFailureWindow)
+		return false
+	}
+
+	return true
+}
+
+// Failed records the failure and answers with the same message for unknown emails and wrong passwords
+func (g *Guard) Failed(c *gin.Context, email string, userID *int) {
+	lockout, err := g.Store.RecordFailure(c.Request.Context(), attempt(c, email, userID), g.Policy)
+	if err != nil {
+		logger.Ctx(c.Request.Context()).Error("Failed to record login failure", zap.Error(err))
+	}
+	if lockout > 0 {
+		logger.Ctx(c.Request.Context()).Warn("Login locked after repeated failures", zap.String("ip", c.ClientIP()), zap.Duration("lockout", lockout))
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// Succeeded records the login, and the session it created if any, in the user's history
+func (g *Guard) Succeeded(c *gin.Context, email string, userID int, sessionID string) {
+	succeeded := attempt(c, email, &userID)
+	succeeded.SessionID = sessionID
+	if err := g.Store.RecordSuccess(c.Request.Context(), succeeded); err != nil {
+		logger.Ctx(c.Request.Context()).Error("Failed to record login", zap.Int("userID", userID), zap.Error(err))
+	}
+}
+
+func attempt(c *gin.Context, email string, userID *int) Attempt {
+	return Attempt{
+		Email:     NormalizeEmail(email),
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func tooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+}
@@ -0,0 +1,215 @@
+package loginguard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  int
+		sinceLast time.Duration
+		want      int
+	}{
+		{"first failure", 0, 0, 1},
+		{"consecutive failure", 3, time.Minute, 4},
+		{"at the reset window", 3, Default.ResetAfter, 4},
+		{"after the reset window", 7, Default.ResetAfter + time.Second, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Default.Failures(tt.previous, tt.sinceLast); got != tt.want {
+				t.Fatalf("Failures(%d, %s) = %d, want %d", tt.previous, tt.sinceLast, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{"below the threshold", Default.MaxFailures - 1, 0},
+		{"at the threshold", Default.MaxFailures, time.Minute},
+		{"one above", Default.MaxFailures + 1, 2 * time.Minute},
+		{"doubles", Default.MaxFailures + 5, 32 * time.Minute},
+		{"capped", Default.MaxFailures + 6, time.Hour},
+		{"capped on overflow", Default.MaxFailures + 2000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Default.Lockout(tt.failures); got != tt.want {
+				t.Fatalf("Lockout(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+// memoryStore keeps the lockouts like the services' tables, with a clock the test moves
+type memoryStore struct {
+	now         time.Time
+	failures    map[string]int
+	lastFailure map[string]time.Time
+	lockedUntil map[string]time.Time
+	ipFailures  map[string]int
+	successes   []Attempt
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		now:         time.Now(),
+		failures:    map[string]int{},
+		lastFailure: map[string]time.Time{},
+		lockedUntil: map[string]time.Time{},
+		ipFailures:  map[string]int{},
+	}
+}
+
+func (s *memoryStore) LockoutRemaining(ctx context.Context, email string) (time.Duration, error) {
+	if remaining := s.lockedUntil[email].Sub(s.now); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) RecentIPFailures(ctx context.Context, ip string, window time.Duration) (int, error) {
+	return s.ipFailures[ip], nil
+}
+
+func (s *memoryStore) RecordFailure(ctx context.Context, attempt Attempt, policy Policy) (time.Duration, error) {
+	s.ipFailures[attempt.IP]++
+	failures := policy.Failures(s.failures[attempt.Email], s.now.Sub(s.lastFailure[attempt.Email]))
+	s.failures[attempt.Email] = failures
+	s.lastFailure[attempt.Email] = s.now
+
+	lockout := policy.Lockout(failures)
+	if lockout > 0 {
+		s.lockedUntil[attempt.Email] = s.now.Add(lockout)
+	}
+	return lockout, nil
+}
+
+func (s *memoryStore) RecordSuccess(ctx context.Context, attempt Attempt) error {
+	s.successes = append(s.successes, attempt)
+	delete(s.failures, attempt.Email)
+	delete(s.lastFailure, attempt.Email)
+	delete(s.lockedUntil, attempt.Email)
+	return nil
+}
+
+// login plays a login handler: a wrong password fails, a right one succeeds
+func login(guard *Guard, email, ip string, correct bool) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	c.Request.RemoteAddr = ip + ":1234"
+
+	if !guard.Allowed(c, email) {
+		return w
+	}
+	if !correct {
+		guard.Failed(c, email, nil)
+		return w
+	}
+	guard.Succeeded(c, email, 7, "session-1")
+	c.Status(http.StatusOK)
+	return w
+}
+
+func TestGuardLocksAfterTheThreshold(t *testing.T) {
+	store := newMemoryStore()
+	guard := New(store)
+
+	for i := 1; i < Default.MaxFailures; i++ {
+		if w := login(guard, "ana@example.com", "203.0.113.1", false); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %d, want 401", i, w.Code)
+		}
+	}
+	if w := login(guard, "ana@example.com", "203.0.113.1", false); w.Code != http.StatusUnauthorized {
+		t.Fatalf("failure %d: status = %d, want 401", Default.MaxFailures, w.Code)
+	}
+
+	// Bloqueado mesmo com a senha certa, e o e-mail é comparado normalizado
+	w := login(guard, " ANA@example.com", "203.0.113.1", true)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("status = %d, Retry-After %q; want 429 after 60s", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestGuardBacksOffUpToTheCap(t *testing.T) {
+	store := newMemoryStore()
+	guard := New(store)
+
+	var lockouts []time.Duration
+	for range Default.MaxFailures + 8 {
+		login(guard, "ana@example.com", "203.0.113.1", false)
+		lockouts = append(lockouts, store.lockedUntil["ana@example.com"].Sub(store.now))
+		// Espera o bloqueio acabar, sem passar da janela que zera as falhas
+		if remaining := lockouts[len(lockouts)-1]; remaining > 0 {
+			store.now = store.now.Add(remaining)
+		}
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour, time.Hour}
+	got := lockouts[Default.MaxFailures-1:]
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("lockouts = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGuardResetsOnSuccess(t *testing.T) {
+	store := newMemoryStore()
+	guard := New(store)
+
+	for range Default.MaxFailures - 1 {
+		login(guard, "ana@example.com", "203.0.113.1", false)
+	}
+	if w := login(guard, "ana@example.com", "203.0.113.1", true); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(store.successes) != 1 || store.successes[0].SessionID != "session-1" || *store.successes[0].UserID != 7 {
+		t.Fatalf("success recorded as %+v", store.successes)
+	}
+
+	// Sem o reset a próxima falha bloquearia o e-mail
+	for i := 1; i < Default.MaxFailures; i++ {
+		login(guard, "ana@example.com", "203.0.113.1", false)
+	}
+	if w := login(guard, "ana@example.com", "203.0.113.1", true); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 after the failures were reset", w.Code)
+	}
+}
+
+func TestGuardBlocksTheIP(t *testing.T) {
+	store := newMemoryStore()
+	store.ipFailures["203.0.113.1"] = Default.IPFailureLimit
+	guard := New(store)
+
+	if w := login(guard, "bruno@example.com", "203.0.113.1", true); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "900" {
+		t.Fatalf("status = %d, Retry-After %q; want 429 for the rest of the window", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := login(guard, "bruno@example.com", "203.0.113.2", true); w.Code != http.StatusOK {
+		t.Fatalf("status from another IP = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/auth"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Auth extracts and validates the user token from the cookie or the Authorization header.
+// scopeResource is the personal access token scope that covers the service, e.g. "banks".
+func Auth(scopeResource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Navegador usa o cookie; scripts mandam "Authorization: Bearer" com um token de acesso pessoal
+		token, err := c.Cookie("token")
+		if err != nil || token == "" {
+			token = bearerToken(c)
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - No token provided"})
+			c.Abort()
+			return
+		}
+
+		// Validate the token
+		tokenClaims, err := auth.ValidateUserToken(c.Request.Context(), token, c.Request.Method, c.Request.URL.Path)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - " + err.Error()})
+			c.Abort()
+			return
+		}
+
+		readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+
+		// Tokens de acesso pessoal e de clientes OAuth só alcançam o que os escopos permitem
+		if tokenClaims.TokenType == claims.TypePersonalAccessToken || tokenClaims.ClientID != "" {
+			action := claims.ScopeWrite
+			if readOnly {
+				action = claims.ScopeRead
+			}
+			if !claims.HasScope(tokenClaims.Scopes, scopeResource, action) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden - token lacks scope " + scopeResource + ":" + action})
+				c.Abort()
+				return
+			}
+		}
+
+		// Personificação pelo suporte é só leitura, a menos que o admin tenha liberado escrita
+		if tokenClaims.TokenType == claims.TypeImpersonation && !tokenClaims.AllowWrite && !readOnly {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden - impersonation token is read-only"})
+			c.Abort()
+			return
+		}
+
+		// Attach user info to the context
+		c.Set("userId", tokenClaims.UserID)
+		c.Set("userEmail", tokenClaims.Email)
+		c.Set("tokenType", tokenClaims.TokenType)
+		c.Set("clientId", tokenClaims.ClientID)
+		c.Request = c.Request.WithContext(logger.WithFields(c.Request.Context(), zap.Int("userID", tokenClaims.UserID)))
+
+		// Continue to next handler
+		c.Next()
+	}
+}
+
+// SessionOnly lets through only tokens of a signed-in session; use it after Auth on credential and account
+// lifecycle routes, which personal access tokens, OAuth clients and impersonation must never reach
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("tokenType") != claims.TypeUser || c.GetString("clientId") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden - this route requires a signed-in session"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
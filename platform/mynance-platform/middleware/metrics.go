@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/prometheus"
+)
+
+// Prometheus records the count, duration, size and errors of the requests, labeled by service, route and method
+func Prometheus() gin.HandlerFunc {
+	service := prometheus.ServiceName()
+	return func(c *gin.Context) {
+		start := time.Now()
+		route := c.FullPath()
+		if route == "" {
+			route = "undefined"
+		}
+		method := c.Request.Method
+
+		prometheus.RequestsInProgress.WithLabelValues(service, route, method).Inc()
+
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		duration := time.Since(start).Seconds()
+		responseSize := float64(c.Writer.Size())
+
+		prometheus.RequestCounter.WithLabelValues(service, route, method, status).Inc()
+		prometheus.RequestDuration.WithLabelValues(service, route, method).Observe(duration)
+		prometheus.RequestsInProgress.WithLabelValues(service, route, method).Dec()
+		prometheus.ResponseSizeBytes.WithLabelValues(service, route, method).Observe(responseSize)
+
+		if c.Writer.Status() >= 500 {
+			prometheus.ErrorCounter.WithLabelValues(service, route, method).Inc()
+		}
+	}
+}
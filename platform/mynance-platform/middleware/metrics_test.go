@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPrometheusLabelsEveryMetric(t *testing.T) {
+	r := gin.New()
+	r.Use(Prometheus())
+	r.GET("/banks", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/banks", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	// Um label a menos faz o WithLabelValues entrar em pânico em toda requisição
+	if w := serve(r, http.MethodGet, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w := serve(r, http.MethodPost, nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+}
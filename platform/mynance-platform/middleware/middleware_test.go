@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/auth"
+	"github.com/jvlerner/mynance-platform/auth/authtest"
+	"github.com/jvlerner/mynance-platform/claims"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const testResource = "banks"
+
+var fakeAuth *authtest.Server
+
+// TestMain starts a fake mynance-auth and mynance-auth-admin and configures auth against them, once per process
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	fakeAuth = authtest.Start(testResource)
+	auth.Init()
+
+	code := m.Run()
+	auth.Close()
+	fakeAuth.Close()
+	os.Exit(code)
+}
+
+func personalAccessToken(name string, scopes ...string) string {
+	return fakeAuth.PersonalAccessToken(name, scopes...)
+}
+
+// signedToken signs a token for this service and registers it as still valid
+func signedToken(t *testing.T, tokenType string, fields map[string]any) string {
+	t.Helper()
+
+	signed, err := fakeAuth.Sign(tokenType, []string{testResource}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func protectedRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	group := r.Group("/", Auth(testResource))
+	group.Use(handlers...)
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"userId": c.GetInt("userId"), "tokenType": c.GetString("tokenType")})
+	}
+	group.GET("/banks", ok)
+	group.POST("/banks", ok)
+	return r
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func serve(r *gin.Engine, method string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/banks", nil)
+	if setup != nil {
+		setup(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	session := signedToken(t, claims.TypeUser, nil)
+	readOnly := personalAccessToken("read", "banks:read")
+	readWrite := personalAccessToken("write", "banks:write")
+	otherService := personalAccessToken("other", "customer:write")
+	impersonation := signedToken(t, claims.TypeImpersonation, nil)
+	impersonationWrite := signedToken(t, claims.TypeImpersonation, map[string]any{"allowWrite": true})
+	oauthClient := signedToken(t, claims.TypeUser, map[string]any{"clientId": "budget-app", "scopes": []string{"banks:read"}})
+
+	tests := []struct {
+		name   string
+		method string
+		setup  func(*http.Request)
+		status int
+	}{
+		{"no token", http.MethodGet, nil, http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, bearer(claims.PersonalAccessTokenPrefix + "revoked"), http.StatusUnauthorized},
+		{"session cookie", http.MethodPost, func(req *http.Request) { req.AddCookie(&http.Cookie{Name: "token", Value: session}) }, http.StatusOK},
+		{"lowercase bearer", http.MethodGet, func(req *http.Request) { req.Header.Set("Authorization", "bearer "+session) }, http.StatusOK},
+		{"read scope reads", http.MethodGet, bearer(readOnly), http.StatusOK},
+		{"read scope writes", http.MethodPost, bearer(readOnly), http.StatusForbidden},
+		{"write scope writes", http.MethodPost, bearer(readWrite), http.StatusOK},
+		{"scope of another service", http.MethodGet, bearer(otherService), http.StatusForbidden},
+		{"OAuth client within scope", http.MethodGet, bearer(oauthClient), http.StatusOK},
+		{"OAuth client beyond scope", http.MethodPost, bearer(oauthClient), http.StatusForbidden},
+		{"impersonation reads", http.MethodGet, bearer(impersonation), http.StatusOK},
+		{"impersonation writes", http.MethodPost, bearer(impersonation), http.StatusForbidden},
+		{"impersonation allowed to write", http.MethodPost, bearer(impersonationWrite), http.StatusOK},
+	}
+
+	r := protectedRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, tt.method, tt.setup)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && !strings.Contains(w.Body.String(), `"userId":7`) {
+				t.Fatalf("user not attached to the context: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestSessionOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"session", signedToken(t, claims.TypeUser, nil), http.StatusOK},
+		{"personal access token", personalAccessToken("session-only", "banks:write"), http.StatusForbidden},
+		{"OAuth client", signedToken(t, claims.TypeUser, map[string]any{"clientId": "budget-app", "scopes": []string{"banks:write"}}), http.StatusForbidden},
+		{"impersonation", signedToken(t, claims.TypeImpersonation, map[string]any{"allowWrite": true}), http.StatusForbidden},
+	}
+
+	r := protectedRouter(SessionOnly())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, http.MethodPost, bearer(tt.token)); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequestLogRequestID(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestLog())
+	r.GET("/banks", func(c *gin.Context) {
+		// O id também chega ao contexto da requisição, de onde o cliente de serviço o propaga
+		c.String(http.StatusOK, logger.RequestID(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{"none", "", false},
+		{"valid", "req-123", true},
+		{"control characters", "req\x01123", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, func(req *http.Request) {
+				if tt.sent != "" {
+					req.Header.Set(logger.RequestIDHeader, tt.sent)
+				}
+			})
+
+			got := w.Header().Get(logger.RequestIDHeader)
+			if tt.keep && got != tt.sent {
+				t.Fatalf("request id = %q, want the one sent %q", got, tt.sent)
+			}
+			if !tt.keep && (got == tt.sent || !validRequestID(got)) {
+				t.Fatalf("request id = %q, want a new one", got)
+			}
+			if w.Body.String() != got {
+				t.Fatalf("request id in the context = %q, in the header %q", w.Body, got)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestLog(), Recovery())
+	r.GET("/banks", func(c *gin.Context) { panic("boom") })
+
+	w := serve(r, http.MethodGet, nil)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "boom") {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	r := gin.New()
+	r.Use(RateLimit())
+	r.GET("/banks", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	from := func(ip string) func(*http.Request) {
+		return func(req *http.Request) { req.RemoteAddr = ip + ":1234" }
+	}
+
+	limited := 0
+	for i := range burst + 10 {
+		w := serve(r, http.MethodGet, from("203.0.113.1"))
+		if w.Code == http.StatusTooManyRequests {
+			if i < burst {
+				t.Fatalf("request %d limited within the burst of %d", i, burst)
+			}
+			limited++
+		}
+	}
+	if limited == 0 {
+		t.Fatalf("no request limited after %d", burst+10)
+	}
+
+	// Outro cliente tem o próprio balde
+	if w := serve(r, http.MethodGet, from("203.0.113.2")); w.Code != http.StatusOK {
+		t.Fatalf("status of another client = %d, want 200", w.Code)
+	}
+}
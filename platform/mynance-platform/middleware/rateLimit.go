@@ -20,13 +20,23 @@ var (
 	mu        sync.Mutex
 	rateLimit = rate.Every(time.Minute / 200) // 200 req/min
 	burst     = 100
+
+	cleanupStop     = make(chan struct{})
+	cleanupStopOnce sync.Once
 )
 
-// StartRateLimiter starts a cron that cleans old clients every 5 minutes.
+// StartRateLimiter starts a cron that cleans old clients every 5 minutes, until StopRateLimiter is called.
 func StartRateLimiter() {
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
-		for range ticker.C {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cleanupStop:
+				return
+			case <-ticker.C:
+			}
+
 			mu.Lock()
 			for ip, cl := range clients {
 				if time.Since(cl.lastSeen) > 10*time.Minute {
@@ -38,6 +48,13 @@ func StartRateLimiter() {
 	}()
 }
 
+// StopRateLimiter stops the cleanup cron
+func StopRateLimiter() {
+	cleanupStopOnce.Do(func() {
+		close(cleanupStop)
+	})
+}
+
 func getLimiter(ip string) *rate.Limiter {
 	mu.Lock()
 	defer mu.Unlock()
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// maxRequestIDLength bounds the ids accepted from callers, which end up in every log line
+const maxRequestIDLength = 128
+
+// RequestLog assigns the request id, keeping a valid one sent by the caller, stores the request-scoped
+// logger in the request context and writes one JSON access log line when the request is done
+func RequestLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(logger.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(logger.RequestIDHeader, requestID)
+		c.Set("requestId", requestID)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "undefined"
+		}
+
+		ctx := logger.WithRequestID(c.Request.Context(), requestID)
+		ctx = logger.WithFields(ctx, zap.String("requestID", requestID), zap.String("route", route))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Contexto lido depois do Next: o middleware de autenticação acrescenta o usuário ao logger
+		log := logger.Ctx(c.Request.Context())
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", logger.RedactQuery(c.Request.URL.RawQuery)),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("responseSize", c.Writer.Size()),
+			zap.String("ip", c.ClientIP()),
+			zap.String("userAgent", c.Request.UserAgent()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("errors", errs))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			log.Error("Request", fields...)
+		case status >= http.StatusBadRequest:
+			log.Warn("Request", fields...)
+		default:
+			log.Info("Request", fields...)
+		}
+	}
+}
+
+// Recovery answers 500 when a handler panics and logs the panic with the request fields
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		logger.Ctx(c.Request.Context()).Error("Handler panicked", zap.Any("panic", recovered), zap.Stack("stack"))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	// Só caracteres visíveis de ASCII: o id vai para os logs e para o cabeçalho de resposta
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
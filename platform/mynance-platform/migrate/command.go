@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+const usage = `Usage: api migrate [command]
+
+Commands:
+  up         apply the pending migrations (default)
+  down [n]   revert the last n applied migrations (default 1)
+  status     list applied and pending migrations
+  verify     exit with status 1 if the schema does not match the migrations
+`
+
+// Apply runs the pending migrations while a service starts, unless DB_MIGRATE_ON_START is "false";
+// it exits the process when they fail
+func Apply(db *sql.DB, source fs.FS) {
+	if os.Getenv("DB_MIGRATE_ON_START") == "false" {
+		logger.Log.Info("Skipping migrations on start")
+		return
+	}
+
+	migrator, err := New(db, source)
+	if err != nil {
+		logger.Log.Fatal("Failed to read migrations", zap.Error(err))
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		logger.Log.Info("Migration applied", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+	}
+	if err != nil {
+		logger.Log.Fatal("Failed to apply migrations", zap.Error(err))
+	}
+}
+
+// Command runs the migrate subcommand of a service and returns the process exit code
+func Command(db *sql.DB, source fs.FS, args []string) int {
+	return command(db, source, args, os.Stdout, os.Stderr)
+}
+
+func command(db *sql.DB, source fs.FS, args []string, stdout, stderr io.Writer) int {
+	name := "up"
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	migrator, err := New(db, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read migrations: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch name {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(stdout, "applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(stdout, "no pending migrations")
+		}
+		return 0
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintf(stderr, "invalid number of migrations %q\n", args[1])
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(stdout, "reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(stdout, "no applied migrations")
+		}
+		return 0
+
+	case "status":
+		applied, pending, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, version := range sortedVersions(applied) {
+			row := applied[version]
+			fmt.Fprintf(stdout, "applied  %d_%s  %s\n", row.Version, row.Name, row.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, migration := range pending {
+			fmt.Fprintf(stdout, "pending  %d_%s\n", migration.Version, migration.Name)
+		}
+		return 0
+
+	case "verify":
+		problems, err := migrator.Verify(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(stdout, problem)
+		}
+		if len(problems) > 0 {
+			return 1
+		}
+		fmt.Fprintln(stdout, "schema matches the migrations")
+		return 0
+
+	default:
+		fmt.Fprintf(stderr, "unknown migrate command %q\n\n%s", name, usage)
+		return 2
+	}
+}
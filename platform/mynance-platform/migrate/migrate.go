@@ -0,0 +1,439 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while migrations run, so replicas starting together apply them once
+const lockKey int64 = 0x6d796e616e6365 // "mynance"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change, read from <version>_<name>.up.sql and <version>_<name>.down.sql
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Applied is a row of schema_migrations
+type Applied struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+	// snapshot is the schema as it was right after the migration ran
+	snapshot string
+}
+
+// Migrator applies the migrations of a service to its database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New reads the migrations embedded by a service; every version needs an up file, the down file is optional
+func New(db *sql.DB, source fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file '%s' does not match <version>_<name>.(up|down).sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file '%s' has an invalid version", entry.Name())
+		}
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: '%s' and '%s'", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrations returns the migrations known to this build, in order
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration, each in its own transaction, and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkHistory(applied, true); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkHistory(applied, false); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns the applied migrations and the ones of this build still pending
+func (m *Migrator) Status(ctx context.Context) (map[int64]Applied, []Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return applied, pending, nil
+}
+
+// Verify compares the database with the migrations of this build and describes every difference found:
+// pending or unknown migrations, migration files edited after being applied, and changes made to the
+// schema outside of migrations since the last one ran. No problems means the schema is what the migrations produce.
+func (m *Migrator) Verify(ctx context.Context) ([]string, error) {
+	applied, pending, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	known := map[int64]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	var last *Applied
+	for _, version := range sortedVersions(applied) {
+		row := applied[version]
+		migration, ok := known[version]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("migration %d_%s is applied but not part of this build", row.Version, row.Name))
+		case migration.Checksum != row.Checksum:
+			problems = append(problems, fmt.Sprintf("migration %d_%s was changed after being applied", row.Version, row.Name))
+		}
+		last = &row
+	}
+	for _, migration := range pending {
+		problems = append(problems, fmt.Sprintf("migration %d_%s is pending", migration.Version, migration.Name))
+	}
+
+	if last != nil {
+		live, err := snapshot(ctx, m.db)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range diffLines(last.snapshot, live) {
+			problems = append(problems, "schema drift: "+line)
+		}
+	}
+	return problems, nil
+}
+
+// Check returns a readiness check that fails while migrations of this build are not applied to the database
+func Check(db *sql.DB, source fs.FS) func(ctx context.Context) error {
+	migrator, err := New(db, source)
+	return func(ctx context.Context) error {
+		if err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrator.migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				return fmt.Errorf("migration %d_%s is pending", migration.Version, migration.Name)
+			}
+		}
+		return nil
+	}
+}
+
+// checkHistory refuses to migrate a database whose applied migrations were edited. Migrations this build
+// does not know are refused too, unless allowUnknown: an older replica may start after a newer one migrated.
+func (m *Migrator) checkHistory(applied map[int64]Applied, allowUnknown bool) error {
+	known := map[int64]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for _, version := range sortedVersions(applied) {
+		row := applied[version]
+		migration, ok := known[version]
+		if !ok {
+			if allowUnknown {
+				continue
+			}
+			return fmt.Errorf("database has migration %d_%s, which is not part of this build", row.Version, row.Name)
+		}
+		if migration.Checksum != row.Checksum {
+			return fmt.Errorf("migration %d_%s was changed after being applied", row.Version, row.Name)
+		}
+	}
+	return nil
+}
+
+// locked runs fn on a single connection holding the advisory lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	// Libera com um contexto próprio: o lock precisa sair mesmo se ctx já foi cancelado
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return err
+	}
+	schema, err := snapshot(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, schema_snapshot)
+		VALUES ($1, $2, $3, $4)`, migration.Version, migration.Name, migration.Checksum, schema); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		schema_snapshot TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, q querier) (map[int64]Applied, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum, schema_snapshot, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]Applied{}
+	for rows.Next() {
+		var row Applied
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.snapshot, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+func sortedVersions(applied map[int64]Applied) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// snapshot describes the tables, columns, constraints and indexes of the current schema, one per line
+func snapshot(ctx context.Context, q querier) (string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT format('column %s.%s %s%s%s', c.relname, a.attname, format_type(a.atttypid, a.atttypmod),
+			CASE WHEN a.attnotnull THEN ' not null' ELSE '' END,
+			COALESCE(' default ' || pg_get_expr(d.adbin, d.adrelid), ''))
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE c.relnamespace = current_schema()::regnamespace AND c.relkind IN ('r', 'p')
+			AND a.attnum > 0 AND NOT a.attisdropped AND c.relname <> 'schema_migrations'
+		UNION ALL
+		SELECT format('constraint %s.%s %s', c.relname, con.conname, pg_get_constraintdef(con.oid))
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		WHERE c.relnamespace = current_schema()::regnamespace AND c.relname <> 'schema_migrations'
+		UNION ALL
+		SELECT format('index %s', indexdef)
+		FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'
+		ORDER BY 1`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// diffLines lists what is only in the expected schema ("missing") or only in the live one ("unexpected")
+func diffLines(expected, live string) []string {
+	inExpected := map[string]bool{}
+	for _, line := range strings.Split(expected, "\n") {
+		if line != "" {
+			inExpected[line] = true
+		}
+	}
+	inLive := map[string]bool{}
+	for _, line := range strings.Split(live, "\n") {
+		if line != "" {
+			inLive[line] = true
+		}
+	}
+
+	var diff []string
+	for _, line := range strings.Split(expected, "\n") {
+		if line != "" && !inLive[line] {
+			diff = append(diff, "missing "+line)
+		}
+	}
+	for _, line := range strings.Split(live, "\n") {
+		if line != "" && !inExpected[line] {
+			diff = append(diff, "unexpected "+line)
+		}
+	}
+	return diff
+}
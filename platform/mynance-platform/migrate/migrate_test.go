@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testMigrations = fstest.MapFS{
+	"1_create_banks.up.sql":     {Data: []byte("CREATE TABLE banks (id SERIAL PRIMARY KEY);")},
+	"1_create_banks.down.sql":   {Data: []byte("DROP TABLE banks;")},
+	"2_add_bank_name.up.sql":    {Data: []byte("ALTER TABLE banks ADD COLUMN name TEXT;")},
+	"2_add_bank_name.down.sql":  {Data: []byte("ALTER TABLE banks DROP COLUMN name;")},
+	"10_create_accounts.up.sql": {Data: []byte("CREATE TABLE accounts (id SERIAL PRIMARY KEY);")},
+	"README.md":                 {Data: []byte("not a migration")},
+}
+
+var appliedColumns = []string{"version", "name", "checksum", "schema_snapshot", "applied_at"}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrator, mock
+}
+
+// expectLocked expects the advisory lock and the schema_migrations table that wrap every run
+func expectLocked(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestNewOrdersMigrationsByVersion(t *testing.T) {
+	migrator, _ := newTestMigrator(t)
+
+	migrations := migrator.Migrations()
+	var versions []int64
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Fatalf("versions = %v, want [1 2 10]", versions)
+	}
+	if migrations[0].Name != "create_banks" || migrations[0].Down != "DROP TABLE banks;" || len(migrations[0].Checksum) != 64 {
+		t.Fatalf("unexpected migration %+v", migrations[0])
+	}
+	if migrations[2].Down != "" {
+		t.Fatal("migration without a down file got one")
+	}
+}
+
+func TestNewRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"name out of pattern", fstest.MapFS{"create_banks.sql": {Data: []byte("SELECT 1")}}},
+		{"version zero", fstest.MapFS{"0_create_banks.up.sql": {Data: []byte("SELECT 1")}}},
+		{"two names", fstest.MapFS{
+			"1_create_banks.up.sql":  {Data: []byte("SELECT 1")},
+			"1_create_bank.down.sql": {Data: []byte("SELECT 1")},
+		}},
+		{"no up file", fstest.MapFS{"1_create_banks.down.sql": {Data: []byte("DROP TABLE banks;")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(nil, tt.files); err == nil {
+				t.Fatal("invalid migrations accepted")
+			}
+		})
+	}
+}
+
+func TestUpAppliesOnlyPendingMigrations(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+	first := migrator.Migrations()[0]
+
+	expectLocked(mock)
+	// A 1 já foi aplicada e uma réplica mais nova aplicou a 99, que este build não conhece
+	mock.ExpectQuery("SELECT version, name, checksum, schema_snapshot, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows(appliedColumns).
+			AddRow(first.Version, first.Name, first.Checksum, "", time.Now()).
+			AddRow(99, "from_a_newer_build", strings.Repeat("0", 64), "", time.Now()))
+	for _, migration := range migrator.Migrations()[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT format").WillReturnRows(sqlmock.NewRows([]string{"line"}).AddRow("column banks.id integer not null"))
+		mock.ExpectExec("INSERT INTO schema_migrations").
+			WithArgs(migration.Version, migration.Name, migration.Checksum, "column banks.id integer not null").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Version != 2 || applied[1].Version != 10 {
+		t.Fatalf("applied %+v, want 2 and 10", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpRefusesMigrationChangedAfterApplied(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+	first := migrator.Migrations()[0]
+
+	expectLocked(mock)
+	mock.ExpectQuery("SELECT version, name, checksum, schema_snapshot, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows(appliedColumns).AddRow(first.Version, first.Name, strings.Repeat("0", 64), "", time.Now()))
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := migrator.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "changed after being applied") {
+		t.Fatalf("err = %v, want the edited migration refused", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckFailsWhileMigrationsArePending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	check := Check(db, testMigrations)
+	migrator, _ := New(db, testMigrations)
+	appliedUpTo := func(n int) *sqlmock.Rows {
+		rows := sqlmock.NewRows(appliedColumns)
+		for _, migration := range migrator.Migrations()[:n] {
+			rows.AddRow(migration.Version, migration.Name, migration.Checksum, "", time.Now())
+		}
+		return rows
+	}
+
+	mock.ExpectQuery("FROM schema_migrations").WillReturnRows(appliedUpTo(2))
+	if err := check(context.Background()); err == nil || !strings.Contains(err.Error(), "10_create_accounts") {
+		t.Fatalf("err = %v, want migration 10 pending", err)
+	}
+
+	mock.ExpectQuery("FROM schema_migrations").WillReturnRows(appliedUpTo(3))
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("check failed with every migration applied: %v", err)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	expected := "column banks.id integer\ncolumn banks.name text\n"
+	live := "column banks.id integer\ncolumn banks.nickname text"
+
+	got := diffLines(expected, live)
+	want := []string{"missing column banks.name text", "unexpected column banks.nickname text"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("diffLines = %q, want %q", got, want)
+	}
+	if diff := diffLines(expected, expected); len(diff) != 0 {
+		t.Fatalf("diff of equal schemas = %q", diff)
+	}
+}
+
+func TestCommandArguments(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{"help", []string{"help"}, 0, "Usage: api migrate"},
+		{"unknown command", []string{"sideways"}, 2, `unknown migrate command "sideways"`},
+		{"invalid down count", []string{"down", "zero"}, 2, `invalid number of migrations "zero"`},
+		{"negative down count", []string{"down", "-1"}, 2, `invalid number of migrations "-1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			// Nenhum destes casos chega ao banco
+			if code := command(nil, testMigrations, tt.args, &stdout, &stderr); code != tt.code {
+				t.Fatalf("exit code = %d, want %d", code, tt.code)
+			}
+			if output := stdout.String() + stderr.String(); !strings.Contains(output, tt.output) {
+				t.Fatalf("output %q does not contain %q", output, tt.output)
+			}
+		})
+	}
+}
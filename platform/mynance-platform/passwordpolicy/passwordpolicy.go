@@ -0,0 +1,298 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+// Rule identifiers returned in Violation.Rule
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSpecial      = "special"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+)
+
+// minPersonalTokenLength ignores short name parts and email local parts, like "jo" or "a"
+const minPersonalTokenLength = 4
+
+// Policy is the set of rules a new password must follow
+type Policy struct {
+	MinLength          int  `json:"minLength"`
+	MaxLength          int  `json:"maxLength"`
+	RequireUppercase   bool `json:"requireUppercase"`
+	RequireLowercase   bool `json:"requireLowercase"`
+	RequireDigit       bool `json:"requireDigit"`
+	RequireSpecial     bool `json:"requireSpecial"`
+	RejectPersonalInfo bool `json:"rejectPersonalInfo"`
+	// BreachedList is a file of SHA-1 hashes (HASH or HASH:COUNT per line), or a directory
+	// with one XXXXX.txt file per 5-character prefix holding SUFFIX:COUNT lines, as served by the k-anonymity range API
+	BreachedList string `json:"breachedList"`
+
+	breached breachedList
+}
+
+// Violation is one rule a password failed
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Default keeps the rules of the old isValidPassword check
+var Default = &Policy{
+	MinLength:          8,
+	MaxLength:          128,
+	RequireUppercase:   true,
+	RequireLowercase:   true,
+	RequireDigit:       true,
+	RequireSpecial:     true,
+	RejectPersonalInfo: true,
+}
+
+// Init loads the policy from PASSWORD_POLICY_FILE (JSON), then applies the PASSWORD_* env overrides
+func Init() {
+	policy := *Default
+
+	if path := os.Getenv("PASSWORD_POLICY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Log.Fatal("Failed to read the password policy", zap.String("path", path), zap.Error(err))
+		}
+		if err := json.Unmarshal(data, &policy); err != nil {
+			logger.Log.Fatal("Invalid password policy", zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	for env, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH": &policy.MinLength,
+		"PASSWORD_MAX_LENGTH": &policy.MaxLength,
+	} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				logger.Log.Fatal("Invalid password policy variable", zap.String("name", env), zap.String("value", value))
+			}
+			*target = parsed
+		}
+	}
+	for env, target := range map[string]*bool{
+		"PASSWORD_REQUIRE_UPPERCASE":    &policy.RequireUppercase,
+		"PASSWORD_REQUIRE_LOWERCASE":    &policy.RequireLowercase,
+		"PASSWORD_REQUIRE_DIGIT":        &policy.RequireDigit,
+		"PASSWORD_REQUIRE_SPECIAL":      &policy.RequireSpecial,
+		"PASSWORD_REJECT_PERSONAL_INFO": &policy.RejectPersonalInfo,
+	} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				logger.Log.Fatal("Invalid password policy variable", zap.String("name", env), zap.String("value", value))
+			}
+			*target = parsed
+		}
+	}
+	if value := os.Getenv("PASSWORD_BREACHED_LIST"); value != "" {
+		policy.BreachedList = value
+	}
+
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		logger.Log.Fatal("Password max length is lower than min length", zap.Int("maxLength", policy.MaxLength), zap.Int("minLength", policy.MinLength))
+	}
+
+	if policy.BreachedList != "" {
+		breached, err := loadBreachedList(policy.BreachedList)
+		if err != nil {
+			logger.Log.Fatal("Failed to load breached password list", zap.Error(err))
+		}
+		policy.breached = breached
+		logger.Log.Info("Breached password list loaded", zap.String("path", policy.BreachedList))
+	}
+
+	Default = &policy
+}
+
+// Check validates a password against the default policy; personal is the user's email, name and similar data
+func Check(password string, personal ...string) []Violation {
+	return Default.Check(password, personal...)
+}
+
+// Check returns every rule the password breaks, or nil if it is acceptable
+func (p *Policy) Check(password string, personal ...string) []Violation {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
+	}
+
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			special = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		add(RuleUppercase, "Password must include an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		add(RuleLowercase, "Password must include a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "Password must include a number")
+	}
+	if p.RequireSpecial && !special {
+		add(RuleSpecial, "Password must include a special character")
+	}
+
+	if p.RejectPersonalInfo && containsPersonalInfo(password, personal) {
+		add(RulePersonalInfo, "Password must not contain your name or email")
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.contains(password)
+		if err != nil {
+			// Lista indisponível não deve impedir a troca de senha
+			logger.Log.Warn("Failed to check breached password list", zap.Error(err))
+		} else if breached {
+			add(RuleBreached, "Password appears in a list of leaked passwords, choose another one")
+		}
+	}
+
+	return violations
+}
+
+func containsPersonalInfo(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		tokens := []string{value}
+		// E-mail: o endereço inteiro e a parte antes do @, nunca o domínio sozinho
+		words := value
+		if local, _, found := strings.Cut(value, "@"); found {
+			tokens = append(tokens, local)
+			words = local
+		}
+		// Cada parte do nome (ou do usuário do e-mail) separadamente
+		tokens = append(tokens, strings.FieldsFunc(words, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+
+		for _, token := range tokens {
+			if utf8.RuneCountInString(token) >= minPersonalTokenLength && strings.Contains(lowered, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type breachedList interface {
+	contains(password string) (bool, error)
+}
+
+func loadBreachedList(path string) (breachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return rangeDir(path), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := hashSet{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		hashes[strings.ToUpper(hash)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, errors.New("no SHA-1 hashes found in " + path)
+	}
+	return hashes, nil
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// hashSet is a whole list loaded in memory
+type hashSet map[string]struct{}
+
+func (s hashSet) contains(password string) (bool, error) {
+	_, found := s[sha1Hex(password)]
+	return found, nil
+}
+
+// rangeDir holds one file per 5-character prefix; only the file of the password's prefix is read
+type rangeDir string
+
+func (d rangeDir) contains(password string) (bool, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// Entradas de preenchimento do range API vêm com contagem 0
+		if strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
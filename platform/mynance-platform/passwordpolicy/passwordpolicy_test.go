@@ -0,0 +1,171 @@
+package passwordpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func rules(violations []Violation) []string {
+	var broken []string
+	for _, violation := range violations {
+		broken = append(broken, violation.Rule)
+	}
+	return broken
+}
+
+func TestCheck(t *testing.T) {
+	policy := *Default
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     []string
+	}{
+		{"acceptable", "Corr3ct-Horse!", nil, nil},
+		{"one below the minimum", "Aa1!aaa", nil, []string{RuleMinLength}},
+		{"at the minimum", "Aa1!aaaa", nil, nil},
+		{"at the maximum", "Aa1!" + strings.Repeat("a", 124), nil, nil},
+		{"one above the maximum", "Aa1!" + strings.Repeat("a", 125), nil, []string{RuleMaxLength}},
+		{"length in characters, not bytes", "Áé1!ççç", nil, []string{RuleMinLength}},
+		{"no uppercase", "corr3ct-horse!", nil, []string{RuleUppercase}},
+		{"no lowercase", "CORR3CT-HORSE!", nil, []string{RuleLowercase}},
+		{"no digit", "Correct-Horse!", nil, []string{RuleDigit}},
+		{"no special", "Corr3ctHorse", nil, []string{RuleSpecial}},
+		{"symbol counts as special", "Corr3ctHorse+", nil, nil},
+		{"every rule", "", nil, []string{RuleMinLength, RuleUppercase, RuleLowercase, RuleDigit, RuleSpecial}},
+		{"email", "Xx1!ana.souza@example.com", []string{"ana.souza@example.com"}, []string{RulePersonalInfo}},
+		{"email local part", "Ana.Souza-2024!", []string{"ana.souza@example.com"}, []string{RulePersonalInfo}},
+		{"email domain alone", "Example.com-2024!", []string{"ana.souza@example.com"}, nil},
+		{"part of the name", "Souza-2024!x", []string{"Ana Souza"}, []string{RulePersonalInfo}},
+		{"short name part", "Ana-2024!xyz", []string{"Ana Souza"}, nil},
+		{"blank personal data", "Corr3ct-Horse!", []string{"", "  "}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(policy.Check(tt.password, tt.personal...))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Check(%q) broke %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDisabledRules(t *testing.T) {
+	policy := &Policy{MinLength: 4}
+
+	if violations := policy.Check("abcd", "abcd@example.com"); violations != nil {
+		t.Fatalf("Check broke %v with only the minimum length on", rules(violations))
+	}
+	if got := rules(policy.Check(strings.Repeat("a", 1000))); got != nil {
+		t.Fatalf("Check broke %v without a maximum length", got)
+	}
+}
+
+// Ambas seguem as demais regras, então só a lista decide
+const (
+	acceptedPassword = "Corr3ct-Horse!"
+	breachedPassword = "Breached-Passw0rd!"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBreachedList(t *testing.T) {
+	hash := sha1Hex(breachedPassword)
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "breached.txt")
+	writeFile(t, file, "not a hash\n"+strings.ToLower(hash)+":42\n")
+
+	rangeDir := filepath.Join(dir, "range")
+	if err := os.Mkdir(rangeDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rangeDir, hash[:5]+".txt"), "0000000000000000000000000000000000A:0\n"+hash[5:]+":42\n")
+
+	// Entrada de preenchimento com contagem 0 não é vazamento
+	padded := filepath.Join(dir, "padded")
+	if err := os.Mkdir(padded, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(padded, hash[:5]+".txt"), hash[5:]+":0\n")
+
+	tests := []struct {
+		name     string
+		list     string
+		password string
+		want     bool
+	}{
+		{"file hit", file, breachedPassword, true},
+		{"file miss", file, acceptedPassword, false},
+		{"range hit", rangeDir, breachedPassword, true},
+		{"range miss without the prefix file", rangeDir, acceptedPassword, false},
+		{"range padding", padded, breachedPassword, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breached, err := loadBreachedList(tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy := *Default
+			policy.breached = breached
+
+			var want []string
+			if tt.want {
+				want = []string{RuleBreached}
+			}
+			if got := rules(policy.Check(tt.password)); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Fatalf("Check(%q) broke %v, want %v", tt.password, got, want)
+			}
+		})
+	}
+}
+
+func TestBreachedListFailing(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.txt")
+	writeFile(t, empty, "no hashes here\n")
+
+	for name, path := range map[string]string{
+		"missing": filepath.Join(dir, "missing.txt"),
+		"empty":   empty,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadBreachedList(path); err == nil {
+				t.Fatalf("loadBreachedList(%q) loaded", path)
+			}
+		})
+	}
+
+	// Arquivo do prefixo ilegível: a troca de senha segue sem a regra
+	t.Run("unreadable range file", func(t *testing.T) {
+		if err := os.Mkdir(filepath.Join(dir, sha1Hex(breachedPassword)[:5]+".txt"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		policy := *Default
+		policy.breached = rangeDir(dir)
+
+		if violations := policy.Check(breachedPassword); violations != nil {
+			t.Fatalf("Check broke %v while the list failed", rules(violations))
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/jvlerner/mynance-platform/logger"
+	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// DB is the service database, opened by InitDB
+var DB *sql.DB
+
+// InitDB connects to the database configured by DB_USER, DB_PASSWORD, DB_HOST, DB_PORT and DB_NAME
+func InitDB() {
+	DB = Connect("DB")
+}
+
+// Connect opens the database configured by <prefix>_USER, _PASSWORD, _HOST, _PORT and _NAME,
+// retrying while it is not up yet; it exits the process when it cannot connect
+func Connect(prefix string) *sql.DB {
+	dbName := os.Getenv(prefix + "_NAME")
+	connStr := fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		os.Getenv(prefix+"_USER"),
+		os.Getenv(prefix+"_PASSWORD"),
+		os.Getenv(prefix+"_HOST"),
+		os.Getenv(prefix+"_PORT"),
+		dbName,
+	)
+
+	const maxRetries = 10
+	const retryInterval = 3 * time.Second
+
+	for i := 1; i <= maxRetries; i++ {
+		db, err := Open(connStr)
+		if err != nil {
+			logger.Log.Error("Failed to open database connection", zap.String("db", dbName), zap.Int("attempt", i), zap.Error(err))
+			time.Sleep(retryInterval)
+			continue
+		}
+
+		err = db.Ping()
+		if err == nil {
+			db.SetConnMaxLifetime(5 * time.Minute)
+			db.SetConnMaxIdleTime(3 * time.Minute)
+			db.SetMaxIdleConns(5)
+			db.SetMaxOpenConns(10)
+			logger.Log.Info("Connected to the database", zap.String("db", dbName))
+			return db
+		}
+
+		db.Close()
+		logger.Log.Error("Failed to ping database", zap.String("db", dbName), zap.Int("attempt", i), zap.Error(err))
+		time.Sleep(retryInterval)
+	}
+
+	logger.Log.Fatal("Failed to connect to the database after multiple attempts", zap.String("db", dbName), zap.Int("attempts", maxRetries))
+	return nil
+}
+
+// Open opens a Postgres connection pool whose queries are recorded as spans of the request that ran them
+func Open(connStr string) (*sql.DB, error) {
+	return otelsql.Open("postgres", connStr,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		// Só consultas dentro de um trace: pings e tarefas de fundo não viram traces soltos
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			SpanFilter:           hasParentSpan,
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+}
+
+func hasParentSpan(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
+
+// CloseDB closes the database connection
+func CloseDB() {
+	if DB != nil {
+		err := DB.Close()
+		if err != nil {
+			logger.Log.Error("Failed to close database connection", zap.Error(err))
+		} else {
+			logger.Log.Info("Database connection closed")
+		}
+	}
+}
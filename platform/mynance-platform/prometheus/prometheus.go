@@ -0,0 +1,211 @@
+package prometheus
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+var (
+	serviceName = getServiceName()
+
+	RequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests, labeled by route, method, and status.",
+		},
+		[]string{"service", "route", "method", "status"},
+	)
+
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests in seconds, labeled by route and method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"service", "route", "method"},
+	)
+
+	RequestsInProgress = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_progress",
+			Help: "Number of HTTP requests currently in progress, labeled by route and method.",
+		},
+		[]string{"service", "route", "method"},
+	)
+
+	ResponseSizeBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP responses in bytes.",
+			Buckets: prometheus.ExponentialBuckets(100, 2, 10),
+		},
+		[]string{"service", "route", "method"},
+	)
+
+	ErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_errors_total",
+			Help: "Total number of 5xx server errors.",
+		},
+		[]string{"service", "route", "method"},
+	)
+
+	// Não pode se chamar go_goroutines: o coletor padrão do Go já registra esse nome, sem o label service
+	Goroutines = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "service_goroutines",
+			Help: "Number of goroutines that currently exist, labeled by service.",
+		},
+		[]string{"service"},
+	)
+
+	AuthValidationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "auth_token_validation_duration_seconds",
+			Help:    "Duration of user token validations, labeled by source (cache, local, remote) and result.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"source", "result"},
+	)
+
+	AuthCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_token_cache_requests_total",
+			Help: "Total number of validated-claims cache lookups, labeled by result (hit, miss).",
+		},
+		[]string{"result"},
+	)
+
+	ServiceTokenRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_service_token_refreshes_total",
+			Help: "Total number of service token logins against mynance-auth-admin, labeled by result.",
+		},
+		[]string{"result"},
+	)
+
+	ServiceTokenAge prometheus.GaugeFunc
+	dbGauges        = map[string]prometheus.GaugeFunc{}
+	dbGaugesMu      sync.Mutex
+	metricsServer   *http.Server
+	initOnce        sync.Once
+	closeOnce       sync.Once
+	goroutinesStop  = make(chan struct{})
+)
+
+// getServiceName reads SERVICE_NAME or defaults to "unknown"
+func getServiceName() string {
+	if name := os.Getenv("SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// ServiceName is the value of the service label, read from SERVICE_NAME
+func ServiceName() string {
+	return serviceName
+}
+
+// SetDBForMonitoring registers a gauge with the open connections of a database, labeled by name
+func SetDBForMonitoring(db *sql.DB, name string) {
+	gauge := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "db_open_connections",
+			Help:        "Number of open database connections.",
+			ConstLabels: prometheus.Labels{"service": serviceName, "db_name": name},
+		},
+		func() float64 {
+			return float64(db.Stats().OpenConnections)
+		},
+	)
+
+	dbGaugesMu.Lock()
+	defer dbGaugesMu.Unlock()
+	if _, ok := dbGauges[name]; ok {
+		return
+	}
+	prometheus.MustRegister(gauge)
+	dbGauges[name] = gauge
+}
+
+// SetServiceTokenForMonitoring registers a gauge with the age of the service token
+func SetServiceTokenForMonitoring(age func() float64) {
+	ServiceTokenAge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "auth_service_token_age_seconds",
+			Help: "Seconds since the current service token was issued.",
+		},
+		age,
+	)
+	prometheus.MustRegister(ServiceTokenAge)
+}
+
+// RemoveDBFromMonitoring unregisters the gauge of a database
+func RemoveDBFromMonitoring(name string) {
+	dbGaugesMu.Lock()
+	defer dbGaugesMu.Unlock()
+	if gauge, ok := dbGauges[name]; ok {
+		prometheus.Unregister(gauge)
+		delete(dbGauges, name)
+	}
+}
+
+// Init starts Prometheus metrics collection and exposes /metrics
+func Init() {
+	initOnce.Do(func() {
+		prometheus.MustRegister(
+			RequestCounter,
+			RequestDuration,
+			RequestsInProgress,
+			ResponseSizeBytes,
+			ErrorCounter,
+			Goroutines,
+			AuthValidationDuration,
+			AuthCacheRequests,
+			ServiceTokenRefreshes,
+		)
+
+		// Atualiza goroutines a cada 5 segundos
+		go func() {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-goroutinesStop:
+					return
+				case <-ticker.C:
+					Goroutines.WithLabelValues(serviceName).Set(float64(runtime.NumGoroutine()))
+				}
+			}
+		}()
+
+		http.Handle("/metrics", promhttp.Handler())
+		metricsServer = &http.Server{Addr: ":2222"}
+
+		go func() {
+			logger.Log.Info("Serving Prometheus metrics", zap.String("addr", metricsServer.Addr))
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Log.Error("Failed to start /metrics endpoint", zap.Error(err))
+			}
+		}()
+	})
+}
+
+// Close stops the goroutine gauge and shuts down the /metrics server
+func Close() {
+	closeOnce.Do(func() {
+		close(goroutinesStop)
+	})
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
+}
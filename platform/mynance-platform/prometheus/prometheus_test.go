@@ -0,0 +1,23 @@
+package prometheus
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// TestInitRegistersAlongsideTheGoCollector guards against metric names taken by the default collectors,
+// which make MustRegister panic when the service starts
+func TestInitRegistersAlongsideTheGoCollector(t *testing.T) {
+	Init()
+	defer Close()
+
+	Goroutines.WithLabelValues(serviceName).Set(1)
+}
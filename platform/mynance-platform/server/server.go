@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/auth"
+	"github.com/jvlerner/mynance-platform/config"
+	"github.com/jvlerner/mynance-platform/logger"
+	"github.com/jvlerner/mynance-platform/middleware"
+	"github.com/jvlerner/mynance-platform/migrate"
+	"github.com/jvlerner/mynance-platform/postgres"
+	"github.com/jvlerner/mynance-platform/prometheus"
+	"github.com/jvlerner/mynance-platform/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/zap"
+)
+
+const (
+	// shutdownTimeout is how long in-flight requests get to finish after SIGTERM
+	shutdownTimeout = 15 * time.Second
+	// checkTimeout bounds each readiness check
+	checkTimeout = 2 * time.Second
+)
+
+// Config selects what New wires for a service
+type Config struct {
+	// Name is logged on start; metrics are labeled by SERVICE_NAME
+	Name string
+	// Database opens the DB_* database as postgres.DB, monitors it and checks it on /readyz
+	Database bool
+	// Migrations are the embedded numbered SQL files of the database, applied on start; with them the
+	// binary also answers "migrate [up|down|status|verify]" instead of serving. Requires Database.
+	Migrations fs.FS
+	// ScopeResource turns on user authentication: Protected only lets valid user tokens through,
+	// and personal access tokens need this resource's scope, e.g. "banks"
+	ScopeResource string
+}
+
+// Check reports whether a dependency is usable; a non-nil error makes /readyz fail
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name     string
+	check    Check
+	critical bool
+}
+
+// Server is the Gin engine of a service with the platform middleware already in place
+type Server struct {
+	*gin.Engine
+	// Protected is the group of routes that require a user token; nil without Config.ScopeResource
+	Protected *gin.RouterGroup
+
+	checks   []namedCheck
+	shutdown []func()
+}
+
+// New loads the environment and wires logging, tracing, access logs, metrics, CORS, rate limiting, health checks and,
+// as configured, the database and user authentication. The service only registers its routes and calls Run.
+func New(cfg Config) *Server {
+	logger.InitLogger()
+	config.LoadEnv()
+	logger.Log.Info("Starting " + cfg.Name)
+
+	s := &Server{}
+
+	// Antes do banco, para que as consultas já saiam com spans
+	s.OnShutdown(tracing.Init())
+
+	if cfg.Database {
+		postgres.InitDB()
+		s.OnShutdown(postgres.CloseDB)
+
+		if cfg.Migrations != nil {
+			if len(os.Args) > 1 && os.Args[1] == "migrate" {
+				code := migrate.Command(postgres.DB, cfg.Migrations, os.Args[2:])
+				postgres.CloseDB()
+				logger.CloseLogger()
+				os.Exit(code)
+			}
+			migrate.Apply(postgres.DB, cfg.Migrations)
+			s.AddCheck("migrations", migrate.Check(postgres.DB, cfg.Migrations))
+		}
+
+		dbName := os.Getenv("DB_NAME")
+		prometheus.SetDBForMonitoring(postgres.DB, dbName)
+		s.OnShutdown(func() { prometheus.RemoveDBFromMonitoring(dbName) })
+		s.AddCheck("database", postgres.DB.PingContext)
+	}
+
+	prometheus.Init()
+	s.OnShutdown(prometheus.Close)
+
+	if cfg.ScopeResource != "" {
+		// Validação local dos tokens de usuário e token do serviço
+		auth.Init()
+		s.OnShutdown(auth.Close)
+
+		// Com AUTH_FAIL_OPEN o serviço continua atendendo sem o mynance-auth-admin
+		if auth.FailOpen() {
+			s.AddNonCriticalCheck("auth-admin", auth.CheckAuthAdmin)
+		} else {
+			s.AddCheck("auth-admin", auth.CheckAuthAdmin)
+		}
+	}
+
+	// Sem o logger em texto do gin.Default: o log de acesso sai em JSON pelo RequestLog
+	s.Engine = gin.New()
+
+	// Registradas antes dos middlewares: sondas não contam no rate limit, nas métricas nem nos traces
+	s.GET("/healthz", s.live)
+	s.GET("/readyz", s.ready)
+
+	s.Use(otelgin.Middleware(tracing.ServiceName()))
+	s.Use(middleware.RequestLog())
+	s.Use(middleware.Recovery())
+
+	middleware.StartRateLimiter()
+	s.OnShutdown(middleware.StopRateLimiter)
+	s.Use(cors.New(cors.Config{
+		AllowOrigins:     config.GetCORS(),
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-User-Token", logger.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", logger.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	s.Use(middleware.Prometheus())
+	s.Use(middleware.RateLimit())
+
+	if cfg.ScopeResource != "" {
+		s.Protected = s.Group("/")
+		s.Protected.Use(middleware.Auth(cfg.ScopeResource))
+	}
+
+	return s
+}
+
+// AddCheck adds a dependency to /readyz; the service is not ready while it fails
+func (s *Server) AddCheck(name string, check Check) {
+	s.checks = append(s.checks, namedCheck{name: name, check: check, critical: true})
+}
+
+// AddNonCriticalCheck adds a dependency the service can work without: /readyz reports it but stays ready
+func (s *Server) AddNonCriticalCheck(name string, check Check) {
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// OnShutdown registers a cleanup that runs after the HTTP server stopped, in reverse order of registration
+func (s *Server) OnShutdown(fn func()) {
+	s.shutdown = append(s.shutdown, fn)
+}
+
+// Run serves on :PORT (8080 by default) until SIGINT or SIGTERM, then lets in-flight requests finish
+// and runs the cleanups registered with OnShutdown
+func (s *Server) Run() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	srv := &http.Server{Addr: ":" + port, Handler: s.Engine}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	logger.Log.Info("Listening", zap.String("addr", srv.Addr))
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("HTTP server failed", zap.Error(err))
+		}
+	case <-ctx.Done():
+		logger.Log.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error("Failed to drain HTTP connections", zap.Error(err))
+		}
+		cancel()
+	}
+
+	for i := len(s.shutdown) - 1; i >= 0; i-- {
+		s.shutdown[i]()
+	}
+	logger.CloseLogger()
+}
+
+// live answers the liveness probe: the process is up and serving
+func (s *Server) live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ready answers the readiness probe with "ok" or "failed" per check. The probe is public, so the error,
+// which can carry hosts and database names, only goes to the log.
+func (s *Server) ready(c *gin.Context) {
+	status := "ok"
+	results := gin.H{}
+	for _, check := range s.checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+		err := check.check(ctx)
+		cancel()
+
+		if err == nil {
+			results[check.name] = "ok"
+			continue
+		}
+		results[check.name] = "failed"
+		logger.Log.Warn("Readiness check failed", zap.String("check", check.name), zap.Error(err))
+		if check.critical {
+			status = "unavailable"
+		} else if status == "ok" {
+			status = "degraded"
+		}
+	}
+
+	if status == "unavailable" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": status, "checks": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "checks": results})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newProbeServer registers only the probes, as New does before any middleware
+func newProbeServer() *Server {
+	s := &Server{Engine: gin.New()}
+	s.GET("/healthz", s.live)
+	s.GET("/readyz", s.ready)
+	return s
+}
+
+func probe(t *testing.T, s *Server, path string) (int, map[string]any, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %s: %v", w.Body, err)
+	}
+	return w.Code, body, w.Body.String()
+}
+
+func TestLive(t *testing.T) {
+	s := newProbeServer()
+	s.AddCheck("database", func(ctx context.Context) error { return errors.New("down") })
+
+	// A liveness não depende das dependências: reiniciar o processo não traz o banco de volta
+	if code, body, _ := probe(t, s, "/healthz"); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("status %d, body %v", code, body)
+	}
+}
+
+func TestReady(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	// O erro carrega host e nome do banco, que não podem sair na sonda pública
+	failing := func(ctx context.Context) error {
+		return errors.New("dial tcp db-auth.internal:5432: connection refused")
+	}
+
+	tests := []struct {
+		name        string
+		database    Check
+		authAdmin   Check
+		code        int
+		status      string
+		checkStatus map[string]string
+	}{
+		{"all passing", ok, ok, http.StatusOK, "ok", map[string]string{"database": "ok", "auth-admin": "ok"}},
+		{"critical failing", failing, ok, http.StatusServiceUnavailable, "unavailable", map[string]string{"database": "failed", "auth-admin": "ok"}},
+		{"non-critical failing", ok, failing, http.StatusOK, "degraded", map[string]string{"database": "ok", "auth-admin": "failed"}},
+		{"both failing", failing, failing, http.StatusServiceUnavailable, "unavailable", map[string]string{"database": "failed", "auth-admin": "failed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newProbeServer()
+			s.AddCheck("database", tt.database)
+			s.AddNonCriticalCheck("auth-admin", tt.authAdmin)
+
+			code, body, raw := probe(t, s, "/readyz")
+			if code != tt.code || body["status"] != tt.status {
+				t.Fatalf("status %d %v, want %d %s", code, body["status"], tt.code, tt.status)
+			}
+			checks, _ := body["checks"].(map[string]any)
+			for name, want := range tt.checkStatus {
+				if checks[name] != want {
+					t.Errorf("check %s = %v, want %s", name, checks[name], want)
+				}
+			}
+			if strings.Contains(raw, "db-auth.internal") {
+				t.Fatalf("readiness leaks the error: %s", raw)
+			}
+		})
+	}
+}
+
+func TestReadyPassesTheRequestContext(t *testing.T) {
+	s := newProbeServer()
+	s.AddCheck("database", func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("check without a deadline")
+		}
+		return nil
+	})
+
+	if code, body, _ := probe(t, s, "/readyz"); code != http.StatusOK {
+		t.Fatalf("status %d, body %v", code, body)
+	}
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/jvlerner/mynance-platform/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.uber.org/zap"
+)
+
+// flushTimeout bounds how long the shutdown waits for the last spans to be exported
+const flushTimeout = 5 * time.Second
+
+// Init installs the W3C trace context propagator and, when OTEL_EXPORTER_OTLP_ENDPOINT is set, a tracer
+// provider exporting the spans of SERVICE_NAME over OTLP/HTTP. Without the endpoint spans are not recorded,
+// but incoming trace headers are still passed on. The returned function flushes the pending spans.
+func Init() func() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func() {}
+	}
+
+	// Endpoint, headers e timeout vêm das variáveis OTEL_EXPORTER_OTLP_* padrão
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		logger.Log.Error("Failed to create the OTLP exporter, tracing disabled", zap.Error(err))
+		return func() {}
+	}
+
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(ServiceName())),
+	)
+	if err != nil {
+		logger.Log.Error("Failed to describe the service resource", zap.Error(err))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Respeita a decisão de amostragem de quem chamou; OTEL_TRACES_SAMPLER não é lido sem isso
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+	logger.Log.Info("Exporting spans", zap.String("endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Log.Error("Failed to flush spans", zap.Error(err))
+		}
+	}
+}
+
+// ServiceName reads SERVICE_NAME, the label of the metrics, or defaults to "unknown"
+func ServiceName() string {
+	if name := os.Getenv("SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "unknown"
+}